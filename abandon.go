@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// abandonedCleanup is an object the cleaner gave up cleaning up after its
+// retry budget ran out.
+type abandonedCleanup struct {
+	Key       string `json:"key"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"lastError"`
+}
+
+// abandonedCleanups collects the objects given up on since the last
+// notification.
+type abandonedCleanups struct {
+	mu      sync.Mutex
+	pending []abandonedCleanup
+}
+
+func (a *abandonedCleanups) add(abandoned abandonedCleanup) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(a.pending, abandoned)
+}
+
+// drain returns the objects given up on since the last call.
+func (a *abandonedCleanups) drain() []abandonedCleanup {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pending := a.pending
+	a.pending = nil
+	return pending
+}
+
+// abandon reports that the cleanup of an object ran out of retries through
+// the abandoned metric, a warning event on the namespace of the object and the
+// next notification.
+func (c *cleaner) abandon(ctx context.Context, key, namespace string, err error) {
+	incWithExemplar(ctx, cleanupAbandoned)
+	c.abandoned.add(abandonedCleanup{Key: key, Attempts: c.failures.maxAttempts, LastError: err.Error()})
+
+	if namespace == "" {
+		c.controllerEvent(ctx, corev1.EventTypeWarning, "CleanupAbandoned", "gave up cleaning up %s after %d failed attempts: %v", key, c.failures.maxAttempts, err)
+		return
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	c.eventf(ctx, ns, corev1.EventTypeWarning, "CleanupAbandoned", "gave up cleaning up %s after %d failed attempts: %v", key, c.failures.maxAttempts, err)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// deleteAttachments deletes the volume attachments of a volume to the given
+// removed nodes, which the attach detach controller can no longer detach.
+func (c *cleaner) deleteAttachments(ctx context.Context, pvName string, nodes []string) {
+	attachments, err := c.factory.Storage().V1().VolumeAttachments().Lister().List(labels.Everything())
+	if err != nil {
+		logf(ctx, "failed to list volume attachments: %v\n", err)
+		return
+	}
+
+	for _, attachment := range attachments {
+		source := attachment.Spec.Source.PersistentVolumeName
+		if source == nil || *source != pvName || !stringList(nodes).contains(attachment.Spec.NodeName) {
+			continue
+		}
+
+		err = c.deleteCall(ctx, func() error {
+			return c.clientset.StorageV1().VolumeAttachments().Delete(ctx, attachment.Name, metav1.DeleteOptions{})
+		})
+		if err != nil {
+			logf(ctx, "failed to delete volumeattachment(%s): %v\n", attachment.Name, err)
+			continue
+		}
+		logf(ctx, "deleted volumeattachment(%s)\n", attachment.Name)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// retryAnnotation on a claim clears it from the blacklist.
+const retryAnnotation = "local-pvc-cleaner.io/retry"
+
+const (
+	failureBackoffBase = 30 * time.Second
+	failureBackoffMax  = 30 * time.Minute
+)
+
+type failureEntry struct {
+	Key         string    `json:"key"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"lastError"`
+	NextAttempt time.Time `json:"nextAttempt"`
+	Blacklisted bool      `json:"blacklisted"`
+}
+
+// failureTracker backs off from objects that fail to be cleaned up and
+// blacklists them after too many attempts.
+type failureTracker struct {
+	maxAttempts int
+
+	mu      sync.Mutex
+	entries map[string]*failureEntry
+}
+
+func newFailureTracker(maxAttempts int) *failureTracker {
+	return &failureTracker{maxAttempts: maxAttempts, entries: map[string]*failureEntry{}}
+}
+
+// decision returns why the object must not be retried yet, or an empty
+// decision.
+func (t *failureTracker) decision(key string) decision {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[key]
+	switch {
+	case !ok:
+		return ""
+	case entry.Blacklisted:
+		return decisionSkippedBlacklisted
+	case time.Now().Before(entry.NextAttempt):
+		return decisionSkippedBackoff
+	default:
+		return ""
+	}
+}
+
+// failed records a failed attempt and returns the backoff until the object is
+// retried, zero for blacklisted objects, and whether the attempt got the
+// object blacklisted.
+func (t *failureTracker) failed(key string, err error) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[key]
+	if !ok {
+		entry = &failureEntry{Key: key}
+		t.entries[key] = entry
+	}
+
+	entry.Attempts++
+	entry.LastError = err.Error()
+	backoff := failureBackoffBase << (entry.Attempts - 1)
+	if backoff > failureBackoffMax || backoff <= 0 {
+		backoff = failureBackoffMax
+	}
+	entry.NextAttempt = time.Now().Add(backoff)
+
+	if t.maxAttempts > 0 && entry.Attempts >= t.maxAttempts && !entry.Blacklisted {
+		entry.Blacklisted = true
+		t.updateGauge()
+		return 0, true
+	}
+	if entry.Blacklisted {
+		return 0, false
+	}
+	return backoff, false
+}
+
+// skip blacklists an object on request of an operator.
+func (t *failureTracker) skip(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entries[key] = &failureEntry{Key: key, LastError: "skipped by operator", Blacklisted: true}
+	t.updateGauge()
+}
+
+// clear forgets the failures of an object and reports whether there were any.
+func (t *failureTracker) clear(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.entries[key]
+	delete(t.entries, key)
+	t.updateGauge()
+	return ok
+}
+
+func (t *failureTracker) list() []failureEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries := make([]failureEntry, 0, len(t.entries))
+	for _, entry := range t.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
+func (t *failureTracker) updateGauge() {
+	blacklisted := 0
+	for _, entry := range t.entries {
+		if entry.Blacklisted {
+			blacklisted++
+		}
+	}
+	blacklistedObjects.Set(float64(blacklisted))
+}
+
+func claimKey(pvc *corev1.PersistentVolumeClaim) string {
+	return "pvc/" + pvc.Namespace + "/" + pvc.Name
+}
+
+func volumeKey(pv *corev1.PersistentVolume) string {
+	return "pv/" + pv.Name
+}
+
+// backoffDecision returns why a claim must not be retried yet. A claim carrying
+// the retry annotation is cleared from the blacklist first, which a simulation
+// only assumes.
+func (c *cleaner) backoffDecision(ctx context.Context, pvc *corev1.PersistentVolumeClaim) decision {
+	key := claimKey(pvc)
+	if _, ok := pvc.Annotations[retryAnnotation]; ok {
+		if simulated(ctx) {
+			return ""
+		}
+		if c.failures.clear(key) {
+			logf(ctx, "cleared failures of pvc(%s) from annotation\n", pvc.Name)
+		}
+		err := c.patchClaimAnnotations(ctx, pvc, map[string]*string{retryAnnotation: nil})
+		if err != nil {
+			logf(ctx, "failed to remove %s annotation from pvc(%s): %v\n", retryAnnotation, pvc.Name, err)
+		}
+	}
+
+	return c.failures.decision(key)
+}
+
+// observeFailure records the outcome of cleaning up an object, warning once
+// when it gets blacklisted and reporting that the cleanup was abandoned.
+// Objects not blacklisted get a reconcile scheduled for when their backoff
+// ends, so they are retried without a reconcile interval.
+func (c *cleaner) observeFailure(ctx context.Context, key string, obj any, err error) {
+	if err == nil {
+		c.failures.clear(key)
+		return
+	}
+
+	retry, blacklisted := c.failures.failed(key, err)
+	if retry > 0 {
+		c.scheduleReconcile(ctx, "backoff/"+key, retry)
+	}
+	if !blacklisted {
+		return
+	}
+
+	logf(ctx, "blacklisted %s after %d failed attempts: %v\n", key, c.failures.maxAttempts, err)
+	objectsBlacklisted.Inc()
+	switch obj := obj.(type) {
+	case *corev1.PersistentVolumeClaim:
+		c.eventf(ctx, obj, corev1.EventTypeWarning, "CleanupBlacklisted", "giving up after %d failed attempts: %v", c.failures.maxAttempts, err)
+		c.abandon(ctx, key, obj.Namespace, err)
+	case *corev1.PersistentVolume:
+		c.eventf(ctx, obj, corev1.EventTypeWarning, "CleanupBlacklisted", "giving up after %d failed attempts: %v", c.failures.maxAttempts, err)
+		namespace := ""
+		if obj.Spec.ClaimRef != nil {
+			namespace = obj.Spec.ClaimRef.Namespace
+		}
+		c.abandon(ctx, key, namespace, err)
+	}
+}
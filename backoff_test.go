@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFailureTrackerFailed(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		failures    int
+		// backoff and blacklisted are returned for the last failure.
+		backoff     time.Duration
+		blacklisted bool
+		decision    decision
+	}{
+		{
+			name:     "first failure",
+			failures: 1,
+			backoff:  failureBackoffBase,
+			decision: decisionSkippedBackoff,
+		},
+		{
+			name:     "backoff doubles",
+			failures: 3,
+			backoff:  4 * failureBackoffBase,
+			decision: decisionSkippedBackoff,
+		},
+		{
+			name:     "backoff capped",
+			failures: 10,
+			backoff:  failureBackoffMax,
+			decision: decisionSkippedBackoff,
+		},
+		{
+			name:     "backoff capped after overflow",
+			failures: 70,
+			backoff:  failureBackoffMax,
+			decision: decisionSkippedBackoff,
+		},
+		{
+			name:        "below max attempts",
+			maxAttempts: 3,
+			failures:    2,
+			backoff:     2 * failureBackoffBase,
+			decision:    decisionSkippedBackoff,
+		},
+		{
+			name:        "blacklisted at max attempts",
+			maxAttempts: 3,
+			failures:    3,
+			blacklisted: true,
+			decision:    decisionSkippedBlacklisted,
+		},
+		{
+			name:        "already blacklisted",
+			maxAttempts: 3,
+			failures:    4,
+			decision:    decisionSkippedBlacklisted,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := newFailureTracker(test.maxAttempts)
+			var backoff time.Duration
+			var blacklisted bool
+			for i := 0; i < test.failures; i++ {
+				backoff, blacklisted = tracker.failed("pvc/default/data", errors.New("delete failed"))
+			}
+
+			if backoff != test.backoff {
+				t.Errorf("got backoff %s, want %s", backoff, test.backoff)
+			}
+			if blacklisted != test.blacklisted {
+				t.Errorf("got newly blacklisted %t, want %t", blacklisted, test.blacklisted)
+			}
+			if got := tracker.decision("pvc/default/data"); got != test.decision {
+				t.Errorf("got decision %q, want %q", got, test.decision)
+			}
+			if got := tracker.decision("pvc/default/other"); got != "" {
+				t.Errorf("got decision %q for an object that never failed", got)
+			}
+		})
+	}
+}
+
+func TestFailureTrackerOperator(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		skip     bool
+		clear    bool
+		cleared  bool
+		decision decision
+	}{
+		{name: "skipped", skip: true, decision: decisionSkippedBlacklisted},
+		{name: "skipped after failures", failures: 2, skip: true, decision: decisionSkippedBlacklisted},
+		{name: "cleared after failures", failures: 2, clear: true, cleared: true, decision: ""},
+		{name: "cleared after skip", skip: true, clear: true, cleared: true, decision: ""},
+		{name: "cleared without failures", clear: true, cleared: false, decision: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := newFailureTracker(5)
+			for i := 0; i < test.failures; i++ {
+				tracker.failed("pvc/default/data", errors.New("delete failed"))
+			}
+			if test.skip {
+				tracker.skip("pvc/default/data")
+			}
+			if test.clear {
+				if got := tracker.clear("pvc/default/data"); got != test.cleared {
+					t.Errorf("got cleared %t, want %t", got, test.cleared)
+				}
+			}
+
+			if got := tracker.decision("pvc/default/data"); got != test.decision {
+				t.Errorf("got decision %q, want %q", got, test.decision)
+			}
+		})
+	}
+}
+
+func TestObserveFailureSchedulesRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		err         error
+		scheduled   bool
+	}{
+		{name: "failed", err: errors.New("delete failed"), scheduled: true},
+		{name: "blacklisted", maxAttempts: 1, err: errors.New("delete failed"), scheduled: false},
+		{name: "succeeded", err: nil, scheduled: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &cleaner{
+				failures:    newFailureTracker(test.maxAttempts),
+				timers:      newReconcileTimers(),
+				reconcileCh: make(chan struct{}, 1),
+			}
+			before := time.Now()
+			c.observeFailure(context.Background(), "pv/data", nil, test.err)
+
+			due, ok := c.timers.due["backoff/pv/data"]
+			if ok != test.scheduled {
+				t.Fatalf("got reconcile scheduled %t, want %t", ok, test.scheduled)
+			}
+			if ok && due.Before(before.Add(failureBackoffBase)) {
+				t.Errorf("got reconcile due %s, before the backoff ends", due.Sub(before))
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+type batchDeletedKey struct{}
+
+// batchDeleted reports whether a claim was deleted with the other orphans of
+// its namespace by one delete collection call.
+func batchDeleted(ctx context.Context, uid types.UID) bool {
+	deleted, _ := ctx.Value(batchDeletedKey{}).(map[types.UID]bool)
+	return deleted[uid]
+}
+
+// batchSelector returns a label selector of the labels the given claims of a
+// namespace share, when it selects exactly them in the informer cache, or nil
+// when no selector does.
+func (c *cleaner) batchSelector(namespace string, claims []*corev1.PersistentVolumeClaim) labels.Selector {
+	common := labels.Set{}
+	for key, value := range claims[0].Labels {
+		common[key] = value
+	}
+	batch := map[types.UID]string{}
+	for _, pvc := range claims {
+		batch[pvc.UID] = pvc.ResourceVersion
+		for key, value := range common {
+			if pvc.Labels[key] != value {
+				delete(common, key)
+			}
+		}
+	}
+	if len(common) == 0 {
+		return nil
+	}
+
+	selector := labels.SelectorFromSet(common)
+	selected, err := c.factory.Core().V1().PersistentVolumeClaims().Lister().PersistentVolumeClaims(namespace).List(selector)
+	if err != nil || !sameClaims(selected, batch) {
+		return nil
+	}
+	return selector
+}
+
+// sameClaims reports whether the claims are exactly the ones of the batch, at
+// the same resource versions.
+func sameClaims(claims []*corev1.PersistentVolumeClaim, batch map[types.UID]string) bool {
+	if len(claims) != len(batch) {
+		return false
+	}
+	for _, pvc := range claims {
+		if resourceVersion, ok := batch[pvc.UID]; !ok || resourceVersion != pvc.ResourceVersion {
+			return false
+		}
+	}
+	return true
+}
+
+// liveBatch lists the claims the selector selects from the api server and
+// returns the resource version of the list when they are still exactly the
+// given claims, or an empty string when a claim was labeled, changed or
+// replaced since the cache saw them.
+func liveBatch(ctx context.Context, client kubernetes.Interface, namespace string, selector labels.Selector, claims []*corev1.PersistentVolumeClaim) (string, error) {
+	list, err := client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return "", err
+	}
+	batch := map[types.UID]string{}
+	for _, pvc := range claims {
+		batch[pvc.UID] = pvc.ResourceVersion
+	}
+	selected := make([]*corev1.PersistentVolumeClaim, 0, len(list.Items))
+	for i := range list.Items {
+		selected = append(selected, &list.Items[i])
+	}
+	if !sameClaims(selected, batch) {
+		return "", nil
+	}
+	return list.ResourceVersion, nil
+}
+
+// deleteBatches deletes the claims of a group of orphans with one delete
+// collection call per namespace where a label selector selects exactly the
+// orphans of the namespace, saving a round trip per claim. Only claims whose
+// cleanup starts with deleting the claim are batched: ones without pods to
+// remove first or a node to migrate to. Delete collection calls take no
+// preconditions, so the selected claims are listed from the api server first
+// and the call deletes exactly the claims of that list. The returned context
+// tells the claims deleted, the others are deleted one by one with a uid
+// precondition.
+func (c *cleaner) deleteBatches(ctx context.Context, group []candidate, mapping map[string]string) context.Context {
+	if !c.batchDeletes || !c.deletePVCs || c.deletePods || c.reportOnly() || c.externalDeletion() || c.breaker.isOpen() {
+		return ctx
+	}
+
+	byNamespace := map[string][]*corev1.PersistentVolumeClaim{}
+	for _, cand := range group {
+		if ephemeralClaim(cand.pvc) || migrating(cand, mapping) {
+			continue
+		}
+		byNamespace[cand.pvc.Namespace] = append(byNamespace[cand.pvc.Namespace], cand.pvc)
+	}
+	namespaces := make([]string, 0, len(byNamespace))
+	for namespace := range byNamespace {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	deleted := map[types.UID]bool{}
+	for _, namespace := range namespaces {
+		claims := byNamespace[namespace]
+		if len(claims) < 2 {
+			continue
+		}
+		selector := c.batchSelector(namespace, claims)
+		if selector == nil {
+			tracef("no label selector selects exactly the %d orphans of namespace(%s)\n", len(claims), namespace)
+			continue
+		}
+
+		client, err := c.clientFor(namespace)
+		if err != nil {
+			logf(ctx, "failed to get client for namespace(%s): %v\n", namespace, err)
+			continue
+		}
+		resourceVersion, err := liveBatch(ctx, client, namespace, selector, claims)
+		if err != nil {
+			logf(ctx, "failed to list pvcs(%s) of namespace(%s), deleting them one by one: %v\n", selector, namespace, err)
+			continue
+		}
+		if resourceVersion == "" {
+			logf(ctx, "pvcs(%s) of namespace(%s) changed since they were evaluated, deleting them one by one\n", selector, namespace)
+			continue
+		}
+		err = c.deleteCall(ctx, func() error {
+			return client.CoreV1().PersistentVolumeClaims(namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{
+				LabelSelector:        selector.String(),
+				ResourceVersion:      resourceVersion,
+				ResourceVersionMatch: metav1.ResourceVersionMatchExact,
+			})
+		})
+		if err != nil {
+			logf(ctx, "failed to delete pvcs(%s) of namespace(%s), deleting them one by one: %v\n", selector, namespace, err)
+			continue
+		}
+		logf(ctx, "deleted %d pvcs(%s) of namespace(%s) at once\n", len(claims), selector, namespace)
+		batchedDeletions.Add(float64(len(claims)))
+		for _, pvc := range claims {
+			deleted[pvc.UID] = true
+		}
+	}
+	if len(deleted) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, batchDeletedKey{}, deleted)
+}
+
+// migrating reports whether an orphan has a node to migrate to instead of
+// being deleted.
+func migrating(cand candidate, mapping map[string]string) bool {
+	for _, nodeName := range cand.nodes {
+		if mapping[nodeName] != "" {
+			return true
+		}
+	}
+	return false
+}
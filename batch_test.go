@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func batchClaim(name, uid, resourceVersion string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "default",
+		Name:            name,
+		UID:             types.UID(uid),
+		ResourceVersion: resourceVersion,
+		Labels:          map[string]string{"app": "db"},
+	}}
+}
+
+func TestSameClaims(t *testing.T) {
+	batch := map[types.UID]string{"a": "1", "b": "2"}
+
+	tests := []struct {
+		name   string
+		claims []*corev1.PersistentVolumeClaim
+		want   bool
+	}{
+		{
+			name:   "same claims",
+			claims: []*corev1.PersistentVolumeClaim{batchClaim("data-0", "a", "1"), batchClaim("data-1", "b", "2")},
+			want:   true,
+		},
+		{
+			name:   "claim missing",
+			claims: []*corev1.PersistentVolumeClaim{batchClaim("data-0", "a", "1")},
+			want:   false,
+		},
+		{
+			name:   "claim added",
+			claims: []*corev1.PersistentVolumeClaim{batchClaim("data-0", "a", "1"), batchClaim("data-1", "b", "2"), batchClaim("data-2", "c", "3")},
+			want:   false,
+		},
+		{
+			name:   "claim changed",
+			claims: []*corev1.PersistentVolumeClaim{batchClaim("data-0", "a", "1"), batchClaim("data-1", "b", "5")},
+			want:   false,
+		},
+		{
+			name:   "claim replaced",
+			claims: []*corev1.PersistentVolumeClaim{batchClaim("data-0", "a", "1"), batchClaim("data-1", "d", "2")},
+			want:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sameClaims(test.claims, batch); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLiveBatch(t *testing.T) {
+	cached := []*corev1.PersistentVolumeClaim{batchClaim("data-0", "a", "1"), batchClaim("data-1", "b", "2")}
+
+	tests := []struct {
+		name string
+		// live are the claims the api server lists for the selector.
+		live []*corev1.PersistentVolumeClaim
+		want string
+	}{
+		{
+			name: "unchanged",
+			live: []*corev1.PersistentVolumeClaim{batchClaim("data-0", "a", "1"), batchClaim("data-1", "b", "2")},
+			want: "42",
+		},
+		{
+			name: "claim labeled since cached",
+			live: []*corev1.PersistentVolumeClaim{batchClaim("data-0", "a", "1"), batchClaim("data-1", "b", "2"), batchClaim("data-2", "c", "3")},
+			want: "",
+		},
+		{
+			name: "claim changed since cached",
+			live: []*corev1.PersistentVolumeClaim{batchClaim("data-0", "a", "1"), batchClaim("data-1", "b", "7")},
+			want: "",
+		},
+		{
+			name: "claim replaced since cached",
+			live: []*corev1.PersistentVolumeClaim{batchClaim("data-0", "a", "1"), batchClaim("data-1", "e", "8")},
+			want: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("list", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				list := &corev1.PersistentVolumeClaimList{ListMeta: metav1.ListMeta{ResourceVersion: "42"}}
+				for _, pvc := range test.live {
+					list.Items = append(list.Items, *pvc)
+				}
+				return true, list, nil
+			})
+
+			selector := labels.SelectorFromSet(labels.Set{"app": "db"})
+			got, err := liveBatch(context.Background(), client, "default", selector, cached)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got resource version %q, want %q", got, test.want)
+			}
+		})
+	}
+}
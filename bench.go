@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+// benchStep measures the duration and allocations of a step.
+type benchStep struct {
+	start  time.Time
+	before runtime.MemStats
+}
+
+func startBenchStep() *benchStep {
+	s := &benchStep{}
+	runtime.GC()
+	runtime.ReadMemStats(&s.before)
+	s.start = time.Now()
+	return s
+}
+
+func (s *benchStep) report(w io.Writer, name string) {
+	duration := time.Since(s.start)
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	fmt.Fprintf(w, "%-20s %12s %12d allocs %10.1f MiB allocated %10.1f MiB heap\n",
+		name, duration.Round(time.Microsecond), after.Mallocs-s.before.Mallocs,
+		float64(after.TotalAlloc-s.before.TotalAlloc)/(1<<20), float64(after.HeapInuse)/(1<<20))
+}
+
+// runBench populates informer caches with a synthetic cluster, without an api
+// server, and measures detection on it.
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	nodes := fs.Int("nodes", 5000, "number of nodes")
+	pods := fs.Int("pods", 200000, "number of pods")
+	pvcs := fs.Int("pvcs", 50000, "number of local pvcs, each consumed by one pod")
+	missing := fs.Float64("missing-nodes", 0.01, "fraction of nodes that are gone")
+	fs.Parse(args)
+
+	// the cleaner logs every decision, which would dominate the timings
+	out := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		panic(err)
+	}
+	os.Stdout = devNull
+	defer func() { os.Stdout = out }()
+
+	clientset := fake.NewSimpleClientset()
+	c := &cleaner{
+		clientset:       clientset,
+		factory:         informers.NewSharedInformerFactory(clientset, 0),
+		podFactory:      informers.NewSharedInformerFactory(clientset, 0),
+		topologyKeys:    stringList{"topology.hostpath.csi/node"},
+		recorder:        &record.FakeRecorder{},
+		decisions:       newDecisionLog(),
+		seen:            newSeenNodes(),
+		stats:           newCleanupStats(),
+		failures:        newFailureTracker(0),
+		breaker:         &circuitBreaker{},
+		timers:          newReconcileTimers(),
+		cancelled:       newCancelledNodes(),
+		mode:            modeReport,
+		order:           orderPriority,
+		deletionMetrics: &deletionMetrics{namespaces: newLabelLimiter(0), storageClasses: newLabelLimiter(0)},
+		deletePVCs:      true,
+		deletePVs:       true,
+		deletePods:      true,
+		cacheNamespaces: true,
+	}
+	c.addIndexers()
+
+	nodeStore := c.factory.Core().V1().Nodes().Informer().GetIndexer()
+	pvStore := c.factory.Core().V1().PersistentVolumes().Informer().GetIndexer()
+	pvcStore := c.factory.Core().V1().PersistentVolumeClaims().Informer().GetIndexer()
+	podStore := c.podFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	missingNodes := int(float64(*nodes) * *missing)
+	nodeName := func(i int) string { return fmt.Sprintf("node-%d", i%*nodes) }
+
+	step := startBenchStep()
+	for i := missingNodes; i < *nodes; i++ {
+		nodeStore.Add(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName(i)}})
+	}
+	for i := 0; i < *pvcs; i++ {
+		namespace := fmt.Sprintf("namespace-%d", i%100)
+		name := fmt.Sprintf("pvc-%d", i)
+		volume := fmt.Sprintf("pv-%d", i)
+		node := nodeName(i)
+		uid := types.UID(fmt.Sprintf("uid-%d", i))
+
+		pvcStore.Add(&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				UID:       uid,
+				Annotations: map[string]string{
+					provisionerAnnotation:  expectedProvisionerValue,
+					selectedNodeAnnotation: node,
+				},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				VolumeName: volume,
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+			},
+		})
+		pvStore.Add(&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        volume,
+				Annotations: map[string]string{provisionedByAnnotation: expectedProvisionerValue},
+			},
+			Spec: corev1.PersistentVolumeSpec{
+				ClaimRef: &corev1.ObjectReference{Namespace: namespace, Name: name, UID: uid},
+				PersistentVolumeSource: corev1.PersistentVolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{Path: "/opt/local-path-provisioner/" + volume},
+				},
+				NodeAffinity: &corev1.VolumeNodeAffinity{Required: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+						MatchExpressions: []corev1.NodeSelectorRequirement{{
+							Key:      hostnameLabel,
+							Operator: corev1.NodeSelectorOpIn,
+							Values:   []string{node},
+						}},
+					}},
+				}},
+			},
+		})
+	}
+	for i := 0; i < *pods; i++ {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("pod-%d", i),
+				Namespace: fmt.Sprintf("namespace-%d", i%100),
+			},
+			Spec: corev1.PodSpec{NodeName: nodeName(i)},
+		}
+		if i < *pvcs {
+			pod.Spec.Volumes = []corev1.Volume{{
+				Name: "data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: fmt.Sprintf("pvc-%d", i)},
+				},
+			}}
+		}
+		podStore.Add(pod)
+	}
+	step.report(out, "populate")
+
+	ctx := context.Background()
+	step = startBenchStep()
+	candidates, err := c.allCandidates(ctx)
+	if err != nil {
+		panic(err)
+	}
+	step.report(out, "list candidates")
+
+	step = startBenchStep()
+	sum, err := c.evaluateAll(ctx, candidates)
+	if err != nil {
+		panic(err)
+	}
+	step.report(out, "evaluate")
+
+	step = startBenchStep()
+	for i := 0; i < missingNodes; i++ {
+		_, err := c.candidatesByNode(ctx, nodeName(i))
+		if err != nil {
+			panic(err)
+		}
+	}
+	step.report(out, "node lookups")
+
+	fmt.Fprintf(out, "%d candidates, %d orphans on %d missing nodes\n", len(candidates), sum.found, missingNodes)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+type breakerResult struct {
+	time   time.Time
+	failed bool
+}
+
+// circuitBreaker stops cleanups while the error rate of delete calls is above
+// a threshold and lets a single probe through every probe interval.
+type circuitBreaker struct {
+	errorRate     float64
+	window        time.Duration
+	minRequests   int
+	probeInterval time.Duration
+
+	mu        sync.Mutex
+	results   []breakerResult
+	open      bool
+	probing   bool
+	nextProbe time.Time
+}
+
+// isOpen reports whether the breaker keeps cleanups from calling the api,
+// which it does while open unless a probe is due. It does not take the probe.
+func (b *circuitBreaker) isOpen() bool {
+	if b.errorRate <= 0 {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.open && (b.probing || time.Now().Before(b.nextProbe))
+}
+
+// tryProbe reports whether a delete call may go through right now. While the
+// breaker is open it lets the call through as the probe when one is due, and
+// the probe stays taken until observe records its result.
+func (b *circuitBreaker) tryProbe() bool {
+	if b.errorRate <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if b.probing || time.Now().Before(b.nextProbe) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+type breakerTransition int
+
+const (
+	breakerUnchanged breakerTransition = iota
+	breakerOpened
+	breakerProbeFailed
+	breakerClosed
+)
+
+// observe records the result of a delete call and reports how it changed the
+// breaker.
+func (b *circuitBreaker) observe(failed bool) breakerTransition {
+	if b.errorRate <= 0 {
+		return breakerUnchanged
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.open {
+		if !b.probing {
+			return breakerUnchanged
+		}
+		b.probing = false
+		if failed {
+			b.nextProbe = now.Add(b.probeInterval)
+			return breakerProbeFailed
+		}
+		b.open = false
+		b.results = nil
+		return breakerClosed
+	}
+
+	b.results = append(b.results, breakerResult{time: now, failed: failed})
+	cutoff := now.Add(-b.window)
+	i := 0
+	for i < len(b.results) && b.results[i].time.Before(cutoff) {
+		i++
+	}
+	b.results = b.results[i:]
+
+	if len(b.results) < b.minRequests {
+		return breakerUnchanged
+	}
+	failures := 0
+	for _, result := range b.results {
+		if result.failed {
+			failures++
+		}
+	}
+	if float64(failures)/float64(len(b.results)) <= b.errorRate {
+		return breakerUnchanged
+	}
+
+	b.open = true
+	b.nextProbe = now.Add(b.probeInterval)
+	return breakerOpened
+}
+
+// apiFailure reports whether an error hints at a degraded api server rather
+// than an object that is already gone or changed.
+func apiFailure(err error) bool {
+	return err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err)
+}
+
+// errCircuitOpen is returned for delete calls the open circuit breaker holds
+// back.
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// deleteCall makes a delete call unless the circuit breaker holds it back and
+// feeds its result into the breaker.
+func (c *cleaner) deleteCall(ctx context.Context, call func() error) error {
+	if !c.breaker.tryProbe() {
+		return errCircuitOpen
+	}
+	err := call()
+	c.observeAPI(ctx, err)
+	return err
+}
+
+// observeAPI feeds the result of a delete call into the circuit breaker.
+func (c *cleaner) observeAPI(ctx context.Context, err error) {
+	switch c.breaker.observe(apiFailure(err)) {
+	case breakerOpened:
+		logf(ctx, "circuit breaker opened after too many failed delete calls: %v\n", err)
+		circuitBreakerOpen.Set(1)
+		circuitBreakerTrips.Inc()
+		c.controllerEvent(ctx, corev1.EventTypeWarning, "CircuitBreakerOpen", "pausing cleanup after too many failed delete calls, probing every %s", c.breaker.probeInterval)
+		time.AfterFunc(c.breaker.probeInterval, c.triggerReconcile)
+	case breakerProbeFailed:
+		logf(ctx, "circuit breaker probe failed: %v\n", err)
+		time.AfterFunc(c.breaker.probeInterval, c.triggerReconcile)
+	case breakerClosed:
+		logf(ctx, "circuit breaker closed\n")
+		circuitBreakerOpen.Set(0)
+		c.controllerEvent(ctx, corev1.EventTypeNormal, "CircuitBreakerClosed", "delete calls succeed again, resuming cleanup")
+		c.triggerReconcile()
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCircuitBreakerObserve(t *testing.T) {
+	tests := []struct {
+		name    string
+		breaker *circuitBreaker
+		// results are the failures of the delete calls observed in order.
+		results []bool
+		want    []breakerTransition
+		open    bool
+	}{
+		{
+			name:    "disabled",
+			breaker: &circuitBreaker{errorRate: 0, window: time.Minute, minRequests: 1, probeInterval: time.Hour},
+			results: []bool{true, true, true},
+			want:    []breakerTransition{breakerUnchanged, breakerUnchanged, breakerUnchanged},
+		},
+		{
+			name:    "too few requests",
+			breaker: &circuitBreaker{errorRate: 0.5, window: time.Minute, minRequests: 3, probeInterval: time.Hour},
+			results: []bool{true, true},
+			want:    []breakerTransition{breakerUnchanged, breakerUnchanged},
+		},
+		{
+			name:    "error rate at threshold",
+			breaker: &circuitBreaker{errorRate: 0.5, window: time.Minute, minRequests: 2, probeInterval: time.Hour},
+			results: []bool{false, true},
+			want:    []breakerTransition{breakerUnchanged, breakerUnchanged},
+		},
+		{
+			name:    "error rate above threshold",
+			breaker: &circuitBreaker{errorRate: 0.5, window: time.Minute, minRequests: 2, probeInterval: time.Hour},
+			results: []bool{false, true, true},
+			want:    []breakerTransition{breakerUnchanged, breakerUnchanged, breakerOpened},
+			open:    true,
+		},
+		{
+			name:    "results while open without a probe",
+			breaker: &circuitBreaker{errorRate: 0.5, window: time.Minute, minRequests: 1, probeInterval: time.Hour},
+			results: []bool{true, false, true},
+			want:    []breakerTransition{breakerOpened, breakerUnchanged, breakerUnchanged},
+			open:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, failed := range test.results {
+				if got := test.breaker.observe(failed); got != test.want[i] {
+					t.Errorf("result %d: got transition %d, want %d", i, got, test.want[i])
+				}
+			}
+			if got := test.breaker.isOpen(); got != test.open {
+				t.Errorf("got open %t, want %t", got, test.open)
+			}
+			if got := test.breaker.tryProbe(); got == test.open {
+				t.Errorf("got probe %t while open is %t", got, test.open)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		failed bool
+		want   breakerTransition
+		open   bool
+	}{
+		{name: "probe succeeded", failed: false, want: breakerClosed, open: false},
+		{name: "probe failed", failed: true, want: breakerProbeFailed, open: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := &circuitBreaker{errorRate: 0.5, window: time.Minute, minRequests: 1}
+			if got := b.observe(true); got != breakerOpened {
+				t.Fatalf("got transition %d, want the breaker opened", got)
+			}
+			if b.isOpen() {
+				t.Fatal("the breaker holds calls back while a probe is due")
+			}
+
+			if !b.tryProbe() {
+				t.Fatal("the due probe was not let through")
+			}
+			if b.tryProbe() {
+				t.Fatal("a second probe was let through while the first is running")
+			}
+			if !b.isOpen() {
+				t.Fatal("the breaker lets calls through while a probe is running")
+			}
+
+			// the next probe is due an interval after a failed one
+			b.probeInterval = time.Hour
+			if got := b.observe(test.failed); got != test.want {
+				t.Errorf("got transition %d, want %d", got, test.want)
+			}
+			if got := b.isOpen(); got != test.open {
+				t.Errorf("got open %t, want %t", got, test.open)
+			}
+		})
+	}
+}
+
+func TestDeleteCallCircuitOpen(t *testing.T) {
+	c := &cleaner{breaker: &circuitBreaker{errorRate: 0.5, window: time.Minute, minRequests: 1, probeInterval: time.Hour}}
+	c.breaker.observe(true)
+
+	called := false
+	err := c.deleteCall(context.Background(), func() error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, errCircuitOpen) {
+		t.Errorf("got error %v, want %v", err, errCircuitOpen)
+	}
+	if called {
+		t.Error("the open breaker let the delete call through")
+	}
+}
+
+func TestRemovePodsCircuitOpen(t *testing.T) {
+	tests := []struct {
+		name string
+		open bool
+		want error
+	}{
+		{name: "closed", open: false, want: nil},
+		{name: "open", open: true, want: errCircuitOpen},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db-0"}}
+			c := &cleaner{
+				clientset: fake.NewSimpleClientset(pod),
+				podAction: podActionDelete,
+				breaker:   &circuitBreaker{errorRate: 0.5, window: time.Minute, minRequests: 1, probeInterval: time.Hour},
+			}
+			if test.open {
+				c.breaker.observe(true)
+			}
+
+			err := c.removePods(context.Background(), []*corev1.Pod{pod})
+			if !errors.Is(err, test.want) {
+				t.Errorf("got error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+)
+
+// cacheSizeSample is the number of objects of a cache whose serialized size
+// is measured to estimate the memory of the whole cache.
+const cacheSizeSample = 100
+
+var (
+	cacheObjectsDesc = prometheus.NewDesc(
+		"local_pvc_cleaner_cache_objects",
+		"Number of objects in an informer cache.",
+		[]string{"kind"}, nil,
+	)
+	cacheBytesDesc = prometheus.NewDesc(
+		"local_pvc_cleaner_cache_estimated_bytes",
+		"Estimated serialized size of the objects in an informer cache, extrapolated from a sample.",
+		[]string{"kind"}, nil,
+	)
+	indexValuesDesc = prometheus.NewDesc(
+		"local_pvc_cleaner_index_values",
+		"Number of distinct values of an informer index.",
+		[]string{"kind", "index"}, nil,
+	)
+	indexEntriesDesc = prometheus.NewDesc(
+		"local_pvc_cleaner_index_entries",
+		"Number of objects referenced by the values of an informer index.",
+		[]string{"kind", "index"}, nil,
+	)
+)
+
+// sizer is implemented by the generated protobuf types of the api objects.
+type sizer interface {
+	Size() int
+}
+
+// cacheCollector reports the sizes of the informer caches and their indexes
+// when scraped.
+type cacheCollector struct {
+	indexers map[string]cache.Indexer
+}
+
+// registerCacheMetrics registers a collector of the caches of the informers
+// the cleaner uses. It must be called once all of them are registered.
+func (c *cleaner) registerCacheMetrics() {
+	indexers := map[string]cache.Indexer{
+		"node": c.factory.Core().V1().Nodes().Informer().GetIndexer(),
+		"pvc":  c.factory.Core().V1().PersistentVolumeClaims().Informer().GetIndexer(),
+		"pv":   c.factory.Core().V1().PersistentVolumes().Informer().GetIndexer(),
+	}
+	if c.podFactory != nil {
+		indexers["pod"] = c.podFactory.Core().V1().Pods().Informer().GetIndexer()
+	}
+	if c.deleteVolumeAttachments {
+		indexers["volumeattachment"] = c.factory.Storage().V1().VolumeAttachments().Informer().GetIndexer()
+	}
+	if c.workloadProtection {
+		indexers["statefulset"] = c.factory.Apps().V1().StatefulSets().Informer().GetIndexer()
+		indexers["deployment"] = c.factory.Apps().V1().Deployments().Informer().GetIndexer()
+	}
+	prometheus.MustRegister(&cacheCollector{indexers: indexers})
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheObjectsDesc
+	ch <- cacheBytesDesc
+	ch <- indexValuesDesc
+	ch <- indexEntriesDesc
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	for kind, indexer := range c.indexers {
+		keys := indexer.ListKeys()
+		ch <- prometheus.MustNewConstMetric(cacheObjectsDesc, prometheus.GaugeValue, float64(len(keys)), kind)
+
+		sampled, size := 0, 0
+		for _, key := range keys {
+			if sampled == cacheSizeSample {
+				break
+			}
+			obj, exists, err := indexer.GetByKey(key)
+			if err != nil || !exists {
+				continue
+			}
+			if s, ok := obj.(sizer); ok {
+				size += s.Size()
+				sampled++
+			}
+		}
+		estimate := 0.0
+		if sampled > 0 {
+			estimate = float64(size) / float64(sampled) * float64(len(keys))
+		}
+		ch <- prometheus.MustNewConstMetric(cacheBytesDesc, prometheus.GaugeValue, estimate, kind)
+
+		for index := range indexer.GetIndexers() {
+			values := indexer.ListIndexFuncValues(index)
+			entries := 0
+			for _, value := range values {
+				keys, err := indexer.IndexKeys(index, value)
+				if err == nil {
+					entries += len(keys)
+				}
+			}
+			ch <- prometheus.MustNewConstMetric(indexValuesDesc, prometheus.GaugeValue, float64(len(values)), kind, index)
+			ch <- prometheus.MustNewConstMetric(indexEntriesDesc, prometheus.GaugeValue, float64(entries), kind, index)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// cancelledNodesKey in the controller configmap lists the nodes whose cleanup
+// is cancelled, so cancellations survive restarts.
+const cancelledNodesKey = "cancelled-nodes"
+
+// cancelledNodes are missing nodes an operator expects back with their data,
+// whose claims are kept until the node is back.
+type cancelledNodes struct {
+	mu    sync.Mutex
+	nodes map[string]bool
+	// saveMu orders the saves of the list, so the last one saved is the
+	// current list.
+	saveMu sync.Mutex
+}
+
+func newCancelledNodes() *cancelledNodes {
+	return &cancelledNodes{nodes: map[string]bool{}}
+}
+
+func (n *cancelledNodes) add(nodeName string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nodes[nodeName] = true
+}
+
+// remove forgets a node and reports whether its cleanup was cancelled.
+func (n *cancelledNodes) remove(nodeName string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ok := n.nodes[nodeName]
+	delete(n.nodes, nodeName)
+	return ok
+}
+
+// list returns the cancelled nodes sorted by name.
+func (n *cancelledNodes) list() stringList {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	nodes := make(stringList, 0, len(n.nodes))
+	for nodeName := range n.nodes {
+		nodes = append(nodes, nodeName)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// any reports whether the cleanup of one of the given nodes was cancelled.
+func (n *cancelledNodes) any(nodeNames []string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, nodeName := range nodeNames {
+		if n.nodes[nodeName] {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelCleanup keeps the claims of a missing node until it is back, ending
+// the quarantine of the ones waiting out their grace period. The cancellation
+// is recorded before waiting for a running cleanup, which stops before its
+// next claim. It returns the number of claims it released.
+func (c *cleaner) cancelCleanup(ctx context.Context, nodeName string) (int, error) {
+	c.cancelled.add(nodeName)
+	err := c.saveCancelled(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("saving cancellation: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	candidates, err := c.candidatesByNode(ctx, nodeName)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, cand := range candidates {
+		if _, ok := cand.pvc.Annotations[orphanedAtAnnotation]; !ok {
+			continue
+		}
+		err := c.patchClaimAnnotations(ctx, cand.pvc, map[string]*string{orphanedAtAnnotation: nil})
+		if err != nil {
+			return released, fmt.Errorf("releasing pvc %s/%s: %w", cand.pvc.Namespace, cand.pvc.Name, err)
+		}
+		c.eventf(ctx, cand.pvc, corev1.EventTypeNormal, "CleanupCancelled", "cancelled by an operator, node %s is expected back", nodeName)
+		released++
+	}
+
+	logf(ctx, "cancelled cleanup of node(%s), released %d pvcs from quarantine\n", nodeName, released)
+	return released, nil
+}
+
+// loadCancelled restores the cancellations saved in the controller configmap.
+func (c *cleaner) loadCancelled(ctx context.Context) error {
+	if c.namespace == "" {
+		return nil
+	}
+
+	cm, err := c.controllerObject(ctx)
+	if err != nil {
+		return err
+	}
+
+	var nodes stringList
+	nodes.Set(cm.Data[cancelledNodesKey])
+	for _, nodeName := range nodes {
+		c.cancelled.add(nodeName)
+	}
+	if len(nodes) > 0 {
+		fmt.Printf("restored cancelled cleanup of node(s) %s\n", nodes.String())
+	}
+	return nil
+}
+
+// saveCancelled writes the cancelled nodes to the controller configmap. They
+// are only kept in memory without a controller namespace.
+func (c *cleaner) saveCancelled(ctx context.Context) error {
+	if c.namespace == "" {
+		return nil
+	}
+
+	c.cancelled.saveMu.Lock()
+	defer c.cancelled.saveMu.Unlock()
+
+	_, err := c.controllerObject(ctx)
+	if err != nil {
+		return err
+	}
+
+	nodes := c.cancelled.list()
+	patch, err := json.Marshal(map[string]any{
+		"data": map[string]string{cancelledNodesKey: nodes.String()},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.clientset.CoreV1().ConfigMaps(c.namespace).Patch(ctx, controllerConfigMapName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// handleCleanupAction cancels the pending cleanup of a node on
+// /v1/cleanups/{node}/cancel, or simulates its deletion on
+// /v1/cleanups/{node}/simulate.
+func (c *cleaner) handleCleanupAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/cleanups/"), "/")
+	if len(parts) != 2 || parts[0] == "" || (parts[1] != "cancel" && parts[1] != "simulate") {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if parts[1] == "simulate" {
+		c.handleSimulation(w, r, parts[0])
+		return
+	}
+
+	_, err := c.cancelCleanup(r.Context(), parts[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runCancel cancels the pending cleanup of a node on a running cleaner.
+func runCancel(args []string) {
+	flags := flag.NewFlagSet("cancel", flag.ExitOnError)
+	server := flags.String("server", "http://localhost:8080", "address of the cleaner api")
+	apiTokenFile := flags.String("api-token-file", "", "file containing the bearer token of the cleaner api")
+	node := flags.String("node", "", "missing node expected back with its data")
+	flags.Parse(args)
+
+	if *node == "" {
+		fmt.Fprintf(os.Stderr, "no node to cancel the cleanup of\n")
+		os.Exit(1)
+	}
+
+	callAPI(*server, *apiTokenFile, http.MethodPost, "/v1/cleanups/"+*node+"/cancel", nil, http.StatusNoContent)
+	fmt.Printf("cancelled cleanup of node(%s)\n", *node)
+}
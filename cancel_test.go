@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCancelledNodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		add     []string
+		remove  []string
+		nodes   []string
+		want    bool
+		removed bool
+	}{
+		{name: "none cancelled", nodes: []string{"node1"}, want: false},
+		{name: "cancelled", add: []string{"node1"}, nodes: []string{"node1"}, want: true},
+		{name: "one of the nodes cancelled", add: []string{"node2"}, nodes: []string{"node1", "node2"}, want: true},
+		{name: "other node cancelled", add: []string{"node2"}, nodes: []string{"node1"}, want: false},
+		{name: "node back", add: []string{"node1"}, remove: []string{"node1"}, nodes: []string{"node1"}, want: false, removed: true},
+		{name: "other node back", add: []string{"node1"}, remove: []string{"node2"}, nodes: []string{"node1"}, want: true, removed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := newCancelledNodes()
+			for _, nodeName := range test.add {
+				n.add(nodeName)
+			}
+			removed := false
+			for _, nodeName := range test.remove {
+				removed = n.remove(nodeName) || removed
+			}
+
+			if removed != test.removed {
+				t.Errorf("got removed %t, want %t", removed, test.removed)
+			}
+			if got := n.any(test.nodes); got != test.want {
+				t.Errorf("got cancelled %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCancelledNodesPersisted(t *testing.T) {
+	tests := []struct {
+		name   string
+		add    []string
+		remove []string
+		want   stringList
+	}{
+		{name: "none cancelled", want: stringList{}},
+		{name: "cancelled", add: []string{"node2", "node1"}, want: stringList{"node1", "node2"}},
+		{name: "node back", add: []string{"node1", "node2"}, remove: []string{"node1"}, want: stringList{"node2"}},
+		{name: "all nodes back", add: []string{"node1"}, remove: []string{"node1"}, want: stringList{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := fake.NewSimpleClientset()
+			c := &cleaner{clientset: client, namespace: "cleaner-system", cancelled: newCancelledNodes()}
+			for _, nodeName := range test.add {
+				c.cancelled.add(nodeName)
+				if err := c.saveCancelled(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, nodeName := range test.remove {
+				c.cancelled.remove(nodeName)
+				if err := c.saveCancelled(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// a restarted cleaner reads the cancellations back
+			restarted := &cleaner{clientset: client, namespace: "cleaner-system", cancelled: newCancelledNodes()}
+			if err := restarted.loadCancelled(ctx); err != nil {
+				t.Fatal(err)
+			}
+			got := restarted.cancelled.list()
+			if got.String() != test.want.String() {
+				t.Errorf("got cancelled nodes %q, want %q", got.String(), test.want.String())
+			}
+		})
+	}
+}
+
+func TestCancelledNodesWithoutNamespace(t *testing.T) {
+	c := &cleaner{cancelled: newCancelledNodes()}
+	c.cancelled.add("node1")
+	if err := c.saveCancelled(context.Background()); err != nil {
+		t.Errorf("saving without a controller namespace failed: %v", err)
+	}
+	if err := c.loadCancelled(context.Background()); err != nil {
+		t.Errorf("loading without a controller namespace failed: %v", err)
+	}
+	if !c.cancelled.any([]string{"node1"}) {
+		t.Error("the cancellation is not kept in memory")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// causes of a node deletion, classified from the last state of the node.
+const (
+	causeAutoscaler      = "autoscaler"
+	causeSpotTermination = "spot-termination"
+	causeClusterAPI      = "cluster-api"
+	causeManual          = "manual"
+	causeUnknown         = "unknown"
+)
+
+// clusterAPIMachineAnnotation links a node to the Cluster API machine backing
+// it.
+const clusterAPIMachineAnnotation = "cluster.x-k8s.io/machine"
+
+// karpenterDisruptionTaints are put by karpenter on nodes it removes.
+var karpenterDisruptionTaints = stringList{"karpenter.sh/disrupted", "karpenter.sh/disruption"}
+
+// deletionCause classifies why a node was deleted. Nodes removed by an
+// autoscaler carry its taint, nodes of reclaimed spot instances a termination
+// taint and nodes of Cluster API machines the machine annotation. A node that
+// was still ready without any of these was most likely deleted by hand, while
+// the cloud controller deleting a vanished instance is unknown.
+func deletionCause(node *corev1.Node, terminationTaints stringList) string {
+	switch {
+	case hasTaint(node, terminationTaints):
+		return causeSpotTermination
+	case hasTaint(node, stringList{toBeDeletedTaint}) || hasTaint(node, karpenterDisruptionTaints):
+		return causeAutoscaler
+	case node.Annotations[clusterAPIMachineAnnotation] != "":
+		return causeClusterAPI
+	}
+
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+			return causeManual
+		}
+	}
+	return causeUnknown
+}
+
+// expectedCause reports whether a deletion cause is regular churn rather than
+// a suspicious deletion.
+func expectedCause(cause string) bool {
+	return cause == causeAutoscaler || cause == causeSpotTermination || cause == causeClusterAPI
+}
+
+// nodeCauses returns the known deletion causes of the given nodes.
+func (c *cleaner) nodeCauses(nodeNames []string) string {
+	var causes []string
+	for _, nodeName := range nodeNames {
+		cause := c.stats.cause(nodeName)
+		if cause == "" {
+			cause = c.inventoryCause(nodeName)
+		}
+		causes = appendUnique(causes, cause)
+	}
+	return strings.Join(causes, ",")
+}
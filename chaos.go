@@ -0,0 +1,107 @@
+//go:build chaos
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// faultSettings are the faults injected into api requests. They are shared
+// by the injectors of every wrapped transport.
+type faultSettings struct {
+	mu sync.Mutex
+	// errorRate is the fraction of matching requests that fail.
+	errorRate float64
+	// status is the status of failed requests, zero to fail with a
+	// connection error instead.
+	status int
+	// latency delays every matching request.
+	latency time.Duration
+	// match restricts faults to requests whose method and path contain all
+	// of the given strings, like "DELETE" and "/persistentvolumes/".
+	match stringList
+}
+
+// fault returns the latency and whether a request fails, and with which
+// status.
+func (s *faultSettings) fault(req *http.Request) (time.Duration, bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, part := range s.match {
+		if !strings.Contains(req.Method+" "+req.URL.Path, part) {
+			return 0, false, 0
+		}
+	}
+	return s.latency, rand.Float64() < s.errorRate, s.status
+}
+
+// faultInjector fails and delays api requests so tests cover the retry,
+// backoff and partial failure paths of the cleaner. It is only built with the
+// chaos tag:
+//
+//	go build -tags chaos .
+type faultInjector struct {
+	next     http.RoundTripper
+	settings *faultSettings
+}
+
+var errInjected = errors.New("injected fault")
+
+func (f *faultInjector) RoundTrip(req *http.Request) (*http.Response, error) {
+	latency, failed, status := f.settings.fault(req)
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	if !failed {
+		return f.next.RoundTrip(req)
+	}
+	fmt.Printf("injecting fault into %s %s\n", req.Method, req.URL.Path)
+	if status == 0 {
+		return nil, errInjected
+	}
+
+	body := fmt.Sprintf(`{"kind":"Status","apiVersion":"v1","status":"Failure","message":"injected fault","code":%d}`, status)
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func init() {
+	settings := &faultSettings{}
+	flag.Float64Var(&settings.errorRate, "chaos-error-rate", 0, "fraction of matching api requests that fail")
+	flag.IntVar(&settings.status, "chaos-status", http.StatusInternalServerError, "http status of failed api requests, zero for connection errors")
+	flag.DurationVar(&settings.latency, "chaos-latency", 0, "delay added to every matching api request")
+	flag.Var(&settings.match, "chaos-match", "comma separated strings the method and path of a request must contain for faults to apply, like DELETE,/persistentvolumes/")
+
+	injectFaults = func(config *rest.Config) {
+		if settings.errorRate <= 0 && settings.latency <= 0 {
+			return
+		}
+		fmt.Printf("injecting faults into %.0f%% of api requests with a latency of %s\n", settings.errorRate*100, settings.latency)
+		// every config wrapped, like the ones of impersonated clients, gets
+		// its own injector in front of its own transport
+		config.Wrap(func(next http.RoundTripper) http.RoundTripper {
+			return &faultInjector{next: next, settings: settings}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+//go:build chaos
+
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// respond returns a transport answering every request with the given status.
+func respond(status int) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	})
+}
+
+func TestFaultInjector(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings *faultSettings
+		method   string
+		path     string
+		status   int
+		err      error
+	}{
+		{
+			name:     "no faults",
+			settings: &faultSettings{},
+			method:   http.MethodDelete,
+			path:     "/api/v1/persistentvolumes/pv1",
+			status:   http.StatusOK,
+		},
+		{
+			name:     "failed with status",
+			settings: &faultSettings{errorRate: 1, status: http.StatusServiceUnavailable},
+			method:   http.MethodDelete,
+			path:     "/api/v1/persistentvolumes/pv1",
+			status:   http.StatusServiceUnavailable,
+		},
+		{
+			name:     "failed with connection error",
+			settings: &faultSettings{errorRate: 1},
+			method:   http.MethodDelete,
+			path:     "/api/v1/persistentvolumes/pv1",
+			err:      errInjected,
+		},
+		{
+			name:     "matching request",
+			settings: &faultSettings{errorRate: 1, status: http.StatusInternalServerError, match: stringList{"DELETE", "/persistentvolumes/"}},
+			method:   http.MethodDelete,
+			path:     "/api/v1/persistentvolumes/pv1",
+			status:   http.StatusInternalServerError,
+		},
+		{
+			name:     "other method",
+			settings: &faultSettings{errorRate: 1, status: http.StatusInternalServerError, match: stringList{"DELETE", "/persistentvolumes/"}},
+			method:   http.MethodGet,
+			path:     "/api/v1/persistentvolumes/pv1",
+			status:   http.StatusOK,
+		},
+		{
+			name:     "other path",
+			settings: &faultSettings{errorRate: 1, status: http.StatusInternalServerError, match: stringList{"DELETE", "/persistentvolumes/"}},
+			method:   http.MethodDelete,
+			path:     "/api/v1/namespaces/default/pods/pod1",
+			status:   http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			injector := &faultInjector{next: respond(http.StatusOK), settings: test.settings}
+			req, err := http.NewRequest(test.method, "https://apiserver"+test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := injector.RoundTrip(req)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if resp.StatusCode != test.status {
+				t.Errorf("got status %d, want %d", resp.StatusCode, test.status)
+			}
+		})
+	}
+}
+
+// TestFaultInjectorPerTransport wraps two transports with the same settings,
+// which must each keep sending requests to their own transport.
+func TestFaultInjectorPerTransport(t *testing.T) {
+	settings := &faultSettings{}
+	first := &faultInjector{next: respond(http.StatusOK), settings: settings}
+	second := &faultInjector{next: respond(http.StatusAccepted), settings: settings}
+
+	for _, test := range []struct {
+		injector *faultInjector
+		status   int
+	}{
+		{first, http.StatusOK},
+		{second, http.StatusAccepted},
+		{first, http.StatusOK},
+	} {
+		req, err := http.NewRequest(http.MethodGet, "https://apiserver/api/v1/nodes", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := test.injector.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != test.status {
+			t.Errorf("got status %d, want %d", resp.StatusCode, test.status)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// violation is a broken invariant found by the check command.
+type violation struct {
+	Severity string `json:"severity"`
+	Object   string `json:"object"`
+	Message  string `json:"message"`
+}
+
+// invariantChecker validates the local volumes and claims of a cluster against
+// its nodes. It only reads from the api server.
+type invariantChecker struct {
+	c          *cleaner
+	nodes      map[string]bool
+	volumes    map[string]*corev1.PersistentVolume
+	violations []violation
+}
+
+func (k *invariantChecker) report(severity, object, format string, args ...any) {
+	k.violations = append(k.violations, violation{Severity: severity, Object: object, Message: fmt.Sprintf(format, args...)})
+}
+
+// pinnedNodes returns the nodes a local volume is pinned to, falling back to
+// its node affinity for volumes of provisioners the cleaner does not know.
+func (k *invariantChecker) pinnedNodes(pv *corev1.PersistentVolume) []string {
+	if nodes := k.c.volumeNodes(pv); len(nodes) > 0 {
+		return nodes
+	}
+	return indexedVolumeNodes(pv)
+}
+
+func (k *invariantChecker) missingNodes(nodes []string) []string {
+	var missing []string
+	for _, node := range nodes {
+		if !k.nodes[node] {
+			missing = append(missing, node)
+		}
+	}
+	return missing
+}
+
+// checkVolume validates that a local volume is pinned to nodes that exist.
+func (k *invariantChecker) checkVolume(pv *corev1.PersistentVolume) {
+	object := "pv/" + pv.Name
+	nodes := k.pinnedNodes(pv)
+	if len(nodes) == 0 {
+		k.report(severityWarning, object, "local volume is not pinned to a node")
+		return
+	}
+	if missing := k.missingNodes(nodes); len(missing) == len(nodes) {
+		k.report(severityError, object, "local volume is pinned to missing node(s) %v", missing)
+	}
+	if _, ok := pv.Annotations[deletedByAnnotation]; ok && pv.DeletionTimestamp == nil {
+		k.report(severityWarning, object, "volume carries the %s annotation but is not being deleted", deletedByAnnotation)
+	}
+}
+
+// checkClaim validates that a claim of a local volume is bound to a volume that
+// exists and is bound back to it, and that the annotations of the cleaner and
+// the scheduler on it agree with its nodes.
+func (k *invariantChecker) checkClaim(pvc *corev1.PersistentVolumeClaim) {
+	object := claimKey(pvc)
+	var nodes []string
+	if pvc.Spec.VolumeName != "" {
+		pv, ok := k.volumes[pvc.Spec.VolumeName]
+		if !ok && pvc.Status.Phase == corev1.ClaimBound {
+			k.report(severityError, object, "bound to missing pv(%s)", pvc.Spec.VolumeName)
+			return
+		}
+		if !ok {
+			return
+		}
+		if !k.c.localVolume(pv) {
+			return
+		}
+		if ref := pv.Spec.ClaimRef; ref == nil || ref.Namespace != pvc.Namespace || ref.Name != pvc.Name || (ref.UID != "" && ref.UID != pvc.UID) {
+			k.report(severityError, object, "pv(%s) is not bound back to the claim", pv.Name)
+		}
+		nodes = k.pinnedNodes(pv)
+		if selected := pvc.Annotations[selectedNodeAnnotation]; selected != "" && len(nodes) > 0 && !stringList(nodes).contains(selected) {
+			k.report(severityWarning, object, "selected node(%s) differs from the nodes %v of pv(%s)", selected, nodes, pv.Name)
+		}
+	} else if k.c.localProvisioner(pvc.Annotations[provisionerAnnotation]) {
+		if selected := pvc.Annotations[selectedNodeAnnotation]; selected != "" {
+			nodes = []string{selected}
+		}
+	} else {
+		return
+	}
+
+	gone := len(nodes) > 0 && len(k.missingNodes(nodes)) == len(nodes)
+	if value, ok := pvc.Annotations[orphanedAtAnnotation]; ok {
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			k.report(severityError, object, "invalid %s annotation %q", orphanedAtAnnotation, value)
+		} else if !gone {
+			k.report(severityWarning, object, "quarantined although node(s) %v exist", nodes)
+		}
+	}
+	if state, ok := pvc.Annotations[stateAnnotation]; ok && !knownState(state) {
+		k.report(severityWarning, object, "unknown %s annotation %q", stateAnnotation, state)
+	} else if ok && !gone {
+		k.report(severityWarning, object, "in state %s although node(s) %v exist", state, nodes)
+	}
+	if pvc.Labels[orphanLabel] == "true" && !gone {
+		k.report(severityWarning, object, "labeled %s although node(s) %v exist", orphanLabel, nodes)
+	}
+}
+
+// knownState reports whether a state annotation is one the cleaner exposes.
+func knownState(state string) bool {
+	for _, s := range pendingStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// check lists the nodes, volumes and claims of the cluster and returns the
+// violated invariants, errors first.
+func (k *invariantChecker) check(ctx context.Context) ([]violation, error) {
+	nodes, err := k.c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+	k.nodes = map[string]bool{}
+	for _, node := range nodes.Items {
+		k.nodes[node.Name] = true
+	}
+
+	pvs, err := k.c.clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pvs: %w", err)
+	}
+	k.volumes = map[string]*corev1.PersistentVolume{}
+	for i := range pvs.Items {
+		pv := &pvs.Items[i]
+		k.volumes[pv.Name] = pv
+		if k.c.localVolume(pv) {
+			k.checkVolume(pv)
+		}
+	}
+
+	pvcs, err := k.c.clientset.CoreV1().PersistentVolumeClaims("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pvcs: %w", err)
+	}
+	for i := range pvcs.Items {
+		k.checkClaim(&pvcs.Items[i])
+	}
+
+	sort.SliceStable(k.violations, func(i, j int) bool {
+		if k.violations[i].Severity != k.violations[j].Severity {
+			return k.violations[i].Severity == severityError
+		}
+		return k.violations[i].Object < k.violations[j].Object
+	})
+	return k.violations, nil
+}
+
+func printViolations(w io.Writer, violations []violation, output string) {
+	if output == "json" {
+		json.NewEncoder(w).Encode(map[string]any{"violations": violations})
+		return
+	}
+	for _, v := range violations {
+		fmt.Fprintf(w, "%-8s %s: %s\n", v.Severity, v.Object, v.Message)
+	}
+	fmt.Fprintf(w, "%d violations\n", len(violations))
+}
+
+// runCheck validates the invariants of the local volumes of a cluster without
+// changing anything, for scheduled health jobs. It exits with 1 when an
+// invariant of the failing severity is violated.
+func runCheck(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	var topologyKeys stringList
+	flags.Var(&topologyKeys, "topology-keys", "comma separated node topology keys of the CSI volumes of local provisioners")
+	failOn := flags.String("fail-on", severityError, "lowest severity that fails the check, error or warning")
+	output := flags.String("output", "text", "output format, text or json")
+	timeout := flags.Duration("timeout", time.Minute, "timeout of the check")
+	flags.Parse(args)
+
+	if *failOn != severityError && *failOn != severityWarning {
+		fmt.Fprintf(os.Stderr, "unknown severity %q\n", *failOn)
+		os.Exit(exitConfigError)
+	}
+	config, err := restConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load kubeconfig: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create client: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	k := &invariantChecker{c: &cleaner{clientset: clientset, topologyKeys: topologyKeys}}
+	violations, err := k.check(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check failed: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+	printViolations(os.Stdout, violations, *output)
+
+	for _, v := range violations {
+		if v.Severity == severityError || *failOn == severityWarning {
+			cancel()
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// nodeChurn counts the node deletions of a sliding window to lengthen the
+// grace period while the cluster replaces many nodes at once, like during an
+// upgrade rolling every node, when a missing node is most likely to be a false
+// positive.
+type nodeChurn struct {
+	window    time.Duration
+	threshold int
+	factor    float64
+
+	mu        sync.Mutex
+	deletions []time.Time
+	high      bool
+}
+
+func newNodeChurn(window time.Duration, threshold int, factor float64) *nodeChurn {
+	return &nodeChurn{window: window, threshold: threshold, factor: factor}
+}
+
+// prune drops the deletions that left the window and reports whether the churn
+// is high. It must be called with the lock held.
+func (n *nodeChurn) prune(now time.Time) bool {
+	i := 0
+	for i < len(n.deletions) && now.Sub(n.deletions[i]) >= n.window {
+		i++
+	}
+	n.deletions = n.deletions[i:]
+	return len(n.deletions) >= n.threshold
+}
+
+// observe records a node deletion and reports whether it started a period of
+// high churn.
+func (n *nodeChurn) observe() bool {
+	if n == nil {
+		return false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	n.deletions = append(n.deletions, now)
+	high := n.prune(now)
+	started := high && !n.high
+	n.high = high
+	n.report()
+	return started
+}
+
+// scale returns the grace period lengthened by the factor while the churn is
+// high. Claims without a grace period keep having none.
+func (n *nodeChurn) scale(grace time.Duration) time.Duration {
+	if n == nil || grace <= 0 {
+		return grace
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.high = n.prune(time.Now())
+	n.report()
+	if !n.high {
+		return grace
+	}
+	return time.Duration(float64(grace) * n.factor)
+}
+
+// report exposes the current factor. It must be called with the lock held.
+func (n *nodeChurn) report() {
+	nodeChurnDeletions.Set(float64(len(n.deletions)))
+	if n.high {
+		gracePeriodFactor.Set(n.factor)
+		return
+	}
+	gracePeriodFactor.Set(1)
+}
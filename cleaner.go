@@ -0,0 +1,796 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+type cleaner struct {
+	clientset            kubernetes.Interface
+	factory              informers.SharedInformerFactory
+	podFactory           informers.SharedInformerFactory
+	topologyKeys         stringList
+	nodeMappingConfigMap string
+	recreateClaims       bool
+	recreateTimeout      time.Duration
+	recoveryTimeout      time.Duration
+	decisions            *decisionLog
+	namespace            string
+	recorder             record.EventRecorder
+	apiToken             string
+	publicReadEndpoints  bool
+	podAction            string
+	namespaceClients     *namespaceClients
+	veto                 *vetoWebhook
+	order                string
+	deletionMetrics      *deletionMetrics
+	stats                *cleanupStats
+	cleanupSLO           time.Duration
+	gracePeriod          time.Duration
+	failures             *failureTracker
+
+	breaker *circuitBreaker
+	mode    string
+
+	deletePVCs              bool
+	deletePVs               bool
+	deletePods              bool
+	forceDeleteStuckPods    bool
+	deleteVolumeAttachments bool
+	provisionerTimeout      time.Duration
+	reclaimMode             string
+	provisionerDeployment   string
+	stepTimeout             time.Duration
+	leaseFreshness          time.Duration
+	notifiers               []notifier
+	ownerLabel              string
+	ownerRoutes             map[string]notifier
+	tombstoneLimit          int
+
+	cleanMissingStorageClass bool
+	cleanLostClaims          bool
+	quarantineOnScaleDown    bool
+	prestageTerminations     bool
+	terminationTaints        stringList
+	workloadProtection       bool
+	minCleanupInterval       time.Duration
+	history                  *cleanupHistory
+	stuckThreshold           time.Duration
+	terminating              *terminatingObjects
+	redactor                 *redactor
+	abandoned                abandonedCleanups
+	neverSeenPolicy          string
+	minConfidence            int
+	autoCleanSelector        labels.Selector
+	activeConsumerApproval   bool
+	reportFile               string
+	cacheNamespaces          bool
+	artifactRetention        time.Duration
+	lastGC                   time.Time
+
+	// reloaded holds the configuration of the controller configmap.
+	reloaded atomic.Pointer[reloadedConfig]
+
+	// pausedFlag holds the pause state when there is no controller configmap.
+	pausedFlag atomic.Bool
+	// pausedScopesValue holds the paused scopes when there is no controller
+	// configmap, pausedScopesMu serializes their updates.
+	pausedScopesValue pauseScopes
+	pausedScopesMu    sync.Mutex
+	nodePools         *nodePools
+	expected          *expectedNodes
+	cancelled         *cancelledNodes
+	timers            *reconcileTimers
+	inventory         *nodeInventory
+	seen              *seenNodes
+	transactions      *transactionLog
+	health            *healthStatus
+	plan              *cleanupPlan
+	load              *apiServerLoad
+	churn             *nodeChurn
+	external          *externalOrphans
+	podBatches        *podBatches
+	// veleroRestores is the informer of velero restores, nil when they are
+	// not watched.
+	veleroRestores cache.SharedIndexInformer
+	// translator translates the node names of volumes and claims into the
+	// names of node objects. It is not reloadable, the indexes depend on it.
+	translator     nodeTranslator
+	virtualCluster bool
+	// capacityHints is the number of nodes preferred for the replacement of a
+	// cleaned up stateful set claim, 0 to not hint any.
+	capacityHints     int
+	nodeLocalCapacity resource.Quantity
+	usage             *volumeUsage
+	batchDeletes      bool
+	cloneSourcePolicy string
+	notified          *notificationLog
+
+	protectedNamespaces      stringList
+	cleanProtectedNamespaces bool
+
+	// mu serializes node cleanups and reconciles so they do not race on the
+	// same claims.
+	mu          sync.Mutex
+	reconcileCh chan struct{}
+}
+
+// candidate is a claim whose volume lives on the given nodes.
+type candidate struct {
+	pvc   *corev1.PersistentVolumeClaim
+	nodes []string
+	// storageClassMissing is set for claims only considered because their
+	// storage class is gone.
+	storageClassMissing bool
+	// lost is set for claims in the Lost phase, whose volume is gone.
+	lost bool
+}
+
+// cleanupOrphan migrates a claim whose node has a declared replacement and
+// deletes it otherwise.
+func (c *cleaner) cleanupOrphan(ctx context.Context, cand candidate, mapping map[string]string) (decision, error) {
+	for _, nodeName := range cand.nodes {
+		newNode := mapping[nodeName]
+		if newNode == "" {
+			tracef("pvc(%s/%s) node(%s) has no mapping\n", cand.pvc.Namespace, cand.pvc.Name, nodeName)
+			continue
+		}
+
+		err := c.migrateVolumes(ctx, cand.pvc, nodeName, newNode)
+		if err != nil {
+			return decisionFailed, err
+		}
+		c.completeStep(ctx, cand.pvc, stepMigrate)
+		return decisionMigrated, nil
+	}
+
+	err := c.deleteVolumes(ctx, cand.pvc, cand.nodes)
+	if err != nil {
+		return decisionFailed, err
+	}
+	if c.tombstoneLimit > 0 && c.deletePVCs {
+		err = c.recordTombstone(ctx, cand.pvc, cand.nodes)
+		if err != nil {
+			logf(ctx, "failed to record tombstone of pvc(%s): %v\n", cand.pvc.Name, err)
+		}
+	}
+	return decisionDeleted, nil
+}
+
+// deleteVolumes tears down a claim after the given nodes are gone: it removes
+// the pods consuming it, deletes the claim, then its volume and the volume
+// attachments, waiting for each step to finish before starting the next one
+// and skipping the kinds that are disabled. It returns the error of the first
+// step that did not complete.
+func (c *cleaner) deleteVolumes(ctx context.Context, pvc *corev1.PersistentVolumeClaim, nodes []string) error {
+	start := time.Now()
+	client, err := c.clientFor(pvc.Namespace)
+	if err != nil {
+		logf(ctx, "failed to get client for namespace(%s): %v\n", pvc.Namespace, err)
+		return err
+	}
+
+	pods, err := c.consumerPods(pvc)
+	if err != nil {
+		logf(ctx, "error getting pods from index: %v\n", err)
+		return err
+	}
+
+	// the pod of an ephemeral claim is removed even without deleting pods,
+	// otherwise it keeps its claim from being garbage collected
+	ephemeral := ephemeralClaim(pvc)
+	if c.forceDeleteStuckPods {
+		c.releaseProtection(ctx, pvc, pods, nodes)
+	}
+	if c.deletePods || ephemeral {
+		err = c.removePods(ctx, pods)
+		if err != nil {
+			return err
+		}
+		for _, pod := range pods {
+			err = c.waitDeleted(ctx, "pod("+pod.Name+")", pod.UID, podWatch(client, pod.Namespace, pod.Name))
+			if err != nil {
+				logf(ctx, "%v\n", err)
+				return err
+			}
+		}
+		c.completeStep(ctx, pvc, stepPods)
+	}
+
+	if c.deletePVCs {
+		if !batchDeleted(ctx, pvc.UID) {
+			// a conflict means the claim was replaced by one with another uid
+			err = c.deleteCall(ctx, func() error {
+				return client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Delete(ctx, pvc.Name, metav1.DeleteOptions{Preconditions: metav1.NewUIDPreconditions(string(pvc.UID))})
+			})
+			if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+				logf(ctx, "failed to delete pvc(%s): %v\n", pvc.Name, err)
+				return err
+			}
+		}
+
+		err = c.waitDeleted(ctx, "pvc("+pvc.Name+")", pvc.UID, claimWatch(client, pvc.Namespace, pvc.Name))
+		if err != nil {
+			logf(ctx, "%v\n", err)
+			return err
+		}
+		logf(ctx, "deleted pvc(%s)\n", pvc.Name)
+		c.completeStep(ctx, pvc, stepPVC)
+	}
+
+	pvName := pvc.Spec.VolumeName
+	if pvName == "" {
+		logf(ctx, "pvc(%s) is not bound to a volume\n", pvc.Name)
+	}
+
+	if c.deletePVs && pvName != "" {
+		err = c.recordProvenance(ctx, pvName, reasonNodeDeleted, nodes)
+		if err != nil {
+			logf(ctx, "failed to record provenance on pv(%s): %v\n", pvName, err)
+		}
+
+		if !c.waitForReclaim(ctx, pvName) {
+			err = c.deleteCall(ctx, func() error {
+				return c.clientset.CoreV1().PersistentVolumes().Delete(ctx, pvName, metav1.DeleteOptions{})
+			})
+			if err != nil && !apierrors.IsNotFound(err) {
+				logf(ctx, "failed to delete pv(%s): %v\n", pvName, err)
+				return err
+			}
+
+			err = c.waitDeleted(ctx, "pv("+pvName+")", "", volumeWatch(c.clientset, pvName))
+			if err != nil {
+				logf(ctx, "%v\n", err)
+				return err
+			}
+			logf(ctx, "deleted pv(%s)\n", pvName)
+		}
+		c.completeStep(ctx, pvc, stepPV)
+	}
+
+	if c.deleteVolumeAttachments && pvName != "" {
+		c.deleteAttachments(ctx, pvName, nodes)
+		c.completeStep(ctx, pvc, stepAttachments)
+	}
+
+	if c.deletePVCs && c.recreateClaims && !ephemeral && statefulSetClaim(pvc, pods) {
+		c.recreateClaim(ctx, pvc)
+	}
+	if c.deletePVCs && c.capacityHints > 0 && !ephemeral && statefulSetClaim(pvc, pods) {
+		c.hintPlacement(ctx, pvc, nodes)
+	}
+	if c.deletePods && c.deletePVCs && !ephemeral {
+		c.trackRecovery(ctx, pvc, pods, start)
+	}
+
+	return nil
+}
+
+// boundClaim returns the claim a volume is bound to from the informer cache.
+func (c *cleaner) boundClaim(pv *corev1.PersistentVolume) (*corev1.PersistentVolumeClaim, error) {
+	if pv.Spec.ClaimRef == nil {
+		return nil, nil
+	}
+
+	pvc, err := c.factory.Core().V1().PersistentVolumeClaims().Lister().PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(pv.Spec.ClaimRef.Name)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if pv.Spec.ClaimRef.UID != "" && pv.Spec.ClaimRef.UID != pvc.UID {
+		return nil, nil
+	}
+
+	return pvc, nil
+}
+
+// nodeExcluded reports whether a node opted out of cleanup through the exclude
+// label or annotation.
+func nodeExcluded(node *corev1.Node) bool {
+	return node.Labels[excludeNodeKey] == "true" || node.Annotations[excludeNodeKey] == "true"
+}
+
+func (c *cleaner) handleNodeDelete(ctx context.Context, obj any) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			logf(ctx, "unexpected object in node delete: %T\n", obj)
+			return
+		}
+		node, ok = tombstone.Obj.(*corev1.Node)
+		if !ok {
+			logf(ctx, "unexpected object in node tombstone: %T\n", tombstone.Obj)
+			return
+		}
+	}
+
+	ctx = withCorrelationID(ctx, newCorrelationID())
+	observed := time.Now()
+	cause := deletionCause(node, c.terminationTaints)
+	logf(ctx, "node deleted: %s cause(%s)\n", node.Name, cause)
+	c.stats.nodeDeleted(node.Name, cause)
+	nodeDeletionsTotal.WithLabelValues(cause).Inc()
+	if c.churn.observe() {
+		logf(ctx, "node churn is high, lengthening grace periods by %gx\n", c.churn.factor)
+		c.controllerEvent(ctx, corev1.EventTypeWarning, "NodeChurnHigh", "%d nodes were deleted within %s, lengthening grace periods by %gx", c.churn.threshold, c.churn.window, c.churn.factor)
+	}
+	eventType := corev1.EventTypeNormal
+	if !expectedCause(cause) {
+		eventType = corev1.EventTypeWarning
+	}
+	c.controllerEvent(ctx, eventType, "NodeDeleted", "node %s was deleted, cause: %s", node.Name, cause)
+	if nodeExcluded(node) {
+		logf(ctx, "node(%s) is excluded from cleanup\n", node.Name)
+		candidates, err := c.candidatesByNode(ctx, node.Name)
+		if err != nil {
+			logf(ctx, "error getting candidates of node(%s): %v\n", node.Name, err)
+			return
+		}
+		for _, cand := range candidates {
+			c.record(ctx, cand, decisionSkippedNodeExcluded)
+		}
+		return
+	}
+
+	c.cleanupVolumesByNode(ctx, node.Name)
+
+	duration := time.Since(observed)
+	nodeCleanupDuration.(prometheus.ExemplarObserver).ObserveWithExemplar(duration.Seconds(), exemplar(ctx))
+	if c.cleanupSLO > 0 && duration > c.cleanupSLO {
+		nodeCleanupSLOBreaches.Inc()
+		logf(ctx, "warning: cleanup of node(%s) took %s, exceeding the slo of %s\n", node.Name, duration, c.cleanupSLO)
+	}
+}
+
+// candidatesByNode returns the claims whose volumes live on the given node.
+// The node affinity of bound volumes is authoritative, the selected node
+// annotation is only used for claims without such a volume.
+func (c *cleaner) candidatesByNode(ctx context.Context, nodeName string) ([]candidate, error) {
+	var candidates []candidate
+	seen := map[types.UID]bool{}
+
+	persistentVolumes, err := c.factory.Core().V1().PersistentVolumes().Informer().GetIndexer().ByIndex(pvByNodeIndex, nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("getting pv from index: %w", err)
+	}
+	for _, pvAny := range persistentVolumes {
+		pv := pvAny.(*corev1.PersistentVolume)
+		nodes := c.volumeNodes(pv)
+		if !stringList(nodes).contains(nodeName) {
+			continue
+		}
+
+		pvc, err := c.boundClaim(pv)
+		if err != nil {
+			logf(ctx, "failed to get pvc bound to pv(%s): %v\n", pv.Name, err)
+			continue
+		}
+
+		if pvc == nil {
+			tracef("pv(%s) on node(%s) is not bound to a pvc\n", pv.Name, nodeName)
+			continue
+		}
+		if seen[pvc.UID] {
+			continue
+		}
+		tracef("pvc(%s/%s) bound to pv(%s) on nodes(%s)\n", pvc.Namespace, pvc.Name, pv.Name, strings.Join(nodes, ","))
+		seen[pvc.UID] = true
+		candidates = append(candidates, candidate{pvc: pvc, nodes: nodes})
+	}
+
+	persistentVolumeClaims, err := c.factory.Core().V1().PersistentVolumeClaims().Informer().GetIndexer().ByIndex(pvcByNodeIndex, nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("getting pvc from index: %w", err)
+	}
+	for _, pvcAny := range persistentVolumeClaims {
+		pvc := pvcAny.(*corev1.PersistentVolumeClaim)
+		if seen[pvc.UID] || !c.localProvisioner(pvc.Annotations[provisionerAnnotation]) {
+			continue
+		}
+		nodes := c.claimNodes(pvc)
+		if !stringList(nodes).contains(nodeName) {
+			tracef("pvc(%s/%s) selected node(%s) but its pv is on nodes(%s)\n", pvc.Namespace, pvc.Name, nodeName, strings.Join(nodes, ","))
+			continue
+		}
+		tracef("pvc(%s/%s) selected node(%s)\n", pvc.Namespace, pvc.Name, nodeName)
+		seen[pvc.UID] = true
+		candidates = append(candidates, candidate{pvc: pvc, nodes: nodes, lost: lostClaim(pvc)})
+	}
+
+	return candidates, nil
+}
+
+// allCandidates returns every claim whose volume is pinned to a node, looking
+// at volumes first like candidatesByNode.
+func (c *cleaner) allCandidates(ctx context.Context) ([]candidate, error) {
+	var candidates []candidate
+	seen := map[types.UID]bool{}
+
+	pvs, err := c.factory.Core().V1().PersistentVolumes().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("listing pvs: %w", err)
+	}
+	for _, pv := range pvs {
+		nodes := c.volumeNodes(pv)
+		if len(nodes) == 0 {
+			tracef("pv(%s) has no node topology\n", pv.Name)
+			continue
+		}
+
+		pvc, err := c.boundClaim(pv)
+		if err != nil {
+			logf(ctx, "failed to get pvc bound to pv(%s): %v\n", pv.Name, err)
+			continue
+		}
+
+		if pvc == nil {
+			tracef("pv(%s) on nodes(%s) is not bound to a pvc\n", pv.Name, strings.Join(nodes, ","))
+			continue
+		}
+		if seen[pvc.UID] {
+			continue
+		}
+		tracef("pvc(%s/%s) bound to pv(%s) on nodes(%s)\n", pvc.Namespace, pvc.Name, pv.Name, strings.Join(nodes, ","))
+		seen[pvc.UID] = true
+		candidates = append(candidates, candidate{pvc: pvc, nodes: nodes})
+	}
+
+	pvcs, err := c.factory.Core().V1().PersistentVolumeClaims().Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("listing pvcs: %w", err)
+	}
+	for _, pvc := range pvcs {
+		if seen[pvc.UID] {
+			continue
+		}
+		storageClassMissing := false
+		if !c.localProvisioner(pvc.Annotations[provisionerAnnotation]) {
+			if !c.orphanedStorageClassClaim(pvc) {
+				tracef("pvc(%s/%s) provisioner(%s) does not match\n", pvc.Namespace, pvc.Name, pvc.Annotations[provisionerAnnotation])
+				continue
+			}
+			tracef("pvc(%s/%s) storage class(%s) does not exist\n", pvc.Namespace, pvc.Name, *pvc.Spec.StorageClassName)
+			storageClassMissing = true
+		}
+
+		nodes := c.claimNodes(pvc)
+		tracef("pvc(%s/%s) on nodes(%s)\n", pvc.Namespace, pvc.Name, strings.Join(nodes, ","))
+		seen[pvc.UID] = true
+		candidates = append(candidates, candidate{pvc: pvc, nodes: nodes, storageClassMissing: storageClassMissing, lost: lostClaim(pvc)})
+	}
+
+	return candidates, nil
+}
+
+// claimNodes returns the nodes the volume of a local path claim is pinned to,
+// falling back to the selected node annotation, which backup and restore
+// tooling sometimes strips or leaves stale, when there is no such volume. It
+// returns nil when neither names a node.
+func (c *cleaner) claimNodes(pvc *corev1.PersistentVolumeClaim) []string {
+	if pvc.Spec.VolumeName != "" {
+		pv, err := c.factory.Core().V1().PersistentVolumes().Lister().Get(pvc.Spec.VolumeName)
+		if err != nil {
+			tracef("failed to get pv(%s) of pvc(%s/%s): %v\n", pvc.Spec.VolumeName, pvc.Namespace, pvc.Name, err)
+		} else if nodes := c.volumeNodes(pv); len(nodes) > 0 {
+			return nodes
+		}
+	}
+
+	if nodeName := pvc.Annotations[selectedNodeAnnotation]; nodeName != "" {
+		return []string{c.translateNode(nodeName)}
+	}
+	return nil
+}
+
+func (c *cleaner) nodeExists(ctx context.Context, nodeName string) (bool, error) {
+	if simulatedNode(ctx) == nodeName {
+		return false, nil
+	}
+	_, exists, err := c.factory.Core().V1().Nodes().Informer().GetStore().GetByKey(nodeName)
+	return exists, err
+}
+
+// remainingNode returns the first of the given nodes that still exists, or an
+// empty string when all of them are gone.
+func (c *cleaner) remainingNode(ctx context.Context, nodeNames []string) (string, error) {
+	for _, nodeName := range nodeNames {
+		exists, err := c.nodeExists(ctx, nodeName)
+		if err != nil {
+			return "", err
+		}
+		tracef("node(%s) exists(%t)\n", nodeName, exists)
+		if exists {
+			return nodeName, nil
+		}
+	}
+	return "", nil
+}
+
+// skipDecision returns why a candidate must not be cleaned up, or an empty
+// decision when it is an orphan.
+func (c *cleaner) skipDecision(ctx context.Context, cand candidate) decision {
+	if c.replicatedClaim(cand.pvc) {
+		tracef("pvc(%s/%s) is backed by replicated storage\n", cand.pvc.Namespace, cand.pvc.Name)
+		if !simulated(ctx) {
+			replicatedVolumesSkipped.Inc()
+		}
+		return decisionSkippedReplicated
+	}
+	if d := c.volumeSourceDecision(ctx, cand); d != "" {
+		return d
+	}
+
+	if cand.storageClassMissing && !c.cleanMissingStorageClass {
+		logf(ctx, "pvc(%s/%s) references missing storage class(%s)\n", cand.pvc.Namespace, cand.pvc.Name, *cand.pvc.Spec.StorageClassName)
+		return decisionSkippedStorageClassMissing
+	}
+
+	if len(cand.nodes) == 0 && cand.lost {
+		return c.lostDecision(ctx, cand)
+	}
+	if len(cand.nodes) == 0 {
+		logf(ctx, "pvc(%s/%s) has no selected node nor a volume pinned to one\n", cand.pvc.Namespace, cand.pvc.Name)
+		return decisionSkippedUnclassifiable
+	}
+
+	remaining, err := c.remainingNode(ctx, cand.nodes)
+	if err != nil {
+		logf(ctx, "failed to get nodes(%s) from pvc(%s): %v\n", strings.Join(cand.nodes, ","), cand.pvc.Name, err)
+		return decisionFailed
+	}
+
+	if remaining != "" && cand.lost {
+		return c.lostDecision(ctx, cand)
+	}
+	if remaining != "" {
+		logf(ctx, "node(%s) does exist in store from pvc(%s)\n", remaining, cand.pvc.Name)
+		return decisionSkippedNodeExists
+	}
+
+	if c.namespaceTerminating(ctx, cand.pvc.Namespace) {
+		tracef("namespace(%s) of pvc(%s) is terminating\n", cand.pvc.Namespace, cand.pvc.Name)
+		return decisionSkippedTerminating
+	}
+	logf(ctx, "nodes(%s) do not exist in store from pvc(%s)\n", strings.Join(cand.nodes, ","), cand.pvc.Name)
+	if d := c.virtualNodesDecision(ctx, cand); d != "" {
+		return d
+	}
+	if d := c.neverSeenDecision(ctx, cand); d != "" {
+		return d
+	}
+	if d := c.leaseDecision(ctx, cand); d != "" {
+		return d
+	}
+	if d := c.policyDecision(ctx, cand); d != "" {
+		return d
+	}
+	return c.confidenceDecision(ctx, cand)
+}
+
+// evaluateAll decides what happens to each candidate and cleans up the orphans
+// among them, asking the veto webhook once per set of nodes.
+func (c *cleaner) evaluateAll(ctx context.Context, candidates []candidate) (summary, error) {
+	var sum summary
+	mapping, err := c.nodeMapping(ctx)
+	if err != nil {
+		return sum, fmt.Errorf("getting node mapping: %w", err)
+	}
+
+	c.sortCandidates(candidates)
+
+	scopes := c.pausedScopes(ctx)
+	var groups []string
+	orphans := map[string][]candidate{}
+	for _, cand := range candidates {
+		d := c.skipDecision(ctx, cand)
+		approved := false
+		if d == "" && c.reportOnly() {
+			d, approved = c.planDecision(ctx, cand)
+		}
+		if resolvedDecision(d) && !c.reportOnly() {
+			c.releaseQuarantine(ctx, cand, d)
+			c.withdrawExternal(ctx, cand)
+		}
+		if d == "" && c.cancelled.any(cand.nodes) {
+			d = decisionSkippedCancelled
+		}
+		if d == "" && !approved {
+			d = c.quarantineDecision(ctx, cand)
+		}
+		if d == "" {
+			d = c.selectorDecision(ctx, cand)
+		}
+		if d == "" && !approved {
+			d = c.consumerDecision(ctx, cand)
+		}
+		if d == "" {
+			d = c.backoffDecision(ctx, cand.pvc)
+		}
+		if d == "" && scopes.covers(cand, c.nodePools) {
+			d = decisionSkippedPaused
+		}
+		if d == "" {
+			if restore := c.restoringNamespace(cand.pvc.Namespace); restore != "" {
+				logf(ctx, "velero restore(%s) into namespace(%s) of pvc(%s) is in progress\n", restore, cand.pvc.Namespace, cand.pvc.Name)
+				d = decisionSkippedRestoring
+			}
+		}
+		if d == "" {
+			d = c.cloneSourceDecision(ctx, cand)
+		}
+		if d == "" {
+			d = c.flappingDecision(ctx, cand)
+		}
+		if d != "" {
+			c.record(ctx, cand, d)
+			sum.add(cand, d)
+			continue
+		}
+
+		key := strings.Join(cand.nodes, ",")
+		if _, ok := orphans[key]; !ok {
+			groups = append(groups, key)
+		}
+		orphans[key] = append(orphans[key], cand)
+	}
+
+	for _, key := range groups {
+		group := orphans[key]
+		var veto decision
+		if c.isPaused(ctx) {
+			veto = decisionSkippedPaused
+		} else if c.load.high() {
+			logf(ctx, "api server is shedding load, deferring cleanup of node(s) %s\n", key)
+			veto = decisionSkippedAPIServerLoad
+			c.load.deferCleanup(c.triggerReconcile)
+		} else {
+			veto = c.vetoDecision(ctx, group[0].nodes, group)
+		}
+		ctx := ctx
+		if veto == "" && !c.externalDeletion() {
+			ctx = c.beginTransaction(ctx, group[0].nodes, group, mapping)
+			ctx = c.deleteBatches(ctx, group, mapping)
+		}
+		for _, cand := range group {
+			d := veto
+			if d == "" && c.cancelled.any(cand.nodes) && !batchDeleted(ctx, cand.pvc.UID) {
+				d = decisionSkippedCancelled
+			}
+			if d == "" && c.breaker.isOpen() && !batchDeleted(ctx, cand.pvc.UID) {
+				d = decisionSkippedCircuitOpen
+			}
+			if d == "" && c.externalDeletion() {
+				d = c.offerExternal(ctx, cand)
+			}
+			var duration time.Duration
+			var err error
+			usedBytes := int64(-1)
+			if d == "" {
+				usedBytes = c.usedBytes(ctx, cand.pvc)
+				start := time.Now()
+				d, err = c.cleanupOrphan(ctx, cand, mapping)
+				duration = time.Since(start)
+				// a call held back by the breaker says nothing about the claim,
+				// so it is skipped without counting towards its blacklist
+				if errors.Is(err, errCircuitOpen) {
+					d, err = decisionSkippedCircuitOpen, nil
+				} else {
+					c.observeFailure(ctx, claimKey(cand.pvc), cand.pvc, err)
+				}
+				if (d == decisionDeleted || d == decisionMigrated) && c.minCleanupInterval > 0 {
+					c.history.observe(claimKey(cand.pvc), c.minCleanupInterval)
+				}
+			}
+			if d == decisionDeleted && usedBytes >= 0 {
+				logf(ctx, "discarded %d bytes of pvc(%s)\n", usedBytes, cand.pvc.Name)
+				discardedBytes.Add(float64(usedBytes))
+			}
+			c.record(ctx, cand, d)
+			sum.addCleanup(cand, d, duration, err, usedBytes)
+		}
+		c.endTransaction(ctx)
+	}
+	return sum, nil
+}
+
+func (c *cleaner) cleanupVolumesByNode(ctx context.Context, nodeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	candidates, err := c.candidatesByNode(ctx, nodeName)
+	if err != nil {
+		logf(ctx, "error getting candidates of node(%s): %v\n", nodeName, err)
+		return
+	}
+
+	sum, err := c.evaluateAll(ctx, candidates)
+	if err != nil {
+		logf(ctx, "failed to clean up node(%s): %v\n", nodeName, err)
+		return
+	}
+	c.notify(ctx, notificationNodeCleanup, []string{nodeName}, sum)
+}
+
+// reconcile cleans up volumes whose node no longer exists in the cluster and
+// returns what it did.
+func (c *cleaner) reconcile(ctx context.Context) (summary, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx = withCorrelationID(ctx, newCorrelationID())
+	start := time.Now()
+	c.resumeTransactions(ctx)
+	candidates, err := c.allCandidates(ctx)
+	if err != nil {
+		logf(ctx, "error getting candidates: %v\n", err)
+		c.controllerEvent(ctx, corev1.EventTypeWarning, "ReconcileFailed", "listing candidates: %v", err)
+		c.reportHealth(ctx, err)
+		c.writeReport(ctx, start, summary{}, err)
+		return summary{}, err
+	}
+	c.retainNodePools(candidates)
+	c.loadApprovals(ctx)
+
+	sum, err := c.evaluateAll(ctx, candidates)
+	if err != nil {
+		logf(ctx, "failed to reconcile: %v\n", err)
+		c.controllerEvent(ctx, corev1.EventTypeWarning, "ReconcileFailed", "%v", err)
+		c.reportHealth(ctx, err)
+		c.writeReport(ctx, start, sum, err)
+		return sum, err
+	}
+	if !c.isPaused(ctx) && !c.breaker.isOpen() && !c.externalDeletion() {
+		c.cleanupDanglingVolumes(ctx)
+	}
+	c.reportStuck(ctx)
+	c.publishPlan(ctx)
+	c.collectGarbage(ctx, candidates)
+	err = c.saveInventory(ctx)
+	if err != nil {
+		logf(ctx, "failed to save node inventory: %v\n", err)
+	}
+	duration := time.Since(start)
+
+	reconcileOrphansFound.Set(float64(sum.found))
+	reconcileOrphansCleaned.Set(float64(sum.cleaned))
+	reconcileOrphansSkipped.Set(float64(sum.skipped))
+	reconcileOrphansFailed.Set(float64(sum.failed))
+	reconcileUnclassifiable.Set(float64(sum.unclassifiable))
+	reconcileStorageClassMissing.Set(float64(sum.storageClassMissing))
+	reconcileLost.Set(float64(sum.lost))
+	reconcileNamespaceTerminating.Set(float64(sum.namespaceTerminating))
+	reconcileDuration.Set(duration.Seconds())
+	reconcileTimestamp.SetToCurrentTime()
+
+	logf(ctx, "reconciled %d pvcs: found %d orphans, cleaned %d, skipped %d, failed %d in %s\n", len(candidates), sum.found, sum.cleaned, sum.skipped, sum.failed, duration)
+	eventType := corev1.EventTypeNormal
+	if sum.failed > 0 {
+		eventType = corev1.EventTypeWarning
+	}
+	c.controllerEvent(ctx, eventType, "ReconcileComplete", "found %d orphans, cleaned %d, skipped %d, failed %d in %s", sum.found, sum.cleaned, sum.skipped, sum.failed, duration)
+	c.notify(ctx, notificationReconcile, nil, sum)
+	c.reportHealth(ctx, nil)
+	c.writeReport(ctx, start, sum, nil)
+	return sum, nil
+}
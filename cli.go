@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// callAPI sends a request to the api of a running cleaner for a command line
+// verb and returns the response body. It exits when the request does not
+// succeed with the expected status.
+func callAPI(server, apiTokenFile, method, path string, body []byte, status int) []byte {
+	req, err := http.NewRequest(method, strings.TrimSuffix(server, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiTokenFile != "" {
+		token, err := os.ReadFile(apiTokenFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read api token: %v\n", err)
+			os.Exit(1)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to call %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		fmt.Fprintf(os.Stderr, "failed to call %s: %s\n", path, resp.Status)
+		os.Exit(1)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read response of %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	return respBody
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// cloneSourceDefer keeps claims that are the data source of clones still
+	// being provisioned until the clones are bound.
+	cloneSourceDefer = "defer"
+	// cloneSourceWarn cleans them up and records a warning event.
+	cloneSourceWarn = "warn"
+)
+
+// claimDataSource returns the namespace/name of the claim a claim is cloned
+// from, or an empty string.
+func claimDataSource(pvc *corev1.PersistentVolumeClaim) string {
+	if ref := pvc.Spec.DataSourceRef; ref != nil && ref.Kind == "PersistentVolumeClaim" && (ref.APIGroup == nil || *ref.APIGroup == "") {
+		namespace := pvc.Namespace
+		if ref.Namespace != nil && *ref.Namespace != "" {
+			namespace = *ref.Namespace
+		}
+		return namespace + "/" + ref.Name
+	}
+	if ref := pvc.Spec.DataSource; ref != nil && ref.Kind == "PersistentVolumeClaim" && (ref.APIGroup == nil || *ref.APIGroup == "") {
+		return pvc.Namespace + "/" + ref.Name
+	}
+	return ""
+}
+
+// pendingClones returns the names of the claims cloned from an orphan that are
+// not bound yet. Clones waiting on the same missing nodes never finish and do
+// not count.
+func (c *cleaner) pendingClones(cand candidate) []string {
+	objs, err := c.factory.Core().V1().PersistentVolumeClaims().Informer().GetIndexer().ByIndex(pvcByDataSourceIndex, cand.pvc.Namespace+"/"+cand.pvc.Name)
+	if err != nil {
+		return nil
+	}
+
+	var clones []string
+	for _, obj := range objs {
+		clone := obj.(*corev1.PersistentVolumeClaim)
+		if clone.Status.Phase == corev1.ClaimBound || clone.DeletionTimestamp != nil {
+			continue
+		}
+		if node := clone.Annotations[selectedNodeAnnotation]; node != "" && stringList(cand.nodes).contains(c.translateNode(node)) {
+			continue
+		}
+		clones = append(clones, clone.Namespace+"/"+clone.Name)
+	}
+	return clones
+}
+
+// cloneSourceDecision defers cleaning up orphans that clones are still being
+// provisioned from, since deleting the source mid-clone fails the clones with
+// confusing CSI errors, or only warns about them with the warn policy.
+func (c *cleaner) cloneSourceDecision(ctx context.Context, cand candidate) decision {
+	clones := c.pendingClones(cand)
+	if len(clones) == 0 {
+		return ""
+	}
+
+	if c.cloneSourcePolicy == cloneSourceWarn {
+		c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "CloneSourceDeleted", "deleting the pvc while pvc(s) %s are still cloned from it", strings.Join(clones, ","))
+		return ""
+	}
+	logf(ctx, "pvc(%s/%s) is the data source of pending clones(%s)\n", cand.pvc.Namespace, cand.pvc.Name, strings.Join(clones, ","))
+	return decisionSkippedCloneSource
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// clusterUIDKey in the controller configmap holds the uid of the kube-system
+// namespace of the cluster the cleaner first ran against.
+const clusterUIDKey = "cluster-uid"
+
+// clusterUID identifies a cluster by the uid of its kube-system namespace,
+// which lives as long as the cluster.
+func (c *cleaner) clusterUID(ctx context.Context) (types.UID, error) {
+	ns, err := c.clientset.CoreV1().Namespaces().Get(ctx, "kube-system", metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return ns.UID, nil
+}
+
+// checkCluster records the cluster uid in the controller configmap on the
+// first run and afterwards fails when it changed, like when a kubeconfig or a
+// restored configmap points the cleaner at another cluster. It does nothing
+// when no controller namespace is configured.
+func (c *cleaner) checkCluster(ctx context.Context) error {
+	if c.namespace == "" {
+		return nil
+	}
+
+	uid, err := c.clusterUID(ctx)
+	if err != nil {
+		return fmt.Errorf("getting cluster uid: %w", err)
+	}
+	cm, err := c.controllerObject(ctx)
+	if err != nil {
+		return fmt.Errorf("getting controller configmap: %w", err)
+	}
+
+	recorded := types.UID(cm.Data[clusterUIDKey])
+	if recorded == uid {
+		return nil
+	}
+	if recorded != "" {
+		c.eventf(ctx, cm, corev1.EventTypeWarning, "ClusterMismatch", "cluster uid %s does not match the recorded uid %s, refusing to delete anything", uid, recorded)
+		return fmt.Errorf("cluster uid %s does not match the uid %s recorded in configmap %s/%s", uid, recorded, c.namespace, controllerConfigMapName)
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"data": map[string]string{clusterUIDKey: string(uid)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.clientset.CoreV1().ConfigMaps(c.namespace).Patch(ctx, controllerConfigMapName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("recording cluster uid: %w", err)
+	}
+
+	fmt.Printf("recorded cluster uid(%s)\n", uid)
+	return nil
+}
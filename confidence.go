@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// podByNodeIndex indexes pods by the node they are scheduled on, registered
+// only for confidence scoring.
+const podByNodeIndex = "podByNode"
+
+// clusterAPINamespaceAnnotation holds the namespace of the Cluster API
+// machine of a node.
+const clusterAPINamespaceAnnotation = "cluster.x-k8s.io/cluster-namespace"
+
+// defaultLeaseStaleness is how old a node lease must be to count as stale
+// when no lease freshness is configured.
+const defaultLeaseStaleness = 5 * time.Minute
+
+// signals that a node is really gone and their weight in the confidence
+// score, adding up to 100.
+const (
+	signalNodeAbsent      = "node-absent"
+	signalLeaseStale      = "lease-stale"
+	signalNoPodsReporting = "no-pods-reporting"
+	signalRemovalExpected = "removal-expected"
+	signalMachineGone     = "machine-gone"
+)
+
+var signalWeights = map[string]int{
+	signalNodeAbsent:      40,
+	signalLeaseStale:      20,
+	signalNoPodsReporting: 15,
+	signalRemovalExpected: 15,
+	signalMachineGone:     10,
+}
+
+// signalOrder is the order signals are logged in.
+var signalOrder = []string{signalNodeAbsent, signalLeaseStale, signalNoPodsReporting, signalRemovalExpected, signalMachineGone}
+
+// addConfidenceIndexers registers the pod by node index the pods reporting
+// signal is looked up with.
+func (c *cleaner) addConfidenceIndexers() {
+	if c.minConfidence <= 0 || c.podFactory == nil {
+		return
+	}
+	c.podFactory.Core().V1().Pods().Informer().AddIndexers(cache.Indexers{
+		podByNodeIndex: func(obj any) ([]string, error) {
+			pod := obj.(*corev1.Pod)
+			if pod.Spec.NodeName == "" {
+				return nil, nil
+			}
+			return []string{pod.Spec.NodeName}, nil
+		},
+	})
+}
+
+// nodeSignals returns whether each signal holds for a missing node. Signals
+// that cannot be checked are left out.
+func (c *cleaner) nodeSignals(ctx context.Context, nodeName string) map[string]bool {
+	signals := map[string]bool{signalNodeAbsent: true}
+
+	// virtual clusters have no node leases nor machines of their own
+	var lease *coordinationv1.Lease
+	err := errVirtualCluster
+	if !c.virtualCluster {
+		lease, err = c.clientset.CoordinationV1().Leases(nodeLeaseNamespace).Get(ctx, nodeName, metav1.GetOptions{})
+	}
+	switch {
+	case apierrors.IsNotFound(err):
+		signals[signalLeaseStale] = true
+	case err == nil:
+		staleness := c.leaseFreshness
+		if staleness <= 0 {
+			staleness = defaultLeaseStaleness
+		}
+		signals[signalLeaseStale] = lease.Spec.RenewTime == nil || time.Since(lease.Spec.RenewTime.Time) >= staleness
+	}
+
+	if c.podFactory != nil {
+		pods, err := c.podFactory.Core().V1().Pods().Informer().GetIndexer().ByIndex(podByNodeIndex, nodeName)
+		if err == nil {
+			reporting := false
+			for _, podAny := range pods {
+				pod := podAny.(*corev1.Pod)
+				for _, condition := range pod.Status.Conditions {
+					if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+						reporting = true
+					}
+				}
+			}
+			signals[signalNoPodsReporting] = !reporting
+		}
+	}
+
+	if cause := c.stats.cause(nodeName); cause != "" {
+		signals[signalRemovalExpected] = expectedCause(cause)
+	}
+
+	if machine := c.nodeMachine(nodeName); machine != "" && !c.virtualCluster {
+		namespace, name, _ := strings.Cut(machine, "/")
+		err := c.clientset.CoreV1().RESTClient().Get().
+			AbsPath("/apis/cluster.x-k8s.io/v1beta1/namespaces/" + namespace + "/machines/" + name).
+			Do(ctx).Error()
+		switch {
+		case apierrors.IsNotFound(err):
+			signals[signalMachineGone] = true
+		case err == nil:
+			signals[signalMachineGone] = false
+		}
+	}
+	return signals
+}
+
+// nodeMachine returns the namespace/name of the Cluster API machine of a node
+// from the inventory, or an empty string.
+func (c *cleaner) nodeMachine(nodeName string) string {
+	if c.inventory == nil {
+		return ""
+	}
+	c.inventory.mu.Lock()
+	defer c.inventory.mu.Unlock()
+	return c.inventory.nodes[nodeName].Machine
+}
+
+// confidenceDecision scores how sure the cleaner is that the nodes of an
+// orphan are gone from the signals of its least certain node, and keeps the
+// orphan when the score is below the minimum confidence.
+func (c *cleaner) confidenceDecision(ctx context.Context, cand candidate) decision {
+	if c.minConfidence <= 0 {
+		return ""
+	}
+
+	score := 100
+	var contributions []string
+	for _, nodeName := range cand.nodes {
+		signals := c.nodeSignals(ctx, nodeName)
+		nodeScore := 0
+		for _, signal := range signalOrder {
+			holds, checked := signals[signal]
+			switch {
+			case !checked:
+				contributions = append(contributions, fmt.Sprintf("%s:%s=unknown", nodeName, signal))
+			case holds:
+				nodeScore += signalWeights[signal]
+				contributions = append(contributions, fmt.Sprintf("%s:%s=+%d", nodeName, signal, signalWeights[signal]))
+			default:
+				contributions = append(contributions, fmt.Sprintf("%s:%s=0", nodeName, signal))
+			}
+		}
+		score = min(score, nodeScore)
+	}
+
+	logf(ctx, "pvc(%s/%s) confidence(%d) signals(%s)\n", cand.pvc.Namespace, cand.pvc.Name, score, strings.Join(contributions, ","))
+	if score >= c.minConfidence {
+		return ""
+	}
+	c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "LowConfidence", "node(s) %v are gone but the confidence %d is below %d: %s", cand.nodes, score, c.minConfidence, strings.Join(contributions, ", "))
+	return decisionSkippedLowConfidence
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// activeConsumers returns the running pods of a claim on nodes that exist,
+// which an orphan should not have: its node may be back under a new name or
+// its data was migrated.
+func (c *cleaner) activeConsumers(ctx context.Context, pvc *corev1.PersistentVolumeClaim) []string {
+	pods, err := c.consumerPods(pvc)
+	if err != nil {
+		return nil
+	}
+
+	var active []string
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodRunning || pod.Spec.NodeName == "" {
+			continue
+		}
+		if exists, err := c.nodeExists(ctx, pod.Spec.NodeName); err == nil && exists {
+			active = append(active, pod.Name)
+		}
+	}
+	return active
+}
+
+// consumerDecision quarantines orphans that still have running pods until an
+// operator approves their cleanup, since deleting them breaks a live workload.
+func (c *cleaner) consumerDecision(ctx context.Context, cand candidate) decision {
+	if !c.activeConsumerApproval {
+		return ""
+	}
+	if _, ok := cand.pvc.Annotations[approvedAnnotation]; ok {
+		return ""
+	}
+	active := c.activeConsumers(ctx, cand.pvc)
+	if len(active) == 0 {
+		return ""
+	}
+
+	logf(ctx, "pvc(%s) still has running pods %v\n", cand.pvc.Name, active)
+	if _, ok := cand.pvc.Annotations[orphanedAtAnnotation]; !ok {
+		orphanedAt := time.Now().UTC().Format(time.RFC3339)
+		err := c.patchClaimAnnotations(ctx, cand.pvc, map[string]*string{orphanedAtAnnotation: &orphanedAt})
+		if err != nil {
+			logf(ctx, "failed to quarantine pvc(%s): %v\n", cand.pvc.Name, err)
+			return decisionFailed
+		}
+		logf(ctx, "quarantined pvc(%s) with running pods\n", cand.pvc.Name)
+		c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "Quarantined", "node(s) %v are gone but pods %v are running, the pvc is deleted once approved", cand.nodes, active)
+	}
+	return decisionSkippedActiveConsumers
+}
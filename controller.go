@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const controllerConfigMapName = "local-pvc-cleaner"
+
+func newEventRecorder(clientset kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "local-pvc-cleaner"})
+}
+
+// controllerObject returns the configmap owned by the controller that events
+// about the controller itself are recorded on, creating it when missing. It
+// returns nil when no controller namespace is configured.
+func (c *cleaner) controllerObject(ctx context.Context) (*corev1.ConfigMap, error) {
+	if c.namespace == "" {
+		return nil, nil
+	}
+
+	cm, err := c.clientset.CoreV1().ConfigMaps(c.namespace).Get(ctx, controllerConfigMapName, metav1.GetOptions{})
+	if err == nil {
+		return cm, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	cm = &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      controllerConfigMapName,
+			Namespace: c.namespace,
+		},
+	}
+	cm, err = c.clientset.CoreV1().ConfigMaps(c.namespace).Create(ctx, cm, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("created controller configmap(%s/%s)\n", c.namespace, controllerConfigMapName)
+	return cm, nil
+}
+
+// controllerEvent records an event on the controller object.
+func (c *cleaner) controllerEvent(ctx context.Context, eventType, reason, messageFmt string, args ...any) {
+	cm, err := c.controllerObject(ctx)
+	if err != nil {
+		fmt.Printf("failed to get controller configmap: %v\n", err)
+		return
+	}
+	if cm == nil {
+		return
+	}
+
+	c.eventf(ctx, cm, eventType, reason, messageFmt, args...)
+}
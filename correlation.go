@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// correlationIDAnnotation groups the events and deleted volumes caused by one
+// node cleanup or reconcile.
+const correlationIDAnnotation = "local-pvc-cleaner.io/correlation-id"
+
+type correlationIDKey struct{}
+
+// newCorrelationID returns a random id for a cleanup operation.
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// withCorrelationID returns a context carrying the id of the cleanup operation
+// it belongs to.
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// correlationID returns the id of the cleanup operation of a context, or an
+// empty string outside of one.
+func correlationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// logf prints a log line prefixed with the correlation id of the context, and
+// marked when it belongs to a simulation.
+func logf(ctx context.Context, format string, args ...any) {
+	if simulated(ctx) {
+		format = "simulation " + format
+	}
+	if id := correlationID(ctx); id != "" {
+		format = "correlation(" + id + ") " + format
+	}
+	fmt.Printf(format, args...)
+}
+
+// eventf records an event annotated with the correlation id of the context.
+func (c *cleaner) eventf(ctx context.Context, obj runtime.Object, eventType, reason, messageFmt string, args ...any) {
+	if simulated(ctx) {
+		simulatedEvent(ctx, eventType, reason, fmt.Sprintf(messageFmt, args...))
+		return
+	}
+	id := correlationID(ctx)
+	if id == "" {
+		c.recorder.Eventf(obj, eventType, reason, messageFmt, args...)
+		return
+	}
+	c.recorder.AnnotatedEventf(obj, map[string]string{correlationIDAnnotation: id}, eventType, reason, messageFmt, args...)
+}
+
+// exemplar returns the exemplar labels of the context, or nil outside of a
+// cleanup operation.
+func exemplar(ctx context.Context) prometheus.Labels {
+	id := correlationID(ctx)
+	if id == "" {
+		return nil
+	}
+	return prometheus.Labels{"correlation_id": id}
+}
+
+// incWithExemplar increments a counter, attaching the correlation id of the
+// context as exemplar.
+func incWithExemplar(ctx context.Context, counter prometheus.Counter) {
+	labels := exemplar(ctx)
+	if adder, ok := counter.(prometheus.ExemplarAdder); ok && labels != nil {
+		adder.AddWithExemplar(1, labels)
+		return
+	}
+	counter.Inc()
+}
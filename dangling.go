@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// danglingClaim reports whether a volume still references a claim that no
+// longer exists.
+func (c *cleaner) danglingClaim(pv *corev1.PersistentVolume) (bool, error) {
+	if pv.Spec.ClaimRef == nil {
+		return false, nil
+	}
+
+	pvc, err := c.factory.Core().V1().PersistentVolumeClaims().Lister().PersistentVolumeClaims(pv.Spec.ClaimRef.Namespace).Get(pv.Spec.ClaimRef.Name)
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return pv.Spec.ClaimRef.UID != "" && pv.Spec.ClaimRef.UID != pvc.UID, nil
+}
+
+// danglingVolumeNodes returns the missing nodes of a local volume whose claim
+// and nodes are both gone and that may be deleted, or nil otherwise.
+func (c *cleaner) danglingVolumeNodes(ctx context.Context, pv *corev1.PersistentVolume) []string {
+	nodes := c.volumeNodes(pv)
+	if len(nodes) == 0 || pv.DeletionTimestamp != nil || replicatedVolume(pv) || !c.localVolume(pv) {
+		return nil
+	}
+
+	dangling, err := c.danglingClaim(pv)
+	if err != nil {
+		logf(ctx, "failed to get pvc bound to pv(%s): %v\n", pv.Name, err)
+		return nil
+	}
+	if !dangling {
+		return nil
+	}
+
+	if d := c.failures.decision(volumeKey(pv)); d != "" {
+		tracef("pv(%s) is %s\n", pv.Name, d)
+		return nil
+	}
+
+	remaining, err := c.remainingNode(ctx, nodes)
+	if err != nil {
+		logf(ctx, "failed to get nodes(%s) from pv(%s): %v\n", strings.Join(nodes, ","), pv.Name, err)
+		return nil
+	}
+	if remaining != "" {
+		tracef("pv(%s) has a missing pvc but node(%s) exists\n", pv.Name, remaining)
+		return nil
+	}
+	return nodes
+}
+
+// cleanupDanglingVolumes deletes local volumes left behind when a cleanup was
+// interrupted between deleting the claim and its volume. Only volumes whose
+// claim and nodes are both gone are touched, so retained volumes on live
+// nodes are left alone.
+func (c *cleaner) cleanupDanglingVolumes(ctx context.Context) {
+	if !c.deletePVs {
+		return
+	}
+
+	pvs, err := c.factory.Core().V1().PersistentVolumes().Lister().List(labels.Everything())
+	if err != nil {
+		logf(ctx, "failed to list pvs: %v\n", err)
+		return
+	}
+
+	for _, pv := range pvs {
+		nodes := c.danglingVolumeNodes(ctx, pv)
+		if len(nodes) == 0 {
+			continue
+		}
+
+		if c.reportOnly() {
+			logf(ctx, "found dangling pv(%s) of missing pvc(%s/%s)\n", pv.Name, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name)
+			continue
+		}
+
+		err = c.recordProvenance(ctx, pv.Name, reasonDanglingVolume, nodes)
+		if err != nil {
+			logf(ctx, "failed to record provenance on pv(%s): %v\n", pv.Name, err)
+		}
+
+		err = c.deleteCall(ctx, func() error {
+			return c.clientset.CoreV1().PersistentVolumes().Delete(ctx, pv.Name, metav1.DeleteOptions{})
+		})
+		c.observeFailure(ctx, volumeKey(pv), pv, err)
+		if err != nil {
+			logf(ctx, "failed to delete dangling pv(%s): %v\n", pv.Name, err)
+			continue
+		}
+
+		logf(ctx, "deleted dangling pv(%s) of missing pvc(%s/%s)\n", pv.Name, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name)
+		danglingVolumesDeleted.Inc()
+		c.eventf(ctx, pv, corev1.EventTypeNormal, "DanglingVolumeDeleted", "pvc %s/%s and node(s) %v are gone", pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, nodes)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// decision is the machine readable outcome of evaluating a claim.
+type decision string
+
+const (
+	decisionDeleted                    decision = "deleted"
+	decisionMigrated                   decision = "migrated"
+	decisionFailed                     decision = "failed"
+	decisionSkippedNodeExists          decision = "skipped:node-exists"
+	decisionSkippedNodeExcluded        decision = "skipped:node-excluded"
+	decisionSkippedNodeNeverSeen       decision = "skipped:node-never-seen"
+	decisionSkippedFakeNodes           decision = "skipped:fake-nodes"
+	decisionSkippedUnclassifiable      decision = "skipped:unclassifiable"
+	decisionSkippedReplicated          decision = "skipped:replicated"
+	decisionSkippedNonLocalVolume      decision = "skipped:non-local-volume"
+	decisionSkippedLeaseRenewed        decision = "skipped:lease-renewed"
+	decisionSkippedLowConfidence       decision = "skipped:low-confidence"
+	decisionSkippedStorageClassMissing decision = "skipped:storage-class-missing"
+	decisionSkippedLost                decision = "skipped:lost"
+	decisionSkippedNamespaceProtected  decision = "skipped:namespace-protected"
+	decisionSkippedTerminating         decision = "skipped:namespace-terminating"
+	decisionSkippedWorkloadProtected   decision = "skipped:workload-protected"
+	decisionSkippedGracePeriod         decision = "skipped:grace-period"
+	decisionSkippedCancelled           decision = "skipped:cancelled"
+	decisionSkippedAwaitingApproval    decision = "skipped:awaiting-approval"
+	decisionSkippedActiveConsumers     decision = "skipped:active-consumers"
+	decisionSkippedAwaitingExternal    decision = "skipped:awaiting-external"
+	decisionSkippedBackoff             decision = "skipped:backoff"
+	decisionSkippedFlapping            decision = "skipped:flapping"
+	decisionSkippedBlacklisted         decision = "skipped:blacklisted"
+	decisionSkippedPaused              decision = "skipped:paused"
+	decisionSkippedRestoring           decision = "skipped:restoring"
+	decisionSkippedCloneSource         decision = "skipped:clone-source"
+	decisionSkippedCircuitOpen         decision = "skipped:circuit-open"
+	decisionSkippedAPIServerLoad       decision = "skipped:apiserver-load"
+	decisionSkippedReportMode          decision = "skipped:report-mode"
+	decisionSkippedVetoed              decision = "skipped:vetoed"
+	decisionSkippedVetoDelayed         decision = "skipped:veto-delayed"
+	decisionSkippedVetoUnavailable     decision = "skipped:veto-unavailable"
+)
+
+// summary counts the decisions of a batch of evaluated claims.
+type summary struct {
+	found          int
+	cleaned        int
+	skipped        int
+	failed         int
+	unclassifiable int
+	// storageClassMissing counts the claims of missing storage classes, no
+	// matter whether they are cleaned up.
+	storageClassMissing int
+	// lost counts the claims in the Lost phase, no matter whether they are
+	// cleaned up.
+	lost int
+	// namespaceTerminating counts the orphans left to the deletion of their
+	// namespace, which are not counted as found.
+	namespaceTerminating int
+	// outcomes are the orphans of the batch and their decisions.
+	outcomes []outcome
+}
+
+type outcome struct {
+	cand     candidate
+	decision decision
+	// duration and err are how long cleaning up the orphan took and why it
+	// failed, for orphans the cleaner tried to clean up.
+	duration time.Duration
+	err      error
+	// usedBytes is how much data the volume of a cleaned up orphan held, -1
+	// when unknown.
+	usedBytes int64
+	// blockedSince is when an orphan escalated by the renotify interval was
+	// first part of a batch, zero for others.
+	blockedSince time.Time
+}
+
+// addCleanup adds the decision of an orphan the cleaner tried to clean up.
+func (s *summary) addCleanup(cand candidate, d decision, duration time.Duration, err error, usedBytes int64) {
+	n := len(s.outcomes)
+	s.add(cand, d)
+	if len(s.outcomes) > n {
+		s.outcomes[n].duration = duration
+		s.outcomes[n].err = err
+		s.outcomes[n].usedBytes = usedBytes
+	}
+}
+
+func (s *summary) add(cand candidate, d decision) {
+	if cand.storageClassMissing {
+		s.storageClassMissing++
+	}
+	if cand.lost {
+		s.lost++
+	}
+	if d == decisionSkippedNodeExists || d == decisionSkippedReplicated || d == decisionSkippedNonLocalVolume || d == decisionSkippedStorageClassMissing || d == decisionSkippedLost {
+		return
+	}
+	if d == decisionSkippedUnclassifiable {
+		s.unclassifiable++
+		return
+	}
+	if d == decisionSkippedTerminating {
+		s.namespaceTerminating++
+		return
+	}
+
+	s.found++
+	s.outcomes = append(s.outcomes, outcome{cand: cand, decision: d, usedBytes: -1})
+	switch {
+	case d == decisionDeleted || d == decisionMigrated:
+		s.cleaned++
+	case d == decisionFailed:
+		s.failed++
+	case strings.HasPrefix(string(d), "skipped:"):
+		s.skipped++
+	}
+}
+
+// maxDecisions bounds the number of claims the decision log remembers.
+const maxDecisions = 1000
+
+type decisionRecord struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Nodes     []string  `json:"nodes"`
+	Decision  decision  `json:"decision"`
+	Time      time.Time `json:"time"`
+	// Deadline is when a quarantined claim gets cleaned up.
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// CorrelationID is the id of the node cleanup or reconcile that made the
+	// decision.
+	CorrelationID string `json:"correlationId,omitempty"`
+	// Cause is why the nodes of the claim were deleted, when observed.
+	Cause string `json:"cause,omitempty"`
+}
+
+// decisionLog keeps the latest decision for each evaluated claim.
+type decisionLog struct {
+	mu      sync.Mutex
+	records map[string]decisionRecord
+}
+
+func newDecisionLog() *decisionLog {
+	return &decisionLog{records: map[string]decisionRecord{}}
+}
+
+func (l *decisionLog) add(record decisionRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := record.Namespace + "/" + record.Name
+	l.records[key] = record
+	if len(l.records) <= maxDecisions {
+		return
+	}
+
+	oldestKey := ""
+	var oldest time.Time
+	for key, record := range l.records {
+		if oldestKey == "" || record.Time.Before(oldest) {
+			oldestKey = key
+			oldest = record.Time
+		}
+	}
+	delete(l.records, oldestKey)
+}
+
+// list returns the remembered decisions, newest first.
+func (l *decisionLog) list() []decisionRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := make([]decisionRecord, 0, len(l.records))
+	for _, record := range l.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Time.After(records[j].Time)
+	})
+	return records
+}
+
+func (c *cleaner) record(ctx context.Context, cand candidate, d decision) {
+	switch d {
+	case decisionSkippedBackoff, decisionSkippedBlacklisted, decisionSkippedReplicated:
+		// these repeat on every reconcile until the object is cleared
+		tracef("pvc(%s/%s) nodes(%s) decision(%s)\n", cand.pvc.Namespace, cand.pvc.Name, strings.Join(cand.nodes, ","), d)
+	default:
+		logf(ctx, "pvc(%s/%s) nodes(%s) decision(%s)\n", cand.pvc.Namespace, cand.pvc.Name, strings.Join(cand.nodes, ","), d)
+	}
+	incWithExemplar(ctx, decisionsTotal.WithLabelValues(string(d)))
+	if d == decisionDeleted {
+		c.deletionMetrics.observe(ctx, cand.pvc)
+	}
+	switch d {
+	case decisionDeleted, decisionMigrated:
+		c.stats.observe(cand.nodes, false)
+	case decisionFailed:
+		c.stats.observe(cand.nodes, true)
+	}
+	record := decisionRecord{
+		Namespace: cand.pvc.Namespace,
+		Name:      cand.pvc.Name,
+		Nodes:     cand.nodes,
+		Decision:  d,
+		Time:      time.Now(),
+
+		CorrelationID: correlationID(ctx),
+		Cause:         c.nodeCauses(cand.nodes),
+	}
+	if d == decisionSkippedGracePeriod {
+		deadline := c.quarantineDeadline(ctx, cand.pvc)
+		record.Deadline = &deadline
+	}
+	c.decisions.add(record)
+	c.exposeState(ctx, cand, d, record.Deadline)
+}
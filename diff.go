@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	colorRed    = "\x1b[31m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+	colorReset  = "\x1b[0m"
+)
+
+// diffWriter prints diff lines, colored when writing to a terminal.
+type diffWriter struct {
+	w     io.Writer
+	color bool
+}
+
+func (d diffWriter) line(color, format string, args ...any) {
+	line := fmt.Sprintf(format, args...)
+	if d.color {
+		line = color + line + colorReset
+	}
+	fmt.Fprintln(d.w, line)
+}
+
+// runDiff prints the objects the cleaner considers orphaned and why, without
+// acting on them. Log lines go to stderr so the report can be redirected.
+func (c *cleaner) runDiff(ctx context.Context) error {
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = out }()
+
+	d := diffWriter{w: out, color: term.IsTerminal(int(out.Fd()))}
+	mapping, err := c.nodeMapping(ctx)
+	if err != nil {
+		return fmt.Errorf("getting node mapping: %w", err)
+	}
+
+	candidates, err := c.allCandidates(ctx)
+	if err != nil {
+		return err
+	}
+	c.sortCandidates(candidates)
+
+	var deleted, migrated, kept int
+	for _, cand := range candidates {
+		decision := c.skipDecision(ctx, cand)
+		if decision == decisionSkippedNodeExists {
+			continue
+		}
+
+		name := cand.pvc.Namespace + "/" + cand.pvc.Name
+		nodes := strings.Join(cand.nodes, ",")
+		if decision != "" && decision != decisionSkippedReportMode {
+			kept++
+			d.line(colorYellow, "# pvc %s on node(s) %s is kept: %s", name, nodes, decision)
+			continue
+		}
+
+		newNode := ""
+		for _, nodeName := range cand.nodes {
+			if mapping[nodeName] != "" {
+				newNode = mapping[nodeName]
+				break
+			}
+		}
+		if newNode != "" {
+			migrated++
+			d.line(colorCyan, "# pvc %s moves from node(s) %s to node(%s)", name, nodes, newNode)
+			d.line(colorCyan, "~ persistentvolumeclaim/%s", name)
+			if cand.pvc.Spec.VolumeName != "" {
+				d.line(colorCyan, "~ persistentvolume/%s", cand.pvc.Spec.VolumeName)
+			}
+			continue
+		}
+
+		deleted++
+		d.line(colorRed, "# pvc %s is orphaned, node(s) %s are gone", name, nodes)
+		pods, err := c.consumerPods(cand.pvc)
+		if err != nil {
+			return err
+		}
+		if c.deletePods {
+			for _, pod := range pods {
+				d.line(colorRed, "- pod/%s/%s", pod.Namespace, pod.Name)
+			}
+		}
+		if c.deletePVCs {
+			d.line(colorRed, "- persistentvolumeclaim/%s", name)
+		}
+		if c.deletePVs && cand.pvc.Spec.VolumeName != "" {
+			d.line(colorRed, "- persistentvolume/%s", cand.pvc.Spec.VolumeName)
+		}
+	}
+
+	if c.deletePVs {
+		pvs, err := c.factory.Core().V1().PersistentVolumes().Lister().List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		for _, pv := range pvs {
+			nodes := c.danglingVolumeNodes(ctx, pv)
+			if len(nodes) == 0 {
+				continue
+			}
+			deleted++
+			d.line(colorRed, "# pv %s is dangling, pvc %s/%s and node(s) %s are gone", pv.Name, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, strings.Join(nodes, ","))
+			d.line(colorRed, "- persistentvolume/%s", pv.Name)
+		}
+	}
+
+	fmt.Fprintf(out, "%d to delete, %d to migrate, %d kept\n", deleted, migrated, kept)
+	return nil
+}
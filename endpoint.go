@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"k8s.io/client-go/rest"
+)
+
+// apiEndpoint overrides how the api server of the kubeconfig or in-cluster
+// config is reached, for environments where it sits behind a proxy or serves
+// a certificate of a private ca.
+type apiEndpoint struct {
+	caFile     string
+	certFile   string
+	keyFile    string
+	proxyURL   string
+	serverName string
+}
+
+// apply sets the overrides on a config. Files replace the data loaded from the
+// kubeconfig, which would take precedence otherwise.
+func (e apiEndpoint) apply(config *rest.Config) error {
+	if e.caFile != "" {
+		config.TLSClientConfig.CAFile = e.caFile
+		config.TLSClientConfig.CAData = nil
+	}
+	if (e.certFile == "") != (e.keyFile == "") {
+		return errors.New("--api-client-cert and --api-client-key must be set together")
+	}
+	if e.certFile != "" {
+		config.TLSClientConfig.CertFile = e.certFile
+		config.TLSClientConfig.CertData = nil
+		config.TLSClientConfig.KeyFile = e.keyFile
+		config.TLSClientConfig.KeyData = nil
+	}
+	if e.serverName != "" {
+		config.TLSClientConfig.ServerName = e.serverName
+	}
+	if e.proxyURL != "" {
+		proxy, err := url.Parse(e.proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy url: %w", err)
+		}
+		if proxy.Scheme != "http" && proxy.Scheme != "https" && proxy.Scheme != "socks5" {
+			return fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+		}
+		// credentials in the url are sent as Proxy-Authorization
+		config.Proxy = http.ProxyURL(proxy)
+	}
+	return nil
+}
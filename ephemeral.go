@@ -0,0 +1,30 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ephemeralClaim reports whether a claim was created for a generic ephemeral
+// volume of a pod. Such claims are owned by their pod and garbage collected
+// with it, and the workload gets a new claim with its next pod, so they must
+// never be recreated.
+func ephemeralClaim(pvc *corev1.PersistentVolumeClaim) bool {
+	owner := metav1.GetControllerOf(pvc)
+	return owner != nil && owner.Kind == "Pod" && owner.APIVersion == "v1"
+}
+
+// podClaimNames returns the names of the claims a pod uses, including the
+// claims of its generic ephemeral volumes.
+func podClaimNames(pod *corev1.Pod) []string {
+	names := make([]string, 0, len(pod.Spec.Volumes))
+	for _, volume := range pod.Spec.Volumes {
+		switch {
+		case volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName != "":
+			names = append(names, volume.PersistentVolumeClaim.ClaimName)
+		case volume.Ephemeral != nil:
+			names = append(names, pod.Name+"-"+volume.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	eventQueueDepthDesc = prometheus.NewDesc(
+		"local_pvc_cleaner_event_queue_depth",
+		"Number of node events waiting for their handler.",
+		nil, nil,
+	)
+	eventQueueOldestDesc = prometheus.NewDesc(
+		"local_pvc_cleaner_event_queue_oldest_seconds",
+		"Age of the oldest node event waiting for its handler, 0 when none waits.",
+		nil, nil,
+	)
+)
+
+// queuedEvent is an informer event waiting for its handler.
+type queuedEvent struct {
+	handler string
+	queued  time.Time
+	run     func()
+}
+
+// eventQueue takes node events off the informer as they arrive and runs their
+// handlers one at a time and in order, like the informer would, so a cleaner
+// falling behind during mass node churn shows in its depth and age instead of
+// in the hidden buffer of the informer.
+type eventQueue struct {
+	mu     sync.Mutex
+	items  []queuedEvent
+	notify chan struct{}
+}
+
+// newEventQueue returns an event queue reporting its depth and age.
+func newEventQueue() *eventQueue {
+	q := &eventQueue{notify: make(chan struct{}, 1)}
+	prometheus.MustRegister(q)
+	return q
+}
+
+// enqueue adds an event for the named handler.
+func (q *eventQueue) enqueue(handler string, run func()) {
+	q.mu.Lock()
+	q.items = append(q.items, queuedEvent{handler: handler, queued: time.Now(), run: run})
+	q.mu.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *eventQueue) pop() (queuedEvent, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return queuedEvent{}, false
+	}
+	event := q.items[0]
+	q.items[0] = queuedEvent{}
+	q.items = q.items[1:]
+	return event, true
+}
+
+// run handles the queued events until the context is done.
+func (q *eventQueue) run(ctx context.Context) {
+	for {
+		event, ok := q.pop()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.notify:
+			}
+			continue
+		}
+
+		start := time.Now()
+		eventHandlerLag.WithLabelValues(event.handler).Observe(start.Sub(event.queued).Seconds())
+		event.run()
+		eventHandlerDuration.WithLabelValues(event.handler).Observe(time.Since(start).Seconds())
+	}
+}
+
+func (q *eventQueue) Describe(ch chan<- *prometheus.Desc) {
+	ch <- eventQueueDepthDesc
+	ch <- eventQueueOldestDesc
+}
+
+func (q *eventQueue) Collect(ch chan<- prometheus.Metric) {
+	q.mu.Lock()
+	depth := len(q.items)
+	var oldest time.Duration
+	if depth > 0 {
+		oldest = time.Since(q.items[0].queued)
+	}
+	q.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(eventQueueDepthDesc, prometheus.GaugeValue, float64(depth))
+	ch <- prometheus.MustNewConstMetric(eventQueueOldestDesc, prometheus.GaugeValue, oldest.Seconds())
+}
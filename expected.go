@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expectedNodes are nodes an operator is about to decommission, whose claims
+// skip the grace period until the authorization expires.
+type expectedNodes struct {
+	mu    sync.Mutex
+	nodes map[string]time.Time
+}
+
+func newExpectedNodes() *expectedNodes {
+	return &expectedNodes{nodes: map[string]time.Time{}}
+}
+
+// expect authorizes the cleanup of the given nodes for ttl.
+func (e *expectedNodes) expect(nodeNames []string, ttl time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	for _, nodeName := range nodeNames {
+		e.nodes[nodeName] = expires
+	}
+}
+
+// covers reports whether every given node is expected, forgetting the expired
+// ones.
+func (e *expectedNodes) covers(nodeNames []string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	for nodeName, expires := range e.nodes {
+		if now.After(expires) {
+			delete(e.nodes, nodeName)
+		}
+	}
+	if len(nodeNames) == 0 {
+		return false
+	}
+	for _, nodeName := range nodeNames {
+		if _, ok := e.nodes[nodeName]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+type expectedNode struct {
+	Name    string    `json:"name"`
+	Expires time.Time `json:"expires"`
+}
+
+// list returns the expected nodes, sorted by name.
+func (e *expectedNodes) list() []expectedNode {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	nodes := []expectedNode{}
+	for nodeName, expires := range e.nodes {
+		if now.After(expires) {
+			continue
+		}
+		nodes = append(nodes, expectedNode{Name: nodeName, Expires: expires})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
+
+type expectNodesRequest struct {
+	Nodes []string `json:"nodes"`
+	TTL   string   `json:"ttl"`
+}
+
+// handleExpectedNodes lists the expected nodes on GET and authorizes the
+// cleanup of more on POST.
+func (c *cleaner) handleExpectedNodes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		c.readable(c.handleListExpectedNodes)(w, r)
+	case http.MethodPost:
+		c.authorized(c.handleExpectNodes)(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (c *cleaner) handleListExpectedNodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"nodes": c.expected.list()})
+}
+
+func (c *cleaner) handleExpectNodes(w http.ResponseWriter, r *http.Request) {
+	var request expectNodesRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || len(request.Nodes) == 0 {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	ttl, err := time.ParseDuration(request.TTL)
+	if err != nil || ttl <= 0 {
+		http.Error(w, "invalid ttl", http.StatusBadRequest)
+		return
+	}
+
+	c.expected.expect(request.Nodes, ttl)
+	fmt.Printf("expecting the removal of nodes(%s) for %s\n", strings.Join(request.Nodes, ","), ttl)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runClean authorizes a running cleaner to clean up the claims of nodes about
+// to be decommissioned without waiting for the grace period.
+func runClean(args []string) {
+	flags := flag.NewFlagSet("clean", flag.ExitOnError)
+	server := flags.String("server", "http://localhost:8080", "address of the cleaner api")
+	apiTokenFile := flags.String("api-token-file", "", "file containing the bearer token of the cleaner api")
+	var nodes stringList
+	flags.Var(&nodes, "expect-nodes", "comma separated nodes about to be decommissioned")
+	ttl := flags.Duration("ttl", time.Hour, "how long the authorization lasts")
+	flags.Parse(args)
+
+	if len(nodes) == 0 {
+		fmt.Fprintf(os.Stderr, "no nodes to expect\n")
+		os.Exit(1)
+	}
+
+	body, err := json.Marshal(expectNodesRequest{Nodes: nodes, TTL: ttl.String()})
+	if err != nil {
+		panic(err)
+	}
+	callAPI(*server, *apiTokenFile, http.MethodPost, "/v1/expected-nodes", body, http.StatusNoContent)
+	fmt.Printf("expecting the removal of nodes(%s) for %s\n", nodes.String(), *ttl)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// orphanLabel marks the claims an external deleter may delete in external
+// mode.
+const orphanLabel = "local-pvc-cleaner.io/orphan"
+
+// externalOrphan is a claim offered to the external deleter.
+type externalOrphan struct {
+	cand  candidate
+	since time.Time
+}
+
+// externalOrphans are the claims the cleaner would have deleted in external
+// mode, waiting for the external deleter to confirm their deletion.
+type externalOrphans struct {
+	mu      sync.Mutex
+	orphans map[string]externalOrphan
+}
+
+func newExternalOrphans() *externalOrphans {
+	return &externalOrphans{orphans: map[string]externalOrphan{}}
+}
+
+// patchClaimLabels merges the given labels into a claim, removing the ones set
+// to nil.
+func (c *cleaner) patchClaimLabels(ctx context.Context, pvc *corev1.PersistentVolumeClaim, labels map[string]*string) error {
+	if simulated(ctx) {
+		return nil
+	}
+	client, err := c.clientFor(pvc.Namespace)
+	if err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"labels": labels},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// offerExternal labels an orphan that passed every check and offers it to the
+// external deleter instead of deleting it.
+func (c *cleaner) offerExternal(ctx context.Context, cand candidate) decision {
+	if cand.pvc.Labels[orphanLabel] != "true" {
+		value := "true"
+		err := c.patchClaimLabels(ctx, cand.pvc, map[string]*string{orphanLabel: &value})
+		if err != nil {
+			logf(ctx, "failed to label pvc(%s) as orphan: %v\n", cand.pvc.Name, err)
+			return decisionFailed
+		}
+		c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "AwaitingExternalDeletion", "node(s) %v are gone, the pvc is left to the external deleter", cand.nodes)
+	}
+
+	key := claimKey(cand.pvc)
+	c.external.mu.Lock()
+	defer c.external.mu.Unlock()
+	since := time.Now()
+	if offered, ok := c.external.orphans[key]; ok && offered.cand.pvc.UID == cand.pvc.UID {
+		since = offered.since
+	}
+	c.external.orphans[key] = externalOrphan{cand: cand, since: since}
+	return decisionSkippedAwaitingExternal
+}
+
+// withdrawExternal stops offering a claim whose node is back and removes its
+// orphan label.
+func (c *cleaner) withdrawExternal(ctx context.Context, cand candidate) {
+	if c.external == nil {
+		return
+	}
+	c.external.mu.Lock()
+	delete(c.external.orphans, claimKey(cand.pvc))
+	c.external.mu.Unlock()
+
+	if _, ok := cand.pvc.Labels[orphanLabel]; !ok {
+		return
+	}
+	err := c.patchClaimLabels(ctx, cand.pvc, map[string]*string{orphanLabel: nil})
+	if err != nil {
+		logf(ctx, "failed to remove orphan label from pvc(%s): %v\n", cand.pvc.Name, err)
+	}
+}
+
+type externalConfirmation struct {
+	UID types.UID `json:"uid"`
+	// Error is why the external deleter failed to delete the claim, empty
+	// once it deleted it.
+	Error string `json:"error,omitempty"`
+}
+
+// offeredKey returns the key of the offered claim an api request names by the
+// hashed names shown when names are redacted, which the claim may no longer
+// be listed under once the external deleter deleted it. The caller holds the
+// lock of the external orphans.
+func (c *cleaner) offeredKey(namespace, name string) string {
+	if c.redactor == nil {
+		return "pvc/" + namespace + "/" + name
+	}
+	for key, offered := range c.external.orphans {
+		pvc := offered.cand.pvc
+		if c.redactor.name(pvc.Namespace) == namespace && c.redactor.name(pvc.Name) == name {
+			return key
+		}
+	}
+	return ""
+}
+
+// confirmExternal records the outcome of an external deletion like the
+// cleaner's own: deleted claims count as cleaned up and failed ones back off.
+func (c *cleaner) confirmExternal(ctx context.Context, namespace, name string, confirmation externalConfirmation) error {
+	if c.external == nil {
+		return errors.New("the cleaner does not run in external mode")
+	}
+
+	c.external.mu.Lock()
+	key := c.offeredKey(namespace, name)
+	offered, ok := c.external.orphans[key]
+	if ok && (confirmation.UID == "" || confirmation.UID == offered.cand.pvc.UID) {
+		delete(c.external.orphans, key)
+	}
+	c.external.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("pvc %s/%s is not offered for deletion", namespace, name)
+	}
+	if confirmation.UID != "" && confirmation.UID != offered.cand.pvc.UID {
+		return fmt.Errorf("pvc %s/%s was offered with uid %s", namespace, name, offered.cand.pvc.UID)
+	}
+
+	namespace, name = offered.cand.pvc.Namespace, offered.cand.pvc.Name
+
+	ctx = withCorrelationID(ctx, newCorrelationID())
+	if confirmation.Error != "" {
+		c.observeFailure(ctx, key, offered.cand.pvc, errors.New(confirmation.Error))
+		c.record(ctx, offered.cand, decisionFailed)
+		return nil
+	}
+
+	c.observeFailure(ctx, key, offered.cand.pvc, nil)
+	if c.minCleanupInterval > 0 {
+		c.history.observe(key, c.minCleanupInterval)
+	}
+	logf(ctx, "external deleter deleted pvc(%s/%s) after %s\n", namespace, name, time.Since(offered.since).Round(time.Second))
+	c.record(ctx, offered.cand, decisionDeleted)
+	return nil
+}
+
+type externalCandidate struct {
+	orphan
+	UID   types.UID `json:"uid"`
+	Since time.Time `json:"since"`
+}
+
+// handleCandidates lists the claims offered to the external deleter,
+// forgetting the ones deleted without a confirmation.
+func (c *cleaner) handleCandidates(w http.ResponseWriter, r *http.Request) {
+	candidates := []externalCandidate{}
+	if c.external != nil {
+		c.external.mu.Lock()
+		for key, offered := range c.external.orphans {
+			pvc := offered.cand.pvc
+			current, err := c.factory.Core().V1().PersistentVolumeClaims().Lister().PersistentVolumeClaims(pvc.Namespace).Get(pvc.Name)
+			if apierrors.IsNotFound(err) || (err == nil && current.UID != pvc.UID) {
+				delete(c.external.orphans, key)
+				continue
+			}
+			candidates = append(candidates, externalCandidate{
+				orphan: orphan{
+					Namespace: c.redactor.name(pvc.Namespace),
+					Name:      c.redactor.name(pvc.Name),
+					Volume:    pvc.Spec.VolumeName,
+					Nodes:     offered.cand.nodes,
+				},
+				UID:   pvc.UID,
+				Since: offered.since,
+			})
+		}
+		c.external.mu.Unlock()
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Since.Before(candidates[j].Since)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"candidates": candidates})
+}
@@ -0,0 +1,7 @@
+package main
+
+import "k8s.io/client-go/rest"
+
+// injectFaults is set by builds with the chaos tag to wrap the transport of
+// the api client with fault injection, and nil otherwise.
+var injectFaults func(config *rest.Config)
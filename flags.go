@@ -0,0 +1,31 @@
+package main
+
+import "strings"
+
+// stringList is a comma separated flag value.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = nil
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		*s = append(*s, item)
+	}
+	return nil
+}
+
+func (s stringList) contains(value string) bool {
+	for _, item := range s {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
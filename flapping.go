@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// cleanupHistory remembers when each claim name was last cleaned up, since
+// claims of stateful sets are recreated under the same name.
+type cleanupHistory struct {
+	mu      sync.Mutex
+	cleaned map[string]time.Time
+}
+
+func newCleanupHistory() *cleanupHistory {
+	return &cleanupHistory{cleaned: map[string]time.Time{}}
+}
+
+// observe records the cleanup of a claim name, forgetting the cleanups older
+// than interval.
+func (h *cleanupHistory) observe(key string, interval time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for existing, cleaned := range h.cleaned {
+		if now.Sub(cleaned) > interval {
+			delete(h.cleaned, existing)
+		}
+	}
+	h.cleaned[key] = now
+}
+
+// last returns when a claim name was last cleaned up.
+func (h *cleanupHistory) last(key string) (time.Time, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cleaned, ok := h.cleaned[key]
+	return cleaned, ok
+}
+
+// flappingDecision refuses to clean up a claim whose name was already cleaned
+// up within the minimum interval, which points at flapping nodes or a
+// misconfiguration rather than repeated node loss.
+func (c *cleaner) flappingDecision(ctx context.Context, cand candidate) decision {
+	if c.minCleanupInterval <= 0 {
+		return ""
+	}
+
+	cleaned, ok := c.history.last(claimKey(cand.pvc))
+	if !ok || time.Since(cleaned) >= c.minCleanupInterval {
+		return ""
+	}
+
+	logf(ctx, "warning: pvc(%s/%s) was already cleaned up at %s, refusing to clean it up again within %s\n", cand.pvc.Namespace, cand.pvc.Name, cleaned.UTC().Format(time.RFC3339), c.minCleanupInterval)
+	c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "CleanupFlapping", "a pvc of this name was already cleaned up at %s, refusing to clean it up again within %s", cleaned.UTC().Format(time.RFC3339), c.minCleanupInterval)
+	return decisionSkippedFlapping
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+// gcInterval is how often reconciles collect the artifacts of the cleaner.
+const gcInterval = time.Hour
+
+// claimAnnotations are the annotations the cleaner sets on claims it considers
+// orphaned.
+var claimAnnotations = []string{
+	orphanedAtAnnotation,
+	approvedAnnotation,
+	stateAnnotation,
+	stateDeadlineAnnotation,
+	stateMessageAnnotation,
+}
+
+// collectGarbage removes the artifacts the cleaner left in the cluster once
+// they are older than the artifact retention: tombstones of deleted claims,
+// dropping the tombstone configmaps that end up empty, and the annotations of
+// claims the cleaner no longer manages. It runs at most once per gc interval.
+func (c *cleaner) collectGarbage(ctx context.Context, candidates []candidate) {
+	if c.artifactRetention <= 0 || c.reportOnly() || time.Since(c.lastGC) < gcInterval {
+		return
+	}
+	c.lastGC = time.Now()
+
+	tombstones, err := c.collectTombstones(ctx)
+	if err != nil {
+		logf(ctx, "failed to collect tombstones: %v\n", err)
+	}
+	annotations := c.collectClaimAnnotations(ctx, candidates)
+	if tombstones > 0 || annotations > 0 {
+		logf(ctx, "collected %d tombstones and the annotations of %d pvcs older than %s\n", tombstones, annotations, c.artifactRetention)
+	}
+}
+
+// collectTombstones drops the tombstones older than the artifact retention and
+// returns how many it dropped.
+func (c *cleaner) collectTombstones(ctx context.Context) (int, error) {
+	cms, err := c.clientset.CoreV1().ConfigMaps(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", tombstoneConfigMapName).String(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-c.artifactRetention).Unix()
+	collected := 0
+	for _, cm := range cms.Items {
+		data := map[string]string{}
+		for key, value := range cm.Data {
+			if tombstoneTime(key) >= cutoff {
+				data[key] = value
+			}
+		}
+		expired := len(cm.Data) - len(data)
+		if expired == 0 {
+			continue
+		}
+
+		client, err := c.clientFor(cm.Namespace)
+		if err != nil {
+			logf(ctx, "failed to collect tombstones in namespace(%s): %v\n", cm.Namespace, err)
+			continue
+		}
+		if len(data) == 0 {
+			err = client.CoreV1().ConfigMaps(cm.Namespace).Delete(ctx, cm.Name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &cm.ResourceVersion},
+			})
+			if apierrors.IsNotFound(err) {
+				err = nil
+			}
+		} else {
+			apply := applycorev1.ConfigMap(tombstoneConfigMapName, cm.Namespace).WithData(data)
+			_, err = client.CoreV1().ConfigMaps(cm.Namespace).Apply(ctx, apply, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+		}
+		if err != nil {
+			logf(ctx, "failed to collect tombstones in namespace(%s): %v\n", cm.Namespace, err)
+			continue
+		}
+		collected += expired
+		artifactsCollected.WithLabelValues("tombstone").Add(float64(expired))
+	}
+	return collected, nil
+}
+
+// collectClaimAnnotations removes the annotations of the cleaner from claims
+// that are no longer candidates, like after their provisioner stopped being
+// local, once they were orphaned for longer than the artifact retention. It
+// returns how many claims it patched.
+func (c *cleaner) collectClaimAnnotations(ctx context.Context, candidates []candidate) int {
+	managed := map[types.UID]bool{}
+	for _, cand := range candidates {
+		managed[cand.pvc.UID] = true
+	}
+
+	pvcs, err := c.factory.Core().V1().PersistentVolumeClaims().Lister().List(labels.Everything())
+	if err != nil {
+		logf(ctx, "failed to list pvcs: %v\n", err)
+		return 0
+	}
+
+	collected := 0
+	for _, pvc := range pvcs {
+		if managed[pvc.UID] {
+			continue
+		}
+		annotations := map[string]*string{}
+		for _, key := range claimAnnotations {
+			if _, ok := pvc.Annotations[key]; ok {
+				annotations[key] = nil
+			}
+		}
+		if len(annotations) == 0 {
+			continue
+		}
+		if orphanedAt, err := time.Parse(time.RFC3339, pvc.Annotations[orphanedAtAnnotation]); err == nil && time.Since(orphanedAt) < c.artifactRetention {
+			continue
+		}
+
+		err := c.patchClaimAnnotations(ctx, pvc, annotations)
+		if err != nil {
+			logf(ctx, "failed to collect annotations of pvc(%s/%s): %v\n", pvc.Namespace, pvc.Name, err)
+			continue
+		}
+		tracef("collected annotations of pvc(%s/%s)\n", pvc.Namespace, pvc.Name)
+		c.eventf(ctx, pvc, corev1.EventTypeNormal, "AnnotationsCollected", "removed the annotations of the cleaner, the pvc is no longer managed")
+		collected++
+		artifactsCollected.WithLabelValues("annotations").Inc()
+	}
+	return collected
+}
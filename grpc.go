@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	adminv1 "github.com/OrangeDrangon/local-pvc-cleaner/api/admin/v1"
+)
+
+// adminServer serves the grpc admin api, which drives the cleaner like the
+// http api does for orchestrators that speak grpc.
+type adminServer struct {
+	adminv1.UnimplementedAdminServer
+	c *cleaner
+}
+
+// readOnlyMethods are the admin methods served without authorization when
+// read only endpoints are public.
+var readOnlyMethods = stringList{
+	adminv1.Admin_ListOrphans_FullMethodName,
+	adminv1.Admin_GetHistory_FullMethodName,
+}
+
+// serveGRPC runs the grpc admin api on addr, with the same tls, client ca and
+// api token as the http api.
+func (c *cleaner) serveGRPC(addr, certFile, keyFile, clientCAFile string) {
+	options := []grpc.ServerOption{grpc.UnaryInterceptor(c.authorizeGRPC)}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			panic(err)
+		}
+		config := &tls.Config{Certificates: []tls.Certificate{cert}}
+		if clientCAFile != "" {
+			config.ClientCAs = clientCAPool(clientCAFile)
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(config)))
+	}
+
+	server := grpc.NewServer(options...)
+	adminv1.RegisterAdminServer(server, &adminServer{c: c})
+
+	go func() {
+		listener, err := net.Listen("tcp", addr)
+		if err == nil {
+			err = server.Serve(listener)
+		}
+		if err != nil {
+			fmt.Printf("failed to serve grpc on %s: %v\n", addr, err)
+		}
+	}()
+}
+
+// authorizeGRPC only lets calls carrying the configured bearer token or a
+// verified client certificate through, like authorized does for http.
+func (c *cleaner) authorizeGRPC(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if c.publicReadEndpoints && readOnlyMethods.contains(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+			return handler(ctx, req)
+		}
+	}
+
+	if c.apiToken == "" {
+		return nil, status.Error(codes.PermissionDenied, "api token is not configured")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token := strings.TrimPrefix(value, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(c.apiToken)) == 1 {
+			return handler(ctx, req)
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+func (s *adminServer) ListOrphans(ctx context.Context, req *adminv1.ListOrphansRequest) (*adminv1.ListOrphansResponse, error) {
+	orphans, err := s.c.listOrphans(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &adminv1.ListOrphansResponse{}
+	for _, o := range orphans {
+		resp.Orphans = append(resp.Orphans, &adminv1.Orphan{
+			Namespace: o.Namespace,
+			Name:      o.Name,
+			Volume:    o.Volume,
+			Nodes:     o.Nodes,
+		})
+	}
+	return resp, nil
+}
+
+func (s *adminServer) TriggerCleanup(ctx context.Context, req *adminv1.TriggerCleanupRequest) (*adminv1.TriggerCleanupResponse, error) {
+	s.c.triggerReconcile()
+	return &adminv1.TriggerCleanupResponse{}, nil
+}
+
+func (s *adminServer) Pause(ctx context.Context, req *adminv1.PauseRequest) (*adminv1.PauseResponse, error) {
+	err := s.setPaused(ctx, req.Namespace, req.NodePool, true)
+	if err != nil {
+		return nil, err
+	}
+	return &adminv1.PauseResponse{}, nil
+}
+
+func (s *adminServer) Resume(ctx context.Context, req *adminv1.ResumeRequest) (*adminv1.ResumeResponse, error) {
+	err := s.setPaused(ctx, req.Namespace, req.NodePool, false)
+	if err != nil {
+		return nil, err
+	}
+	return &adminv1.ResumeResponse{}, nil
+}
+
+// setPaused suspends or resumes all deletions, or the ones of a namespace or
+// node pool, like /v1/pause and /v1/resume.
+func (s *adminServer) setPaused(ctx context.Context, namespace, nodePool string, paused bool) error {
+	if namespace != "" && nodePool != "" {
+		return status.Error(codes.InvalidArgument, "namespace and node pool are exclusive")
+	}
+
+	var err error
+	if namespace != "" || nodePool != "" {
+		err = s.c.setScopePaused(ctx, namespace, nodePool, paused)
+	} else {
+		err = s.c.setPaused(ctx, paused)
+	}
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+func (s *adminServer) GetHistory(ctx context.Context, req *adminv1.GetHistoryRequest) (*adminv1.GetHistoryResponse, error) {
+	resp := &adminv1.GetHistoryResponse{Paused: s.c.isPaused(ctx)}
+	for _, record := range s.c.decisionHistory(req.Namespace, req.Name) {
+		d := &adminv1.Decision{
+			Namespace:     record.Namespace,
+			Name:          record.Name,
+			Nodes:         record.Nodes,
+			Decision:      string(record.Decision),
+			Time:          timestamppb.New(record.Time),
+			CorrelationId: record.CorrelationID,
+			Cause:         record.Cause,
+		}
+		if record.Deadline != nil {
+			d.Deadline = timestamppb.New(*record.Deadline)
+		}
+		resp.Decisions = append(resp.Decisions, d)
+	}
+	return resp, nil
+}
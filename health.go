@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// statusResource is the cluster scoped ClusterCleanerStatus custom resource
+// the controller reports its health on. Its definition ships in the README.
+var statusResource = schema.GroupVersionResource{
+	Group:    "local-pvc-cleaner.io",
+	Version:  "v1alpha1",
+	Resource: "clustercleanerstatuses",
+}
+
+const statusResourceName = "local-pvc-cleaner"
+
+// conditions of the ClusterCleanerStatus resource.
+const (
+	conditionReady           = "Ready"
+	conditionInformersSynced = "InformersSynced"
+	conditionLeaderElected   = "LeaderElected"
+	conditionPaused          = "Paused"
+	conditionReconciled      = "Reconciled"
+)
+
+// healthStatus keeps the conditions reported on the status resource so their
+// transition times only change when their status does.
+type healthStatus struct {
+	client dynamic.Interface
+
+	mu                sync.Mutex
+	informersSynced   bool
+	conditions        []metav1.Condition
+	lastReconcileTime *metav1.Time
+	lastError         string
+}
+
+func newHealthStatus(client dynamic.Interface) *healthStatus {
+	return &healthStatus{client: client}
+}
+
+// condition returns a condition that is true when ok, with the reason of its
+// status.
+func condition(conditionType string, ok bool, trueReason, falseReason, message string) metav1.Condition {
+	if ok {
+		return metav1.Condition{Type: conditionType, Status: metav1.ConditionTrue, Reason: trueReason, Message: message}
+	}
+	return metav1.Condition{Type: conditionType, Status: metav1.ConditionFalse, Reason: falseReason, Message: message}
+}
+
+// synced records that the informer caches finished syncing.
+func (h *healthStatus) synced() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	h.informersSynced = true
+	h.mu.Unlock()
+}
+
+// reportHealth updates the status resource after a reconcile that failed with
+// the given error, or succeeded when it is nil.
+func (c *cleaner) reportHealth(ctx context.Context, reconcileErr error) {
+	h := c.health
+	if h == nil {
+		return
+	}
+
+	paused := c.isPaused(ctx)
+	h.mu.Lock()
+	now := metav1.Now()
+	h.lastReconcileTime = &now
+	h.lastError = ""
+	if reconcileErr != nil {
+		h.lastError = reconcileErr.Error()
+	}
+
+	meta.SetStatusCondition(&h.conditions, condition(conditionInformersSynced, h.informersSynced, "Synced", "Syncing", "informer caches of nodes, pvs and pvcs"))
+	// the cleaner runs a single replica without leader election, so the
+	// running instance always leads
+	meta.SetStatusCondition(&h.conditions, condition(conditionLeaderElected, true, "SingleReplica", "", "the cleaner does not use leader election"))
+	meta.SetStatusCondition(&h.conditions, condition(conditionPaused, paused, "Paused", "Running", "deletions are suspended through the pause api"))
+	reconciled := condition(conditionReconciled, true, "Succeeded", "", "the last full reconcile succeeded")
+	if reconcileErr != nil {
+		reconciled = condition(conditionReconciled, false, "", "Failed", reconcileErr.Error())
+	}
+	meta.SetStatusCondition(&h.conditions, reconciled)
+	ready := h.informersSynced && reconcileErr == nil
+	meta.SetStatusCondition(&h.conditions, condition(conditionReady, ready, "Healthy", "Unhealthy", "informers are synced and the last full reconcile succeeded"))
+
+	status := map[string]any{
+		"conditions":        h.conditions,
+		"lastReconcileTime": h.lastReconcileTime.UTC().Format(time.RFC3339),
+	}
+	if h.lastError != "" {
+		status["lastError"] = h.lastError
+	}
+	value, err := json.Marshal(status)
+	h.mu.Unlock()
+	if err != nil {
+		logf(ctx, "failed to marshal cleaner status: %v\n", err)
+		return
+	}
+	statusObj := map[string]any{}
+	err = json.Unmarshal(value, &statusObj)
+	if err != nil {
+		logf(ctx, "failed to convert cleaner status: %v\n", err)
+		return
+	}
+
+	err = c.applyStatusResource(ctx, statusObj)
+	if err != nil {
+		logf(ctx, "failed to update %s/%s: %v\n", statusResource.Resource, statusResourceName, err)
+	}
+}
+
+// applyStatusResource creates the singleton status resource when missing and
+// applies the given status to it.
+func (c *cleaner) applyStatusResource(ctx context.Context, status map[string]any) error {
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": statusResource.GroupVersion().String(),
+		"kind":       "ClusterCleanerStatus",
+		"metadata":   map[string]any{"name": statusResourceName},
+	}}
+	client := c.health.client.Resource(statusResource)
+	_, err := client.Apply(ctx, statusResourceName, obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+	if err != nil {
+		return fmt.Errorf("applying: %w", err)
+	}
+
+	obj.Object["status"] = status
+	_, err = client.ApplyStatus(ctx, statusResourceName, obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+	if err != nil {
+		return fmt.Errorf("applying status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// preferredNodesAnnotation lists the nodes with the most spare local capacity
+// on the replacement claim and pending pods of a cleaned up stateful set
+// claim, for scheduler extenders and placement policies to prefer over
+// bouncing between full nodes.
+const preferredNodesAnnotation = "local-pvc-cleaner.io/preferred-nodes"
+
+// nodeCapacity is the local storage a node has left for a claim.
+type nodeCapacity struct {
+	name string
+	used resource.Quantity
+}
+
+// schedulableNode reports whether new pods can be placed on a node.
+func schedulableNode(node *corev1.Node) bool {
+	if node.Spec.Unschedulable || hasTaint(node, stringList{toBeDeletedTaint}) {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// preferredNodes returns the schedulable nodes with the least local storage
+// bound to them, derived from the local volumes pinned to each of them. With a
+// known local capacity per node, nodes without room for the claim are left out.
+func (c *cleaner) preferredNodes(pvc *corev1.PersistentVolumeClaim, gone []string) []string {
+	nodes, err := c.factory.Core().V1().Nodes().Lister().List(labels.Everything())
+	if err != nil {
+		return nil
+	}
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+
+	var capacities []nodeCapacity
+	for _, node := range nodes {
+		if stringList(gone).contains(node.Name) || !schedulableNode(node) {
+			continue
+		}
+		pvs, err := c.factory.Core().V1().PersistentVolumes().Informer().GetIndexer().ByIndex(pvByNodeIndex, node.Name)
+		if err != nil {
+			continue
+		}
+		used := resource.Quantity{}
+		for _, pvAny := range pvs {
+			pv := pvAny.(*corev1.PersistentVolume)
+			if !c.localVolume(pv) || !stringList(c.volumeNodes(pv)).contains(node.Name) {
+				continue
+			}
+			used.Add(pv.Spec.Capacity[corev1.ResourceStorage])
+		}
+		if !c.nodeLocalCapacity.IsZero() {
+			spare := c.nodeLocalCapacity.DeepCopy()
+			spare.Sub(used)
+			if spare.Cmp(requested) < 0 {
+				continue
+			}
+		}
+		capacities = append(capacities, nodeCapacity{name: node.Name, used: used})
+	}
+
+	sort.Slice(capacities, func(i, j int) bool {
+		if cmp := capacities[i].used.Cmp(capacities[j].used); cmp != 0 {
+			return cmp < 0
+		}
+		return capacities[i].name < capacities[j].name
+	})
+	if len(capacities) > c.capacityHints {
+		capacities = capacities[:c.capacityHints]
+	}
+	preferred := make([]string, 0, len(capacities))
+	for _, capacity := range capacities {
+		preferred = append(preferred, capacity.name)
+	}
+	return preferred
+}
+
+// hintPlacement annotates the unbound replacement of a cleaned up stateful set
+// claim and the pending pods waiting for it with the nodes preferred for it.
+func (c *cleaner) hintPlacement(ctx context.Context, pvc *corev1.PersistentVolumeClaim, gone []string) {
+	preferred := c.preferredNodes(pvc, gone)
+	if len(preferred) == 0 {
+		logf(ctx, "no node has spare local capacity for pvc(%s)\n", pvc.Name)
+		return
+	}
+	hint := strings.Join(preferred, ",")
+
+	client, err := c.clientFor(pvc.Namespace)
+	if err != nil {
+		logf(ctx, "failed to get client for namespace(%s): %v\n", pvc.Namespace, err)
+		return
+	}
+	replacement, err := client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(ctx, pvc.Name, metav1.GetOptions{})
+	if err == nil && replacement.UID != pvc.UID && replacement.Spec.VolumeName == "" {
+		err = c.patchClaimAnnotations(ctx, replacement, map[string]*string{preferredNodesAnnotation: &hint})
+		if err != nil {
+			logf(ctx, "failed to annotate pvc(%s) with preferred nodes: %v\n", pvc.Name, err)
+		}
+	}
+
+	pods, err := c.consumerPods(pvc)
+	if err != nil {
+		return
+	}
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodPending || pod.Spec.NodeName != "" {
+			continue
+		}
+		err = c.patchPodAnnotations(ctx, pod, map[string]*string{preferredNodesAnnotation: &hint})
+		if err != nil {
+			logf(ctx, "failed to annotate pod(%s) with preferred nodes: %v\n", pod.Name, err)
+		}
+	}
+	logf(ctx, "preferred nodes(%s) for the replacement of pvc(%s)\n", hint, pvc.Name)
+}
+
+// patchPodAnnotations merges the given annotations into a pod, removing the
+// ones set to nil.
+func (c *cleaner) patchPodAnnotations(ctx context.Context, pod *corev1.Pod, annotations map[string]*string) error {
+	client, err := c.clientFor(pod.Namespace)
+	if err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// namespaceClients hands out clientsets impersonating a service account in the
+// namespace of the objects they act on.
+type namespaceClients struct {
+	config         *rest.Config
+	serviceAccount string
+
+	mu      sync.Mutex
+	clients map[string]kubernetes.Interface
+}
+
+func (n *namespaceClients) get(namespace string) (kubernetes.Interface, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if client, ok := n.clients[namespace]; ok {
+		return client, nil
+	}
+
+	config := rest.CopyConfig(n.config)
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: fmt.Sprintf("system:serviceaccount:%s:%s", namespace, n.serviceAccount),
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	n.clients[namespace] = client
+	return client, nil
+}
+
+// clientFor returns the clientset used to modify objects in the namespace.
+func (c *cleaner) clientFor(namespace string) (kubernetes.Interface, error) {
+	if c.namespaceClients == nil {
+		return c.clientset, nil
+	}
+	return c.namespaceClients.get(namespace)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// addIndexers registers the indexes the cleaner looks up claims, volumes and
+// pods by, and the informers it reads from without an index. The claim and
+// volume indexes must not depend on the reloadable configuration: the store
+// recomputes the old index values of an object when it changes, so they are
+// supersets filtered at lookup.
+func (c *cleaner) addIndexers() {
+	if c.podFactory != nil {
+		podInformer := c.podFactory.Core().V1().Pods().Informer()
+		podInformer.AddIndexers(cache.Indexers{
+			podByPvcIndex: func(obj any) ([]string, error) {
+				pod := obj.(*corev1.Pod)
+				claimNames := podClaimNames(pod)
+				pvcs := make([]string, 0, len(claimNames))
+				for _, claimName := range claimNames {
+					pvcs = append(pvcs, pod.Namespace+"/"+claimName)
+				}
+
+				return pvcs, nil
+			},
+		})
+	}
+
+	pvcInformer := c.factory.Core().V1().PersistentVolumeClaims().Informer()
+	pvcInformer.AddIndexers(cache.Indexers{
+		pvcByNodeIndex: func(obj any) ([]string, error) {
+			pvc := obj.(*corev1.PersistentVolumeClaim)
+			nodeName := pvc.Annotations[selectedNodeAnnotation]
+			if nodeName == "" {
+				return nil, nil
+			}
+			return []string{c.translateNode(nodeName)}, nil
+		},
+		pvcByDataSourceIndex: func(obj any) ([]string, error) {
+			pvc := obj.(*corev1.PersistentVolumeClaim)
+			source := claimDataSource(pvc)
+			if source == "" {
+				return nil, nil
+			}
+			return []string{source}, nil
+		},
+	})
+
+	pvInformer := c.factory.Core().V1().PersistentVolumes().Informer()
+	pvInformer.AddIndexers(cache.Indexers{
+		pvByNodeIndex: func(obj any) ([]string, error) {
+			pv := obj.(*corev1.PersistentVolume)
+			return c.translateNodes(indexedVolumeNodes(pv)), nil
+		},
+	})
+
+	c.addConfidenceIndexers()
+
+	c.factory.Storage().V1().StorageClasses().Informer()
+	if c.cacheNamespaces {
+		c.factory.Core().V1().Namespaces().Informer()
+	}
+	if c.workloadProtection {
+		c.factory.Apps().V1().StatefulSets().Informer()
+		c.factory.Apps().V1().Deployments().Informer()
+	}
+	if c.deleteVolumeAttachments {
+		c.factory.Storage().V1().VolumeAttachments().Informer()
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+const (
+	// inventoryConfigMapName is the configmap in the controller namespace
+	// holding the nodes seen by the cleaner.
+	inventoryConfigMapName = "local-pvc-cleaner-nodes"
+	inventoryKey           = "nodes"
+	// maxInventoryNodes bounds the inventory so it fits in a configmap.
+	maxInventoryNodes = 5000
+)
+
+const (
+	// causeDeletedWhileDown is the cause of nodes in the inventory whose
+	// deletion the cleaner did not observe.
+	causeDeletedWhileDown = "deleted-while-down"
+	// causeNeverSeen is the cause of nodes that are not in the inventory.
+	causeNeverSeen = "never-seen"
+)
+
+type inventoryNode struct {
+	UID        types.UID `json:"uid"`
+	ProviderID string    `json:"providerID,omitempty"`
+	// Machine is the namespace/name of the Cluster API machine of the node.
+	Machine  string    `json:"machine,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// nodeInventory is the last known state of the nodes seen by the cleaner,
+// persisted so a restarted cleaner tells nodes deleted while it was down from
+// nodes that never existed in the cluster.
+type nodeInventory struct {
+	retention time.Duration
+
+	mu    sync.Mutex
+	nodes map[string]inventoryNode
+}
+
+func newNodeInventory(retention time.Duration) *nodeInventory {
+	return &nodeInventory{retention: retention, nodes: map[string]inventoryNode{}}
+}
+
+// observe records a node as seen now.
+func (i *nodeInventory) observe(node *corev1.Node) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	entry := inventoryNode{UID: node.UID, ProviderID: node.Spec.ProviderID, LastSeen: time.Now().UTC()}
+	if machine := node.Annotations[clusterAPIMachineAnnotation]; machine != "" {
+		entry.Machine = node.Annotations[clusterAPINamespaceAnnotation] + "/" + machine
+	}
+	i.nodes[node.Name] = entry
+}
+
+// known reports whether a node was seen before.
+func (i *nodeInventory) known(nodeName string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	_, ok := i.nodes[nodeName]
+	return ok
+}
+
+// knowsAny reports whether one of the given nodes was seen before.
+func (i *nodeInventory) knowsAny(nodeNames []string) bool {
+	for _, nodeName := range nodeNames {
+		if i.known(nodeName) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadInventory reads the persisted inventory, keeping the nodes observed
+// meanwhile.
+func (c *cleaner) loadInventory(ctx context.Context) error {
+	cm, err := c.clientset.CoreV1().ConfigMaps(c.namespace).Get(ctx, inventoryConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	nodes := map[string]inventoryNode{}
+	err = json.Unmarshal([]byte(cm.Data[inventoryKey]), &nodes)
+	if err != nil {
+		return err
+	}
+
+	c.inventory.mu.Lock()
+	defer c.inventory.mu.Unlock()
+	for name, node := range nodes {
+		if _, ok := c.inventory.nodes[name]; !ok {
+			c.inventory.nodes[name] = node
+		}
+	}
+	return nil
+}
+
+// saveInventory marks the current nodes as seen now and persists the
+// inventory, forgetting the nodes not seen within the retention and the
+// oldest ones beyond maxInventoryNodes.
+func (c *cleaner) saveInventory(ctx context.Context) error {
+	if c.inventory == nil {
+		return nil
+	}
+
+	nodes, err := c.factory.Core().V1().Nodes().Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		c.inventory.observe(node)
+	}
+
+	c.inventory.mu.Lock()
+	cutoff := time.Now().Add(-c.inventory.retention)
+	names := make([]string, 0, len(c.inventory.nodes))
+	for name, node := range c.inventory.nodes {
+		if c.inventory.retention > 0 && node.LastSeen.Before(cutoff) {
+			delete(c.inventory.nodes, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		return c.inventory.nodes[names[a]].LastSeen.After(c.inventory.nodes[names[b]].LastSeen)
+	})
+	for _, name := range names[min(len(names), maxInventoryNodes):] {
+		delete(c.inventory.nodes, name)
+	}
+	value, err := json.Marshal(c.inventory.nodes)
+	c.inventory.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	apply := applycorev1.ConfigMap(inventoryConfigMapName, c.namespace).WithData(map[string]string{inventoryKey: string(value)})
+	_, err = c.clientset.CoreV1().ConfigMaps(c.namespace).Apply(ctx, apply, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+	return err
+}
+
+// inventoryCause returns the cause of a missing node whose deletion the
+// cleaner did not observe, or an empty string without an inventory.
+func (c *cleaner) inventoryCause(nodeName string) string {
+	if c.inventory == nil {
+		return ""
+	}
+	if _, err := c.factory.Core().V1().Nodes().Lister().Get(nodeName); err == nil {
+		return ""
+	}
+	if c.inventory.known(nodeName) {
+		return causeDeletedWhileDown
+	}
+	return causeNeverSeen
+}
+
+type inventoryEntry struct {
+	Name string `json:"name"`
+	inventoryNode
+	Present bool `json:"present"`
+}
+
+// handleNodes lists the node inventory.
+func (c *cleaner) handleNodes(w http.ResponseWriter, r *http.Request) {
+	entries := []inventoryEntry{}
+	if c.inventory != nil {
+		c.inventory.mu.Lock()
+		for name, node := range c.inventory.nodes {
+			entries = append(entries, inventoryEntry{Name: name, inventoryNode: node})
+		}
+		c.inventory.mu.Unlock()
+	}
+	for i := range entries {
+		_, err := c.factory.Core().V1().Nodes().Lister().Get(entries[i].Name)
+		entries[i].Present = err == nil
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"nodes": entries})
+}
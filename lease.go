@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodeLeaseNamespace holds the leases the kubelets renew as heartbeat.
+const nodeLeaseNamespace = "kube-node-lease"
+
+// renewedNode returns the first of the given nodes whose kubelet renewed its
+// lease within the lease freshness, or an empty string when none did.
+func (c *cleaner) renewedNode(ctx context.Context, nodeNames []string) (string, error) {
+	for _, nodeName := range nodeNames {
+		lease, err := c.clientset.CoordinationV1().Leases(nodeLeaseNamespace).Get(ctx, nodeName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if lease.Spec.RenewTime == nil {
+			continue
+		}
+
+		age := time.Since(lease.Spec.RenewTime.Time)
+		tracef("node(%s) renewed its lease %s ago\n", nodeName, age)
+		if age < c.leaseFreshness {
+			return nodeName, nil
+		}
+	}
+	return "", nil
+}
+
+// leaseDecision aborts the cleanup of an orphan when the kubelet of one of its
+// nodes still renews its lease, which means the node is alive despite being
+// gone from the api.
+func (c *cleaner) leaseDecision(ctx context.Context, cand candidate) decision {
+	if c.leaseFreshness <= 0 || c.virtualCluster {
+		return ""
+	}
+
+	nodeName, err := c.renewedNode(ctx, cand.nodes)
+	if err != nil {
+		logf(ctx, "failed to get leases of nodes(%s): %v\n", strings.Join(cand.nodes, ","), err)
+		return decisionFailed
+	}
+	if nodeName == "" {
+		return ""
+	}
+
+	logf(ctx, "warning: node(%s) of pvc(%s/%s) is gone but renewed its lease, not cleaning up\n", nodeName, cand.pvc.Namespace, cand.pvc.Name)
+	if !simulated(ctx) {
+		nodeLeaseAborts.Inc()
+	}
+	c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "NodeLeaseRenewed", "node %s is gone but its kubelet renewed its lease within %s", nodeName, c.leaseFreshness)
+	return decisionSkippedLeaseRenewed
+}
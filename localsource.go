@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// localVolume reports whether a volume is backed by storage on a node: a local
+// or host path volume, one of a CSI driver configured as local provisioner, or
+// a CSI volume whose node affinity pins it with one of the topology keys.
+func (c *cleaner) localVolume(pv *corev1.PersistentVolume) bool {
+	source := pv.Spec.PersistentVolumeSource
+	if source.Local != nil || source.HostPath != nil {
+		return true
+	}
+	if source.CSI == nil {
+		return false
+	}
+	return c.localProvisioner(source.CSI.Driver) || len(affinityNodes(pv, c.volumeTopologyKeys())) > 0
+}
+
+// volumeSourceDecision skips claims bound to a volume that is not local, like
+// an NFS or ceph volume of a claim that kept the annotations of a local
+// provisioner after a migration, whose data survives the loss of the node.
+func (c *cleaner) volumeSourceDecision(ctx context.Context, cand candidate) decision {
+	if cand.pvc.Spec.VolumeName == "" {
+		return ""
+	}
+	pv, err := c.factory.Core().V1().PersistentVolumes().Lister().Get(cand.pvc.Spec.VolumeName)
+	if err != nil {
+		return ""
+	}
+	if c.localVolume(pv) {
+		return ""
+	}
+
+	logf(ctx, "warning: pvc(%s/%s) is bound to pv(%s) without a local source, skipping\n", cand.pvc.Namespace, cand.pvc.Name, pv.Name)
+	c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "NonLocalVolume", "bound to pv %s which is not a local volume, the cleaner leaves it alone", pv.Name)
+	return decisionSkippedNonLocalVolume
+}
@@ -0,0 +1,42 @@
+package main
+
+import "fmt"
+
+type logLevel int
+
+const (
+	logLevelInfo logLevel = iota
+	logLevelTrace
+)
+
+var currentLogLevel = logLevelInfo
+
+func (l *logLevel) String() string {
+	switch *l {
+	case logLevelTrace:
+		return "trace"
+	default:
+		return "info"
+	}
+}
+
+func (l *logLevel) Set(value string) error {
+	switch value {
+	case "info":
+		*l = logLevelInfo
+	case "trace":
+		*l = logLevelTrace
+	default:
+		return fmt.Errorf("unknown log level %q", value)
+	}
+	return nil
+}
+
+// tracef prints the evaluation details that are only useful when debugging why
+// a pvc was or was not matched.
+func tracef(format string, args ...any) {
+	if currentLogLevel < logLevelTrace {
+		return
+	}
+	fmt.Printf("trace: "+format, args...)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// lostClaim reports whether the volume of a claim was removed out of band,
+// which leaves the claim in the Lost phase for good.
+func lostClaim(pvc *corev1.PersistentVolumeClaim) bool {
+	return pvc.Status.Phase == corev1.ClaimLost
+}
+
+// lostDecision decides on a lost claim whose node still exists or is unknown.
+// Such claims never recover and keep the pods of their StatefulSet pending, so
+// they are cleaned up like orphans once lost claims are to be cleaned up.
+func (c *cleaner) lostDecision(ctx context.Context, cand candidate) decision {
+	logf(ctx, "pvc(%s/%s) lost its volume(%s)\n", cand.pvc.Namespace, cand.pvc.Name, cand.pvc.Spec.VolumeName)
+	if !c.cleanLostClaims {
+		return decisionSkippedLost
+	}
+	return c.policyDecision(ctx, cand)
+}
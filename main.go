@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -22,152 +29,654 @@ const (
 	provisionerAnnotation    = "volume.kubernetes.io/storage-provisioner"
 	expectedProvisionerValue = "rancher.io/local-path"
 	pvcByNodeIndex           = "pvcByNode"
+	pvByNodeIndex            = "pvByNode"
 	podByPvcIndex            = "podByPvc"
+	pvcByDataSourceIndex     = "pvcByDataSource"
+	excludeNodeKey           = "local-pvc-cleaner.io/exclude"
 )
 
-func deleteVolumes(ctx context.Context, clientset *kubernetes.Clientset, factory informers.SharedInformerFactory, pvc *corev1.PersistentVolumeClaim) {
-	err := clientset.CoreV1().PersistentVolumeClaims(pvc.Namespace).Delete(ctx, pvc.Name, metav1.DeleteOptions{})
-	if err != nil {
-		fmt.Printf("failed to delete pvc(%s): %v\n", pvc.Name, err)
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "tui" {
+		runTUI(os.Args[2:])
 		return
 	}
-	fmt.Printf("deleted pvc(%s)\n", pvc.Name)
 
-	pvName := pvc.Spec.VolumeName
-	if pvName == "" {
-		fmt.Printf("pvc(%s) is not bound to a volume\n", pvc.Name)
+	if len(os.Args) > 1 && os.Args[1] == "cancel" {
+		runCancel(os.Args[2:])
 		return
 	}
 
-	err = clientset.CoreV1().PersistentVolumes().Delete(ctx, pvName, metav1.DeleteOptions{})
-	if err != nil {
-		fmt.Printf("failed to delete pv(%s): %v\n", pvName, err)
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		runSimulate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "clean" {
+		runClean(os.Args[2:])
 		return
 	}
 
-	fmt.Printf("deleted pv(%s)\n", pvName)
-
-	pods, err := factory.Core().V1().Pods().Informer().GetIndexer().ByIndex(podByPvcIndex, pvc.Name)
-	if err != nil {
-		fmt.Printf("error getting pods from index: %v\n", err)
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		runCheck(os.Args[2:])
 		return
 	}
 
-	for _, podAny := range pods {
-		pod := podAny.(*corev1.Pod)
-		err = clientset.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
-		if err != nil {
-			fmt.Printf("failed to delete pod(%s): %v\n", pod.Name, err)
-			continue
-		}
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		runBench(os.Args[2:])
+		return
+	}
 
-		fmt.Printf("deleted pod(%s)\n", pod.Name)
+	// diff takes the same flags as the cleaner and reports what it would do
+	diff := len(os.Args) > 1 && os.Args[1] == "diff"
+	if diff {
+		os.Args = append(os.Args[:1:1], os.Args[2:]...)
 	}
-}
 
-func cleanupVolumesByNode(ctx context.Context, clientset *kubernetes.Clientset, nodeName string, factory informers.SharedInformerFactory) {
-	persistentVolumeClaims, err := factory.Core().V1().PersistentVolumeClaims().Informer().GetIndexer().ByIndex(pvcByNodeIndex, nodeName)
-	if err != nil {
-		fmt.Printf("error getting pvc from index: %v\n", err)
-		return
+	topologyKeys := stringList{"topology.hostpath.csi/node"}
+	flag.Var(&topologyKeys, "topology-keys", "comma separated node topology keys used to find the node of csi volumes")
+	nodeMappingConfigMap := flag.String("node-mapping", "", "namespace/name of a configmap mapping removed node names to the node their volumes moved to")
+	recreateClaims := flag.Bool("recreate-statefulset-pvcs", false, "recreate deleted stateful set pvcs without their node binding")
+	recreateTimeout := flag.Duration("recreate-timeout", 2*time.Minute, "how long to wait for a deleted pvc to be removed before recreating it")
+	listenAddress := flag.String("listen-address", ":8080", "address to serve metrics and the status api on, empty to disable")
+	tlsCertFile := flag.String("tls-cert-file", "", "tls certificate to serve the api with")
+	tlsKeyFile := flag.String("tls-key-file", "", "tls key to serve the api with")
+	clientCAFile := flag.String("client-ca-file", "", "ca whose client certificates authorize api requests, needs --tls-cert-file and --tls-key-file")
+	grpcAddress := flag.String("grpc-address", "", "address to serve the grpc admin api on, empty to disable")
+	publicReadEndpoints := flag.Bool("public-read-endpoints", false, "serve the read only api endpoints, like /v1/status, without the api token or a client certificate")
+	namespace := flag.String("namespace", os.Getenv("POD_NAMESPACE"), "namespace of the controller configmap that events about the cleaner are recorded on")
+	reconcileInterval := flag.Duration("reconcile-interval", 0, "how often to run a full reconcile, zero to only reconcile on startup and when triggered")
+	apiTokenFile := flag.String("api-token-file", "", "file containing the bearer token required by the api endpoints")
+	podAction := flag.String("pod-action", podActionDelete, "how to remove pods consuming cleaned up pvcs, one of delete or evict")
+	impersonateUser := flag.String("as", "", "user to impersonate for all api requests")
+	var impersonateGroups stringList
+	flag.Var(&impersonateGroups, "as-group", "comma separated groups to impersonate for all api requests")
+	namespaceServiceAccount := flag.String("namespace-service-account", "", "service account to impersonate in the namespace of each deleted pvc and pod")
+	vetoURL := flag.String("veto-url", "", "url of a webhook asked to allow, deny or delay each cleanup")
+	vetoFailOpen := flag.Bool("veto-fail-open", false, "clean up when the veto webhook cannot be reached")
+	vetoTimeout := flag.Duration("veto-timeout", 10*time.Second, "timeout of veto webhook requests")
+	order := flag.String("order", orderPriority, "order pvcs are cleaned up in, one of priority, namespace, size or name")
+	metricsByNamespace := flag.Bool("metrics-namespace-label", true, "break pvc deletion metrics down by namespace")
+	metricsByStorageClass := flag.Bool("metrics-storage-class-label", false, "break pvc deletion metrics down by storage class")
+	metricsMaxLabelValues := flag.Int("metrics-max-label-values", 100, "maximum distinct values of each metric label before the rest are reported as other, zero for no limit")
+	cleanupSLO := flag.Duration("cleanup-slo", 0, "log a warning when cleaning up a deleted node takes longer than this, zero to disable")
+	gracePeriod := flag.Duration("grace-period", 0, "how long to quarantine pvcs of a missing node before cleaning them up, zero to clean up immediately")
+	maxAttempts := flag.Int("max-attempts", 5, "failed cleanup attempts after which an object is blacklisted, zero to retry forever")
+	webhookAddress := flag.String("webhook-address", "", "address to serve the pvc admission webhook on, empty to disable")
+	webhookCertFile := flag.String("webhook-cert-file", "", "tls certificate of the admission webhook")
+	webhookKeyFile := flag.String("webhook-key-file", "", "tls key of the admission webhook")
+	var protectedNamespaces stringList
+	flag.Var(&protectedNamespaces, "protected-namespaces", "comma separated namespaces never cleaned up in addition to kube-system, kube-public and kube-node-lease")
+	cleanProtectedNamespaces := flag.Bool("clean-protected-namespaces", false, "also clean up pvcs in protected namespaces")
+	breakerErrorRate := flag.Float64("breaker-error-rate", 0.5, "fraction of failed delete calls over the breaker window that pauses cleanup, zero to disable")
+	breakerWindow := flag.Duration("breaker-window", 5*time.Minute, "window the delete error rate is computed over")
+	breakerMinRequests := flag.Int("breaker-min-requests", 10, "delete calls needed in the window before the breaker can open")
+	breakerProbeInterval := flag.Duration("breaker-probe-interval", time.Minute, "how often to probe the api while the breaker is open")
+	mode := flag.String("mode", modeClean, "clean to clean up orphans, report to only report them, external to label them and leave deleting them to an external deleter")
+	deletePVCs := flag.Bool("delete-pvc", true, "delete the pvcs of removed nodes")
+	deletePVs := flag.Bool("delete-pv", true, "delete the pvs of removed nodes instead of leaving them to the provisioner")
+	deletePods := flag.Bool("delete-pods", true, "remove the pods consuming the pvcs of removed nodes")
+	deleteVolumeAttachments := flag.Bool("delete-volumeattachments", false, "delete the volume attachments of cleaned up pvs to removed nodes")
+	provisionerTimeout := flag.Duration("provisioner-timeout", 0, "how long to wait for the provisioner to reclaim the pv of a deleted pvc before deleting it directly, zero to delete it right away")
+	stepTimeout := flag.Duration("step-timeout", 2*time.Minute, "how long to wait for the pods, pvc and pv of a cleanup to be gone before the cleanup fails")
+	leaseFreshness := flag.Duration("lease-freshness", 0, "do not clean up nodes whose kubelet renewed its lease within this duration, zero to disable")
+	notifyURL := flag.String("notify-url", "", "url of a webhook notified about cleanups")
+	notifyTemplate := flag.String("notify-template", "", "go template file rendering the notification payload, json by default")
+	notifyContentType := flag.String("notify-content-type", "application/json", "content type of rendered notifications")
+	smtpAddress := flag.String("smtp-address", "", "host:port of a mail server notified about cleanups")
+	smtpTLS := flag.String("smtp-tls", smtpTLSStartTLS, "how to secure the mail server connection, one of starttls, tls or none")
+	smtpUsername := flag.String("smtp-username", "", "user to authenticate to the mail server as")
+	smtpPasswordFile := flag.String("smtp-password-file", "", "file containing the mail server password")
+	smtpFrom := flag.String("smtp-from", "local-pvc-cleaner", "sender address of notification mails")
+	var smtpTo stringList
+	flag.Var(&smtpTo, "smtp-to", "comma separated addresses notification mails are sent to")
+	smtpTemplate := flag.String("smtp-template", "", "go template file rendering the notification mail body, a plain text summary by default")
+	once := flag.Bool("once", false, "run a single reconcile and exit, for cron jobs")
+	pushgatewayURL := flag.String("pushgateway-url", "", "pushgateway to push the metrics of a --once run to before exiting")
+	metricsTextfile := flag.String("metrics-textfile", "", "file to write the metrics of a --once run to before exiting")
+	podLabelSelector := flag.String("pod-label-selector", "", "label selector narrowing the watched pods to the ones that may consume local pvcs")
+	podFieldSelector := flag.String("pod-field-selector", "", "field selector narrowing the watched pods to the ones that may consume local pvcs")
+	managePods := flag.Bool("manage-pods", true, "watch and remove the pods consuming cleaned up pvcs, false to leave pods to their controllers and save the memory of the pod watch")
+	tombstoneLimit := flag.Int("tombstones", 0, "number of deleted pvcs to list in the local-pvc-cleaner-tombstones configmap of their namespace, zero to disable")
+	cleanMissingStorageClass := flag.Bool("clean-missing-storage-class", false, "also clean up pvcs without a local provisioner annotation whose storage class does not exist when their node is gone")
+	nodePoolLabels := append(stringList{}, defaultNodePoolLabels...)
+	flag.Var(&nodePoolLabels, "node-pool-labels", "comma separated node labels naming the node pool a node belongs to, used to pause deletions per node pool")
+	quarantineOnScaleDown := flag.Bool("quarantine-on-scale-down", false, "start the grace period of the pvcs of a node once cluster-autoscaler taints it for removal")
+	prestageTerminations := flag.Bool("prestage-terminations", false, "end the grace period of the pvcs of a spot or preemptible node once it is tainted for termination, so they are cleaned up right after it is deleted")
+	terminationTaints := append(stringList{}, defaultTerminationTaints...)
+	flag.Var(&terminationTaints, "termination-taints", "comma separated taint keys announcing the termination of a spot or preemptible node")
+	workloadProtection := flag.Bool("workload-protection", false, "watch statefulsets and deployments and skip the pvcs of the ones annotated with local-pvc-cleaner.io/protect-pvcs")
+	minCleanupInterval := flag.Duration("min-cleanup-interval", 0, "refuse to clean up a pvc name in a namespace again within this interval, zero to disable")
+	stuckThreshold := flag.Duration("stuck-threshold", 10*time.Minute, "report objects deleted by the cleaner that are still terminating after this long on every reconcile, zero to disable")
+	redact := flag.Bool("redact", false, "hash namespace and pvc names in metrics, notifications and the api")
+	redactKeyFile := flag.String("redact-key-file", "", "file containing the key the redacted names are hashed with, so they cannot be guessed")
+	nodeInventory := flag.Bool("node-inventory", false, "persist the nodes seen in the local-pvc-cleaner-nodes configmap of --namespace to tell nodes deleted while the cleaner was down from nodes that never existed")
+	nodeInventoryRetention := flag.Duration("node-inventory-retention", 30*24*time.Hour, "how long nodes stay in the inventory after they were last seen")
+	flag.Bool("skip-never-seen-nodes", true, "deprecated, pvcs whose nodes were never seen are skipped unless --never-seen-node-policy is clean")
+	neverSeenPolicy := flag.String("never-seen-node-policy", neverSeenReport, "report to only report pvcs whose nodes never existed in this cluster, like restores from other clusters, clean to clean them up like pvcs of deleted nodes")
+	minConfidence := flag.Int("min-confidence", 0, "only clean up pvcs whose nodes score at least this confidence out of 100 from the node-absent, lease-stale, no-pods-reporting, removal-expected and machine-gone signals, zero to disable")
+	autoCleanSelector := flag.String("auto-clean-selector", "", "label selector over the labels and annotations of a pvc and its pv, the topology of the pv and local-pvc-cleaner.io/node-pool, only matching pvcs are cleaned up automatically while the others wait for approval")
+	artifactRetention := flag.Duration("artifact-retention", 0, "remove tombstones and the annotations of pvcs the cleaner no longer manages once they are older than this, zero to keep them")
+	cleanLostClaims := flag.Bool("clean-lost-pvcs", false, "also clean up pvcs in the Lost phase whose node still exists")
+	reclaimMode := flag.String("reclaim-mode", reclaimTimeout, "timeout to wait --provisioner-timeout for the provisioner to reclaim pvs, direct to delete them right away, auto to wait for the provisioner only while its deployment is available")
+	provisionerDeployment := flag.String("provisioner-deployment", "local-path-storage/local-path-provisioner", "namespace/name of the provisioner deployment checked by the auto reclaim mode")
+	transactionLog := flag.Bool("transaction-log", false, "record the planned and completed steps of each node cleanup in the local-pvc-cleaner-transactions configmap of --namespace and resume cleanups a crash interrupted")
+	reportStatus := flag.Bool("status-resource", false, "report the health of the controller on the local-pvc-cleaner ClusterCleanerStatus resource after every reconcile")
+	recoveryTimeout := flag.Duration("recovery-timeout", 0, "track the stateful set pods of cleaned up pvcs for up to this long and report when they are ready with a bound replacement pvc, zero to disable")
+	podBatchSize := flag.Int("pod-batch-size", 0, "remove at most this many consumer pods per --pod-batch-delay across node cleanups, zero to remove them all at once")
+	podBatchDelay := flag.Duration("pod-batch-delay", 10*time.Second, "delay between the starts of pod removal batches")
+	veleroRestores := flag.Bool("velero-restores", false, "pause cleanups in namespaces while a velero restore into them is in progress")
+	veleroNamespace := flag.String("velero-namespace", "velero", "namespace of the velero restores, empty for all namespaces")
+	activeConsumerApproval := flag.Bool("active-consumer-approval", true, "quarantine orphaned pvcs that still have running pods on existing nodes until their cleanup is approved")
+	reportFile := flag.String("report-file", "", "write a json report of the decisions, durations and errors of each full reconcile to this file")
+	cacheNamespaces := flag.Bool("cache-namespaces", false, "watch namespaces so their annotations are looked up from a cache instead of the api during cleanups")
+	ownerLabel := flag.String("owner-label", "team", "label of pvcs and their pods, or annotation or label of their namespace, naming the team owning them")
+	ownerRoutesFile := flag.String("owner-routes", "", "json file mapping owners to the webhook urls notified about cleanups of their pvcs")
+	cleanupPlan := flag.Bool("cleanup-plan", false, "in report mode, publish the pvcs that would be deleted on the local-pvc-cleaner CleanupPlan resource and delete the ones approved in its spec")
+	userAgent := flag.String("user-agent", "local-pvc-cleaner", "user agent of all api requests, to tell the cleaner apart in audit logs and api server metrics")
+	priorityGroup := flag.String("priority-group", "", "group to impersonate in addition to --as-group so a FlowSchema can assign the api requests of the cleaner their own priority level, needs --as")
+	deferUnderLoad := flag.Duration("defer-under-load", 0, "defer cleanups for this long after the api server rejected a request with 429 Too Many Requests, 0 to disable")
+	apiCAFile := flag.String("api-ca-file", "", "ca bundle to verify the api server certificate with instead of the one of the kubeconfig or service account")
+	apiClientCert := flag.String("api-client-cert", "", "client certificate to authenticate to the api server with, needs --api-client-key")
+	apiClientKey := flag.String("api-client-key", "", "key of the --api-client-cert")
+	apiProxy := flag.String("api-proxy", "", "http, https or socks5 proxy url to reach the api server through, credentials in the url authenticate to the proxy")
+	apiServerName := flag.String("api-server-name", "", "server name to request and verify the api server certificate for, when it differs from the host")
+	forceDeleteStuckPods := flag.Bool("force-delete-stuck-pods", false, "force delete the pods of cleaned up pvcs that are stuck in the Unknown phase or terminating on the gone nodes, releasing the pvc-protection finalizer, needs --manage-pods")
+	churnThreshold := flag.Int("churn-threshold", 0, "number of node deletions within --churn-window that lengthen the grace period by --churn-grace-factor, 0 to disable")
+	churnWindow := flag.Duration("churn-window", time.Hour, "window node deletions are counted in for --churn-threshold")
+	churnGraceFactor := flag.Float64("churn-grace-factor", 3, "factor the grace period is lengthened by while node churn is high")
+	nodeNamePattern := flag.String("node-name-pattern", "", "regular expression matching the node names of volumes and pvcs to rewrite into the names of node objects, for virtual clusters naming nodes differently than the host")
+	nodeNameReplacement := flag.String("node-name-replacement", "", "replacement of --node-name-pattern, referencing its groups like $1")
+	nodeNamesFile := flag.String("node-names-file", "", "json file mapping the node names of volumes and pvcs to the names of node objects")
+	virtualCluster := flag.Bool("vcluster", false, "run in a vcluster: only trust its node objects while it syncs the real host nodes and skip the node lease and machine signals")
+	capacityHints := flag.Int("capacity-hints", 0, "annotate the replacement pvcs and pending pods of cleaned up stateful set pvcs with this many nodes with the most spare local capacity, 0 to disable")
+	nodeLocalCapacity := flag.String("node-local-capacity", "", "local storage capacity of each node, leaving nodes without room for a pvc out of the capacity hints")
+	usageInterval := flag.Duration("usage-interval", 0, "sample the used bytes of volumes from the kubelet stats of every node this often, to report the data discarded by cleanups, 0 to disable")
+	usagePrometheusURL := flag.String("usage-prometheus-url", "", "prometheus to query the used bytes of cleaned up volumes from kubelet_volume_stats_used_bytes, instead of sampling the kubelet stats")
+	batchDeletes := flag.Bool("batch-deletes", false, "delete the orphaned pvcs of a namespace on the same nodes with one delete collection call when a label selector selects exactly them, without --delete-pods")
+	cloneSourcePolicy := flag.String("clone-source-policy", cloneSourceDefer, "defer to keep pvcs that pvcs still being cloned from them use as data source until the clones are bound, warn to clean them up with a warning event")
+	renotifyInterval := flag.Duration("renotify-interval", 0, "notify about pvcs that keep failing or waiting to be cleaned up once per this interval, with a still blocked message, instead of with every cleanup, 0 to notify every time")
+	printRBAC := flag.Bool("print-rbac", false, "print the ClusterRole and Roles the configuration needs and exit, without calling the api server")
+	flag.Var(&currentLogLevel, "log-level", "log level, one of info or trace")
+	flag.Parse()
+	if *once {
+		defer exitOnPanic()
 	}
-	for _, pvcAny := range persistentVolumeClaims {
-		pvc := pvcAny.(*corev1.PersistentVolumeClaim)
-		deleteVolumes(ctx, clientset, factory, pvc)
+	if diff {
+		*mode = modeReport
+		*listenAddress = ""
+		*webhookAddress = ""
+	}
+	if *printRBAC {
+		*listenAddress = ""
+		*webhookAddress = ""
 	}
-}
 
-func main() {
-	// kubeconfig or in-cluster
-	var config *rest.Config
-	var err error
-	kubeConfig := os.Getenv("KUBECONFIG")
-	if kubeConfig != "" {
-		config, err = clientcmd.BuildConfigFromFlags("", kubeConfig)
-	} else {
-		config, err = rest.InClusterConfig()
+	config, err := restConfig()
+	if err != nil && *printRBAC {
+		// printing the permissions needs no api server
+		config, err = &rest.Config{}, nil
+	}
+	if err != nil {
+		panic(err)
 	}
+
+	err = apiEndpoint{
+		caFile:     *apiCAFile,
+		certFile:   *apiClientCert,
+		keyFile:    *apiClientKey,
+		proxyURL:   *apiProxy,
+		serverName: *apiServerName,
+	}.apply(config)
 	if err != nil {
 		panic(err)
 	}
+	config.UserAgent = *userAgent
+	if *priorityGroup != "" {
+		if *impersonateUser == "" {
+			panic("--priority-group needs --as")
+		}
+		impersonateGroups = append(impersonateGroups, *priorityGroup)
+	}
+	if *impersonateUser != "" || len(impersonateGroups) > 0 {
+		config.Impersonate = rest.ImpersonationConfig{
+			UserName: *impersonateUser,
+			Groups:   impersonateGroups,
+		}
+	}
+
+	if injectFaults != nil {
+		injectFaults(config)
+	}
+	var load *apiServerLoad
+	if *deferUnderLoad > 0 {
+		load = newAPIServerLoad(*deferUnderLoad)
+		load.wrap(config)
+	}
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		panic(err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err = labels.Parse(*podLabelSelector)
+	if err != nil {
+		panic(fmt.Sprintf("invalid pod label selector: %v", err))
+	}
+	_, err = fields.ParseSelector(*podFieldSelector)
+	if err != nil {
+		panic(fmt.Sprintf("invalid pod field selector: %v", err))
+	}
+
 	factory := informers.NewSharedInformerFactory(clientset, 0)
+	var podFactory informers.SharedInformerFactory
+	if *managePods {
+		podFactory = informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = *podLabelSelector
+			options.FieldSelector = *podFieldSelector
+		}))
+	}
+	c := &cleaner{
+		clientset:            clientset,
+		factory:              factory,
+		podFactory:           podFactory,
+		topologyKeys:         topologyKeys,
+		nodeMappingConfigMap: *nodeMappingConfigMap,
+		recreateClaims:       *recreateClaims,
+		recreateTimeout:      *recreateTimeout,
+		recoveryTimeout:      *recoveryTimeout,
+		decisions:            newDecisionLog(),
+		stats:                newCleanupStats(),
+		cleanupSLO:           *cleanupSLO,
+		gracePeriod:          *gracePeriod,
+		failures:             newFailureTracker(*maxAttempts),
+		breaker: &circuitBreaker{
+			errorRate:     *breakerErrorRate,
+			window:        *breakerWindow,
+			minRequests:   *breakerMinRequests,
+			probeInterval: *breakerProbeInterval,
+		},
+		mode: *mode,
 
-	podInformer := factory.Core().V1().Pods().Informer()
-	podInformer.AddIndexers(cache.Indexers{
-		podByPvcIndex: func(obj any) ([]string, error) {
-			pod := obj.(*corev1.Pod)
-			pvcs := make([]string, 0, len(pod.Spec.Volumes))
-			for _, volume := range pod.Spec.Volumes {
-				if volume.PersistentVolumeClaim == nil {
-					continue
-				}
+		deletePVCs:              *deletePVCs,
+		deletePVs:               *deletePVs,
+		deletePods:              *deletePods && *managePods,
+		deleteVolumeAttachments: *deleteVolumeAttachments,
+		provisionerTimeout:      *provisionerTimeout,
+		reclaimMode:             *reclaimMode,
+		provisionerDeployment:   *provisionerDeployment,
+		stepTimeout:             *stepTimeout,
+		leaseFreshness:          *leaseFreshness,
+		tombstoneLimit:          *tombstoneLimit,
 
-				claimName := volume.PersistentVolumeClaim.ClaimName
-				if claimName == "" {
-					continue
-				}
-				pvcs = append(pvcs, claimName)
-			}
+		cleanMissingStorageClass: *cleanMissingStorageClass,
+		cleanLostClaims:          *cleanLostClaims,
+		quarantineOnScaleDown:    *quarantineOnScaleDown,
+		prestageTerminations:     *prestageTerminations,
+		terminationTaints:        terminationTaints,
+		workloadProtection:       *workloadProtection,
+		minCleanupInterval:       *minCleanupInterval,
+		history:                  newCleanupHistory(),
+		stuckThreshold:           *stuckThreshold,
+		terminating:              newTerminatingObjects(),
+		nodePools:                newNodePools(nodePoolLabels),
+		expected:                 newExpectedNodes(),
+		cancelled:                newCancelledNodes(),
+		timers:                   newReconcileTimers(),
+		neverSeenPolicy:          *neverSeenPolicy,
+		seen:                     newSeenNodes(),
+		minConfidence:            *minConfidence,
+		artifactRetention:        *artifactRetention,
+		activeConsumerApproval:   *activeConsumerApproval,
+		reportFile:               *reportFile,
+		cacheNamespaces:          *cacheNamespaces,
+		forceDeleteStuckPods:     *forceDeleteStuckPods,
 
-			return pvcs, nil
+		protectedNamespaces:      protectedNamespaces,
+		cleanProtectedNamespaces: *cleanProtectedNamespaces,
+		namespace:                *namespace,
+		recorder:                 newEventRecorder(clientset),
+		reconcileCh:              make(chan struct{}, 1),
+		podAction:                *podAction,
+		order:                    *order,
+		deletionMetrics: &deletionMetrics{
+			byNamespace:    *metricsByNamespace,
+			byStorageClass: *metricsByStorageClass,
+			namespaces:     newLabelLimiter(*metricsMaxLabelValues),
+			storageClasses: newLabelLimiter(*metricsMaxLabelValues),
 		},
-	})
+	}
+
+	if *autoCleanSelector != "" {
+		c.autoCleanSelector, err = labels.Parse(*autoCleanSelector)
+		if err != nil {
+			panic(fmt.Sprintf("invalid auto clean selector: %v", err))
+		}
+	}
+
+	if c.mode != modeClean && c.mode != modeReport && c.mode != modeExternal {
+		panic(fmt.Sprintf("unknown mode %q", c.mode))
+	}
+	if c.neverSeenPolicy != neverSeenReport && c.neverSeenPolicy != neverSeenClean {
+		panic(fmt.Sprintf("unknown never seen node policy %q", c.neverSeenPolicy))
+	}
+	if c.externalDeletion() {
+		c.external = newExternalOrphans()
+	}
+	if *podBatchSize > 0 {
+		c.podBatches = &podBatches{size: *podBatchSize, delay: *podBatchDelay}
+	}
+	if c.reclaimMode != reclaimTimeout && c.reclaimMode != reclaimDirect && c.reclaimMode != reclaimAuto {
+		panic(fmt.Sprintf("unknown reclaim mode %q", c.reclaimMode))
+	}
 
-	pvcInformer := factory.Core().V1().PersistentVolumeClaims().Informer()
-	pvcInformer.AddIndexers(cache.Indexers{
-		pvcByNodeIndex: func(obj any) ([]string, error) {
-			pvc := obj.(*corev1.PersistentVolumeClaim)
-			if pvc.Annotations[provisionerAnnotation] != expectedProvisionerValue {
-				return nil, nil
+	if !*printRBAC {
+		err = c.checkCluster(ctx)
+		if err != nil {
+			if *once {
+				panic(err)
 			}
+			fmt.Printf("switching to report mode: %v\n", err)
+			c.mode = modeReport
+		}
+	}
 
-			return []string{pvc.Annotations[selectedNodeAnnotation]}, nil
-		},
-	})
+	if !validOrder(c.order) {
+		panic(fmt.Sprintf("unknown order %q", c.order))
+	}
+
+	if c.podAction != podActionDelete && c.podAction != podActionEvict {
+		panic(fmt.Sprintf("unknown pod action %q", c.podAction))
+	}
+
+	if *namespaceServiceAccount != "" {
+		c.namespaceClients = &namespaceClients{
+			config:         config,
+			serviceAccount: *namespaceServiceAccount,
+			clients:        map[string]kubernetes.Interface{},
+		}
+	}
+
+	if *vetoURL != "" {
+		c.veto = &vetoWebhook{
+			url:      *vetoURL,
+			failOpen: *vetoFailOpen,
+			client:   &http.Client{Timeout: *vetoTimeout},
+		}
+	}
+
+	if *notifyURL != "" {
+		tmpl, err := parseNotificationTemplate(*notifyTemplate, defaultNotificationTemplate)
+		if err != nil {
+			panic(err)
+		}
+		c.notifiers = append(c.notifiers, &webhookNotifier{
+			url:         *notifyURL,
+			contentType: *notifyContentType,
+			template:    tmpl,
+			client:      &http.Client{Timeout: 10 * time.Second},
+		})
+	}
+	if *ownerRoutesFile != "" {
+		tmpl, err := parseNotificationTemplate(*notifyTemplate, defaultNotificationTemplate)
+		if err != nil {
+			panic(err)
+		}
+		c.ownerLabel = *ownerLabel
+		c.ownerRoutes, err = loadOwnerRoutes(*ownerRoutesFile, *notifyContentType, tmpl)
+		if err != nil {
+			panic(err)
+		}
+	}
 
+	if *smtpAddress != "" {
+		if *smtpTLS != smtpTLSStartTLS && *smtpTLS != smtpTLSImplicit && *smtpTLS != smtpTLSNone {
+			panic(fmt.Sprintf("unknown smtp tls mode %q", *smtpTLS))
+		}
+		tmpl, err := parseNotificationTemplate(*smtpTemplate, defaultEmailTemplate)
+		if err != nil {
+			panic(err)
+		}
+		sink := &smtpNotifier{
+			address:  *smtpAddress,
+			from:     *smtpFrom,
+			to:       smtpTo,
+			username: *smtpUsername,
+			tlsMode:  *smtpTLS,
+			template: tmpl,
+		}
+		if *smtpPasswordFile != "" {
+			password, err := os.ReadFile(*smtpPasswordFile)
+			if err != nil {
+				panic(err)
+			}
+			sink.password = strings.TrimSpace(string(password))
+		}
+		c.notifiers = append(c.notifiers, sink)
+	}
+
+	if *redact {
+		c.redactor = &redactor{}
+		if *redactKeyFile != "" {
+			key, err := os.ReadFile(*redactKeyFile)
+			if err != nil {
+				panic(err)
+			}
+			c.redactor.key = []byte(strings.TrimSpace(string(key)))
+		}
+		c.deletionMetrics.redactor = c.redactor
+	}
+
+	if *apiTokenFile != "" {
+		token, err := os.ReadFile(*apiTokenFile)
+		if err != nil {
+			panic(err)
+		}
+		c.apiToken = strings.TrimSpace(string(token))
+	}
+
+	if *webhookAddress != "" {
+		c.serveWebhook(*webhookAddress, *webhookCertFile, *webhookKeyFile)
+	}
+
+	c.publicReadEndpoints = *publicReadEndpoints
+	if *clientCAFile != "" && (*tlsCertFile == "" || *tlsKeyFile == "") {
+		panic("--client-ca-file needs --tls-cert-file and --tls-key-file")
+	}
+	if *listenAddress != "" {
+		c.serve(*listenAddress, *tlsCertFile, *tlsKeyFile, *clientCAFile)
+	}
+	if *grpcAddress != "" {
+		c.serveGRPC(*grpcAddress, *tlsCertFile, *tlsKeyFile, *clientCAFile)
+	}
+
+	c.translator, err = newNodeTranslator(*nodeNamePattern, *nodeNameReplacement, *nodeNamesFile)
+	if err != nil {
+		panic(err)
+	}
+	c.virtualCluster = *virtualCluster
+	c.capacityHints = *capacityHints
+	c.batchDeletes = *batchDeletes
+	if *cloneSourcePolicy != cloneSourceDefer && *cloneSourcePolicy != cloneSourceWarn {
+		panic(fmt.Sprintf("unknown clone source policy %q", *cloneSourcePolicy))
+	}
+	c.cloneSourcePolicy = *cloneSourcePolicy
+	if *renotifyInterval > 0 {
+		c.notified = newNotificationLog(*renotifyInterval)
+	}
+	if *usageInterval > 0 || *usagePrometheusURL != "" {
+		c.usage = newVolumeUsage(strings.TrimSuffix(*usagePrometheusURL, "/"))
+	}
+	if *nodeLocalCapacity != "" {
+		c.nodeLocalCapacity, err = resource.ParseQuantity(*nodeLocalCapacity)
+		if err != nil {
+			panic(fmt.Sprintf("invalid node local capacity: %v", err))
+		}
+	}
+	c.addIndexers()
+
+	if *nodeInventory && !*printRBAC {
+		if c.namespace == "" {
+			panic("the node inventory needs --namespace")
+		}
+		c.inventory = newNodeInventory(*nodeInventoryRetention)
+		err = c.loadInventory(ctx)
+		if err != nil {
+			panic(fmt.Sprintf("loading node inventory: %v", err))
+		}
+	}
+	if !*printRBAC {
+		err = c.loadCancelled(ctx)
+		if err != nil {
+			panic(fmt.Sprintf("loading cancelled nodes: %v", err))
+		}
+	}
+	if *transactionLog && !*printRBAC {
+		if c.namespace == "" {
+			panic("the transaction log needs --namespace")
+		}
+		c.transactions = newTransactionLog()
+		err = c.loadTransactions(ctx)
+		if err != nil {
+			panic(fmt.Sprintf("loading transactions: %v", err))
+		}
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		panic(err)
+	}
+	if *reportStatus {
+		c.health = newHealthStatus(dynamicClient)
+	}
+	if *cleanupPlan {
+		err = validatePlan(c.mode)
+		if err != nil {
+			panic(err)
+		}
+		c.plan = newCleanupPlan(dynamicClient)
+	}
+	c.load = load
+	if *churnThreshold > 0 {
+		if *churnGraceFactor < 1 || *churnWindow <= 0 {
+			panic("--churn-grace-factor must be at least 1 and --churn-window positive")
+		}
+		c.churn = newNodeChurn(*churnWindow, *churnThreshold, *churnGraceFactor)
+		gracePeriodFactor.Set(1)
+	}
+
+	required := c.requiredPermissions()
+	if *veleroRestores {
+		required.add(*veleroNamespace, "velero.io", "restores", nil, "list", "watch")
+	}
+	if *printRBAC {
+		if *impersonateUser != "" {
+			required.add("", "", "users", []string{*impersonateUser}, "impersonate")
+		}
+		if len(impersonateGroups) > 0 {
+			required.add("", "", "groups", impersonateGroups, "impersonate")
+		}
+		manifests, err := rbacManifests(required)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Print(string(manifests))
+		return
+	}
+
+	events := newEventQueue()
+	go events.run(ctx)
 	nodeInformer := factory.Core().V1().Nodes().Informer()
 	nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			events.enqueue("node-add", func() {
+				node := obj.(*corev1.Node)
+				c.nodePools.observe(node)
+				c.seen.observe(node.Name)
+				if c.inventory != nil {
+					c.inventory.observe(node)
+				}
+				if c.cancelled.remove(node.Name) {
+					fmt.Printf("node(%s) with a cancelled cleanup is back\n", node.Name)
+					if err := c.saveCancelled(ctx); err != nil {
+						fmt.Printf("failed to save cancelled nodes: %v\n", err)
+					}
+				}
+			})
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			events.enqueue("node-update", func() {
+				c.nodePools.observe(newObj.(*corev1.Node))
+				c.handleNodeUpdate(ctx, oldObj.(*corev1.Node), newObj.(*corev1.Node))
+			})
+		},
 		DeleteFunc: func(obj any) {
-			node := obj.(*corev1.Node)
-			fmt.Printf("node deleted: %s\n", node.Name)
-			cleanupVolumesByNode(context.TODO(), clientset, node.Name, factory)
+			events.enqueue("node-delete", func() {
+				c.handleNodeDelete(ctx, obj)
+			})
 		},
 	})
 
+	c.registerCacheMetrics()
+	c.warnExcessPermissions(ctx, required)
+
+	if *once {
+		err = checkAccess(ctx, clientset, *managePods, *workloadProtection)
+		if err != nil {
+			fmt.Printf("missing access: %v\n", err)
+			os.Exit(exitConfigError)
+		}
+	}
+
 	stopCh := make(chan struct{})
+	c.watchConfig(stopCh)
+	if *veleroRestores {
+		c.watchVeleroRestores(dynamicClient, *veleroNamespace, stopCh)
+	}
 	factory.Start(stopCh)
 	factory.WaitForCacheSync(stopCh)
+	if podFactory != nil {
+		podFactory.Start(stopCh)
+		podFactory.WaitForCacheSync(stopCh)
+	}
+	c.health.synced()
 
-	pvcs, err := factory.Core().V1().PersistentVolumeClaims().Lister().List(labels.Everything())
-	for _, pvc := range pvcs {
-		if pvc.Annotations[provisionerAnnotation] != expectedProvisionerValue {
-			continue
-		}
-
-		nodeName := pvc.Annotations[selectedNodeAnnotation]
-		_, exists, err := factory.Core().V1().Nodes().Informer().GetStore().GetByKey(nodeName)
+	if diff {
+		err = c.runDiff(ctx)
+		cancel()
+		close(stopCh)
 		if err != nil {
-			fmt.Printf("failed to get node(%s) from pvc(%s): %v\n", nodeName, pvc.Name, err)
-			continue
+			panic(err)
 		}
+		return
+	}
 
-		if exists {
-			fmt.Printf("node(%s) does exist in store from pvc(%s)\n", nodeName, pvc.Name)
-			continue
-		}
+	if *once {
+		sum, err := c.reconcile(ctx)
+		exportRunMetrics(*pushgatewayURL, *metricsTextfile)
+		cancel()
+		close(stopCh)
+		os.Exit(exitCode(sum, err))
+	}
 
-		fmt.Printf("node(%s) does not exist in store from pvc(%s)\n", nodeName, pvc.Name)
-		deleteVolumes(context.TODO(), clientset, factory, pvc)
+	if *usageInterval > 0 && *usagePrometheusURL == "" {
+		go c.runUsageSampling(ctx, *usageInterval)
 	}
+	c.reconcile(ctx)
+	go c.runReconciles(ctx, *reconcileInterval)
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	<-sigCh
+	cancel()
 	close(stopCh)
 }
+
+// restConfig returns the config of the KUBECONFIG, or the in-cluster config
+// when it is not set.
+func restConfig() (*rest.Config, error) {
+	kubeConfig := os.Getenv("KUBECONFIG")
+	if kubeConfig != "" {
+		return clientcmd.BuildConfigFromFlags("", kubeConfig)
+	}
+	return rest.InClusterConfig()
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// otherLabelValue replaces label values beyond the cardinality limit.
+const otherLabelValue = "other"
+
+var (
+	decisionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_decisions_total",
+		Help: "Number of evaluated pvcs by decision.",
+	}, []string{"decision"})
+	reconcileOrphansFound = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_reconcile_orphans_found",
+		Help: "Number of pvcs whose node is gone found by the last full reconcile.",
+	})
+	reconcileOrphansCleaned = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_reconcile_orphans_cleaned",
+		Help: "Number of orphaned pvcs deleted or migrated by the last full reconcile.",
+	})
+	reconcileOrphansSkipped = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_reconcile_orphans_skipped",
+		Help: "Number of orphaned pvcs skipped by the last full reconcile.",
+	})
+	reconcileOrphansFailed = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_reconcile_orphans_failed",
+		Help: "Number of orphaned pvcs that failed to be cleaned by the last full reconcile.",
+	})
+	reconcileUnclassifiable = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_reconcile_unclassifiable_pvcs",
+		Help: "Number of local path pvcs without a selected node nor a volume pinned to one found by the last full reconcile.",
+	})
+	reconcileStorageClassMissing = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_reconcile_storage_class_missing_pvcs",
+		Help: "Number of pvcs with a selected node and a storage class that does not exist found by the last full reconcile.",
+	})
+	reconcileLost = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_reconcile_lost_pvcs",
+		Help: "Number of pvcs of local provisioners in the Lost phase found by the last full reconcile.",
+	})
+	reconcileNamespaceTerminating = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_reconcile_namespace_terminating_pvcs",
+		Help: "Number of orphaned pvcs in terminating namespaces left to the namespace deletion by the last full reconcile.",
+	})
+	replicatedVolumesSkipped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_replicated_volumes_skipped_total",
+		Help: "Number of times a pvc backed by replicated storage was skipped.",
+	})
+	nodeLeaseAborts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_node_lease_aborts_total",
+		Help: "Number of cleanups aborted because the kubelet of a missing node renewed its lease.",
+	})
+	stuckTerminatingObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_stuck_terminating_objects",
+		Help: "Number of objects deleted by the cleaner that are terminating for longer than the stuck threshold.",
+	}, []string{"kind"})
+	cleanupAbandoned = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_cleanup_abandoned_total",
+		Help: "Number of objects whose cleanup was given up after running out of retries.",
+	})
+	artifactsCollected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_artifacts_collected_total",
+		Help: "Number of artifacts of the cleaner removed after the artifact retention.",
+	}, []string{"kind"})
+	apiServerThrottled = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_apiserver_throttled_total",
+		Help: "Number of api requests rejected by the api server with 429 Too Many Requests.",
+	})
+	nodeDeletionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_node_deletions_total",
+		Help: "Number of observed node deletions by classified cause.",
+	}, []string{"cause"})
+	reconcileDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_reconcile_duration_seconds",
+		Help: "Duration of the last full reconcile.",
+	})
+	reconcileTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_reconcile_timestamp_seconds",
+		Help: "Unix time the last full reconcile finished.",
+	})
+	stuckPodsForceDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_stuck_pods_force_deleted_total",
+		Help: "Number of pods stuck on gone nodes force deleted to release the pvc-protection finalizer of their claim.",
+	})
+	podEvictionsBlocked = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_pod_evictions_blocked_total",
+		Help: "Number of consumer pods whose eviction stayed blocked by a pod disruption budget.",
+	})
+	nodeCleanupDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "local_pvc_cleaner_node_cleanup_duration_seconds",
+		Help:    "Time from observing a node deletion to finishing the cleanup of its volumes.",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	})
+	batchedDeletions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_batched_pvc_deletions_total",
+		Help: "Number of pvcs deleted together with the other orphans of their namespace by one delete collection call.",
+	})
+	notificationsSuppressed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_notifications_suppressed_total",
+		Help: "Number of claims left out of notifications because they were notified about within the renotify interval.",
+	})
+	discardedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_discarded_bytes_total",
+		Help: "Bytes of data held by deleted volumes whose usage was known.",
+	})
+	nodeChurnDeletions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_node_churn_deletions",
+		Help: "Number of node deletions within the churn window.",
+	})
+	gracePeriodFactor = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_grace_period_factor",
+		Help: "Factor the grace periods are currently lengthened by because of high node churn.",
+	})
+	eventHandlerLag = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "local_pvc_cleaner_event_handler_lag_seconds",
+		Help:    "Time node events waited in the event queue before their handler started.",
+		Buckets: []float64{0.001, 0.01, 0.1, 1, 5, 15, 60, 300, 900},
+	}, []string{"handler"})
+	eventHandlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "local_pvc_cleaner_event_handler_duration_seconds",
+		Help:    "Time the handlers of node events took.",
+		Buckets: []float64{0.001, 0.01, 0.1, 1, 5, 15, 60, 300, 900},
+	}, []string{"handler"})
+	statefulSetRecoveryDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "local_pvc_cleaner_statefulset_recovery_duration_seconds",
+		Help:    "Time from starting the cleanup of a stateful set claim to its pods being ready with a bound replacement claim.",
+		Buckets: []float64{5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	})
+	statefulSetRecoveryTimeouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_statefulset_recovery_timeouts_total",
+		Help: "Number of cleaned up stateful set claims whose pods did not recover within the recovery timeout.",
+	})
+	nodeCleanupSLOBreaches = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_node_cleanup_slo_breaches_total",
+		Help: "Number of node cleanups that took longer than the configured slo.",
+	})
+	danglingVolumesDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_dangling_pvs_deleted_total",
+		Help: "Number of local pvs deleted because their pvc and node were gone.",
+	})
+	blacklistedObjects = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_blacklisted_objects",
+		Help: "Number of objects that are no longer retried after repeatedly failing to be cleaned up.",
+	})
+	objectsBlacklisted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_blacklisted_total",
+		Help: "Number of times an object got blacklisted after repeatedly failing to be cleaned up.",
+	})
+	circuitBreakerOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "local_pvc_cleaner_circuit_breaker_open",
+		Help: "Whether cleanups are paused because too many delete calls failed.",
+	})
+	circuitBreakerTrips = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_circuit_breaker_trips_total",
+		Help: "Number of times the circuit breaker opened.",
+	})
+	pvcDeletionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "local_pvc_cleaner_pvc_deletions_total",
+		Help: "Number of deleted pvcs by namespace and storage class.",
+	}, []string{"namespace", "storage_class"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		decisionsTotal,
+		reconcileOrphansFound,
+		reconcileOrphansCleaned,
+		reconcileOrphansSkipped,
+		reconcileOrphansFailed,
+		reconcileUnclassifiable,
+		reconcileStorageClassMissing,
+		reconcileLost,
+		reconcileNamespaceTerminating,
+		replicatedVolumesSkipped,
+		nodeLeaseAborts,
+		stuckTerminatingObjects,
+		cleanupAbandoned,
+		nodeDeletionsTotal,
+		artifactsCollected,
+		apiServerThrottled,
+		reconcileDuration,
+		reconcileTimestamp,
+		podEvictionsBlocked,
+		stuckPodsForceDeleted,
+		batchedDeletions,
+		notificationsSuppressed,
+		discardedBytes,
+		nodeChurnDeletions,
+		gracePeriodFactor,
+		eventHandlerLag,
+		eventHandlerDuration,
+		pvcDeletionsTotal,
+		nodeCleanupDuration,
+		statefulSetRecoveryDuration,
+		statefulSetRecoveryTimeouts,
+		nodeCleanupSLOBreaches,
+		danglingVolumesDeleted,
+		blacklistedObjects,
+		objectsBlacklisted,
+		circuitBreakerOpen,
+		circuitBreakerTrips,
+	)
+}
+
+// labelLimiter caps the number of distinct values a label takes, folding the
+// rest into otherLabelValue. A limit of zero means no limit.
+type labelLimiter struct {
+	limit int
+
+	mu     sync.Mutex
+	values map[string]bool
+}
+
+func newLabelLimiter(limit int) *labelLimiter {
+	return &labelLimiter{limit: limit, values: map[string]bool{}}
+}
+
+func (l *labelLimiter) value(value string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit == 0 || l.values[value] {
+		return value
+	}
+	if len(l.values) >= l.limit {
+		return otherLabelValue
+	}
+	l.values[value] = true
+	return value
+}
+
+// deletionMetrics breaks pvc deletions down by namespace and storage class.
+type deletionMetrics struct {
+	byNamespace    bool
+	byStorageClass bool
+	namespaces     *labelLimiter
+	storageClasses *labelLimiter
+	redactor       *redactor
+}
+
+func (m *deletionMetrics) observe(ctx context.Context, pvc *corev1.PersistentVolumeClaim) {
+	namespace := ""
+	if m.byNamespace {
+		namespace = m.namespaces.value(m.redactor.name(pvc.Namespace))
+	}
+
+	storageClass := ""
+	if m.byStorageClass && pvc.Spec.StorageClassName != nil {
+		storageClass = m.storageClasses.value(*pvc.Spec.StorageClassName)
+	}
+
+	incWithExemplar(ctx, pvcDeletionsTotal.WithLabelValues(namespace, storageClass))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// nodeMapping loads the configmap declaring which node the volumes of a
+// removed node now live on. Keys are old node names and values new node names.
+func (c *cleaner) nodeMapping(ctx context.Context) (map[string]string, error) {
+	if c.nodeMappingConfigMap == "" {
+		return nil, nil
+	}
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(c.nodeMappingConfigMap)
+	if err != nil {
+		return nil, err
+	}
+
+	cm, err := c.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return cm.Data, nil
+}
+
+// migrateVolumes points a claim and its volume at a new node instead of
+// deleting them. The volume update is tried as a dry run first, so clusters
+// that treat the volume node affinity as immutable reject the migration before
+// the claim is touched, and the claim is rolled back when the volume update
+// fails after all. Node names are compared after translation, like the nodes
+// of candidates.
+func (c *cleaner) migrateVolumes(ctx context.Context, pvc *corev1.PersistentVolumeClaim, oldNode, newNode string) error {
+	pv, err := c.migratedVolume(pvc, oldNode, newNode)
+	if err != nil {
+		logf(ctx, "failed to get pv(%s): %v\n", pvc.Spec.VolumeName, err)
+		return err
+	}
+	if pv != nil {
+		_, err = c.clientset.CoreV1().PersistentVolumes().Update(ctx, pv, metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}})
+		if err != nil {
+			logf(ctx, "pv(%s) cannot be migrated from node(%s) to node(%s): %v\n", pv.Name, oldNode, newNode, err)
+			return err
+		}
+	}
+
+	client, err := c.clientFor(pvc.Namespace)
+	if err != nil {
+		logf(ctx, "failed to get client for namespace(%s): %v\n", pvc.Namespace, err)
+		return err
+	}
+
+	var original string
+	migrated := false
+	if selected := pvc.Annotations[selectedNodeAnnotation]; selected != "" && c.translateNode(selected) == oldNode {
+		original = selected
+		updated := pvc.DeepCopy()
+		updated.Annotations[selectedNodeAnnotation] = newNode
+		pvc, err = client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+		if err != nil {
+			logf(ctx, "failed to migrate pvc(%s) from node(%s) to node(%s): %v\n", updated.Name, oldNode, newNode, err)
+			return err
+		}
+		migrated = true
+		logf(ctx, "migrated pvc(%s) from node(%s) to node(%s)\n", pvc.Name, oldNode, newNode)
+	}
+
+	if pv == nil {
+		return nil
+	}
+	_, err = c.clientset.CoreV1().PersistentVolumes().Update(ctx, pv, metav1.UpdateOptions{})
+	if err != nil {
+		logf(ctx, "failed to migrate pv(%s) from node(%s) to node(%s): %v\n", pv.Name, oldNode, newNode, err)
+		if migrated {
+			c.rollbackMigration(ctx, client, pvc, original)
+		}
+		return err
+	}
+	logf(ctx, "migrated pv(%s) from node(%s) to node(%s)\n", pv.Name, oldNode, newNode)
+	return nil
+}
+
+// migratedVolume returns a copy of the volume of a claim with its node
+// affinity moved from the old node to the new one, or nil when the claim has
+// no volume or its affinity does not name the old node.
+func (c *cleaner) migratedVolume(pvc *corev1.PersistentVolumeClaim, oldNode, newNode string) (*corev1.PersistentVolume, error) {
+	if pvc.Spec.VolumeName == "" {
+		return nil, nil
+	}
+	pv, err := c.factory.Core().V1().PersistentVolumes().Lister().Get(pvc.Spec.VolumeName)
+	if err != nil {
+		return nil, err
+	}
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return nil, nil
+	}
+
+	pv = pv.DeepCopy()
+	changed := false
+	for i := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		term := &pv.Spec.NodeAffinity.Required.NodeSelectorTerms[i]
+		for j := range term.MatchExpressions {
+			expr := &term.MatchExpressions[j]
+			if expr.Operator != corev1.NodeSelectorOpIn {
+				continue
+			}
+			for k, value := range expr.Values {
+				if c.translateNode(value) == oldNode {
+					expr.Values[k] = newNode
+					changed = true
+				}
+			}
+		}
+	}
+	if !changed {
+		return nil, nil
+	}
+	return pv, nil
+}
+
+// rollbackMigration points a migrated claim back at its original node after
+// its volume could not be migrated.
+func (c *cleaner) rollbackMigration(ctx context.Context, client kubernetes.Interface, pvc *corev1.PersistentVolumeClaim, original string) {
+	pvc = pvc.DeepCopy()
+	pvc.Annotations[selectedNodeAnnotation] = original
+	_, err := client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(ctx, pvc, metav1.UpdateOptions{})
+	if err != nil {
+		logf(ctx, "failed to roll back migration of pvc(%s) to node(%s): %v\n", pvc.Name, original, err)
+		return
+	}
+	logf(ctx, "rolled back migration of pvc(%s) to node(%s)\n", pvc.Name, original)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+)
+
+const (
+	// modeClean detects orphans and cleans them up.
+	modeClean = "clean"
+	// modeReport detects orphans and reports them through the decision log,
+	// metrics and events without changing any claim, volume or pod.
+	modeReport = "report"
+	// modeExternal detects and labels orphans and leaves deleting them to an
+	// external deleter, which confirms the deletions through the api.
+	modeExternal = "external"
+)
+
+var errReportMode = errors.New("the cleaner runs in report mode")
+
+// reportOnly reports whether the cleaner must not change anything in the
+// cluster besides recording events.
+func (c *cleaner) reportOnly() bool {
+	return c.mode == modeReport
+}
+
+// externalDeletion reports whether orphans are deleted by an external deleter
+// instead of the cleaner.
+func (c *cleaner) externalDeletion() bool {
+	return c.mode == modeExternal
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// namespaceObject returns a namespace for policy decisions on its labels and
+// annotations, from the namespace informer cache when namespaces are cached
+// and from the api otherwise.
+func (c *cleaner) namespaceObject(ctx context.Context, name string) (*corev1.Namespace, error) {
+	if c.cacheNamespaces {
+		return c.factory.Core().V1().Namespaces().Lister().Get(name)
+	}
+	return c.clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+}
+
+// namespaceProtected reports whether a namespace opted out of cleanups with
+// the protect pvcs annotation.
+func (c *cleaner) namespaceProtected(ctx context.Context, name string) bool {
+	ns, err := c.namespaceObject(ctx, name)
+	if err != nil {
+		tracef("failed to get namespace(%s): %v\n", name, err)
+		return false
+	}
+	return ns.Annotations[protectPVCsAnnotation] == "true"
+}
+
+// namespaceTerminating reports whether a namespace is being deleted, in which
+// case the namespace controller deletes its claims anyway.
+func (c *cleaner) namespaceTerminating(ctx context.Context, name string) bool {
+	ns, err := c.namespaceObject(ctx, name)
+	if err != nil {
+		tracef("failed to get namespace(%s): %v\n", name, err)
+		return false
+	}
+	return ns.Status.Phase == corev1.NamespaceTerminating || ns.DeletionTimestamp != nil
+}
+
+// namespaceTerminatingError reports whether an api request failed because the
+// namespace of its object is being deleted.
+func namespaceTerminatingError(err error) bool {
+	return apierrors.HasStatusCause(err, corev1.NamespaceTerminatingCause)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// neverSeenReport only reports claims whose nodes were never seen, like
+	// claims restored from a backup of another cluster.
+	neverSeenReport = "report"
+	// neverSeenClean cleans them up like claims of deleted nodes.
+	neverSeenClean = "clean"
+)
+
+// seenNodes are the nodes observed since the cleaner started.
+type seenNodes struct {
+	since time.Time
+
+	mu    sync.Mutex
+	nodes map[string]bool
+}
+
+func newSeenNodes() *seenNodes {
+	return &seenNodes{since: time.Now(), nodes: map[string]bool{}}
+}
+
+func (s *seenNodes) observe(nodeName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes[nodeName] = true
+}
+
+func (s *seenNodes) any(nodeNames []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, nodeName := range nodeNames {
+		if s.nodes[nodeName] {
+			return true
+		}
+	}
+	return false
+}
+
+// neverSeenNodes reports whether none of the missing nodes of a claim ever
+// existed in this cluster, as opposed to being deleted. With the node
+// inventory that is every node missing from it, without it only claims
+// created after the cleaner started whose nodes it never saw can tell.
+func (c *cleaner) neverSeenNodes(pvc *corev1.PersistentVolumeClaim, nodes []string) bool {
+	if c.inventory != nil {
+		return !c.inventory.knowsAny(nodes)
+	}
+	return pvc.CreationTimestamp.Time.After(c.seen.since) && !c.seen.any(nodes)
+}
+
+// neverSeenDecision reports claims whose nodes were never seen in this
+// cluster, which restores of backups from other clusters leave behind, and
+// keeps them unless the never seen node policy cleans them up.
+func (c *cleaner) neverSeenDecision(ctx context.Context, cand candidate) decision {
+	if !c.neverSeenNodes(cand.pvc, cand.nodes) {
+		return ""
+	}
+
+	logf(ctx, "nodes(%s) of pvc(%s) were never seen in this cluster\n", strings.Join(cand.nodes, ","), cand.pvc.Name)
+	if c.neverSeenPolicy == neverSeenClean {
+		return ""
+	}
+	c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "NodeNeverSeen", "node(s) %v never existed in this cluster, the pvc was likely restored from another cluster and is kept", cand.nodes)
+	return decisionSkippedNodeNeverSeen
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// defaultNodePoolLabels are the node labels of common autoscalers and managed
+// clusters naming the pool a node belongs to.
+var defaultNodePoolLabels = stringList{
+	"karpenter.sh/nodepool",
+	"cloud.google.com/gke-nodepool",
+	"eks.amazonaws.com/nodegroup",
+	"kubernetes.azure.com/agentpool",
+}
+
+// nodePools remembers the pool of every node seen, since the pool of a node
+// is needed after the node is gone.
+type nodePools struct {
+	labels stringList
+
+	mu    sync.Mutex
+	pools map[string]string
+}
+
+func newNodePools(labels stringList) *nodePools {
+	return &nodePools{labels: labels, pools: map[string]string{}}
+}
+
+// observe records the pool of a node from the first pool label it carries.
+func (p *nodePools) observe(node *corev1.Node) {
+	for _, label := range p.labels {
+		pool := node.Labels[label]
+		if pool == "" {
+			continue
+		}
+
+		p.mu.Lock()
+		p.pools[node.Name] = pool
+		p.mu.Unlock()
+		return
+	}
+}
+
+// pool returns the pool of a node, or an empty string when it is unknown.
+func (p *nodePools) pool(nodeName string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pools[nodeName]
+}
+
+// retain forgets the pools of every node except the given ones.
+func (p *nodePools) retain(nodeNames map[string]bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for nodeName := range p.pools {
+		if !nodeNames[nodeName] {
+			delete(p.pools, nodeName)
+		}
+	}
+}
+
+// retainNodePools forgets the pools of nodes that are gone and no claim is
+// pinned to anymore.
+func (c *cleaner) retainNodePools(candidates []candidate) {
+	if c.nodePools == nil {
+		return
+	}
+
+	nodes, err := c.factory.Core().V1().Nodes().Lister().List(labels.Everything())
+	if err != nil {
+		fmt.Printf("failed to list nodes: %v\n", err)
+		return
+	}
+	nodeNames := map[string]bool{}
+	for _, node := range nodes {
+		nodeNames[node.Name] = true
+	}
+	for _, cand := range candidates {
+		for _, nodeName := range cand.nodes {
+			nodeNames[nodeName] = true
+		}
+	}
+	c.nodePools.retain(nodeNames)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"text/template"
+	"time"
+)
+
+const (
+	notificationNodeCleanup = "node-cleanup"
+	notificationReconcile   = "reconcile"
+)
+
+// notification is the data notification templates are rendered with.
+type notification struct {
+	Event         string            `json:"event"`
+	CorrelationID string            `json:"correlationId,omitempty"`
+	Nodes         []string          `json:"nodes"`
+	Namespaces    []string          `json:"namespaces"`
+	Found         int               `json:"found"`
+	Cleaned       int               `json:"cleaned"`
+	Skipped       int               `json:"skipped"`
+	Failed        int               `json:"failed"`
+	PVCs          []notificationPVC `json:"pvcs"`
+	// Abandoned are the objects given up on since the previous notification.
+	Abandoned []abandonedCleanup `json:"abandoned"`
+	// Simulated is set on notifications about simulated node deletions.
+	Simulated bool `json:"simulated,omitempty"`
+}
+
+type notificationPVC struct {
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Nodes     []string `json:"nodes"`
+	Size      string   `json:"size,omitempty"`
+	Decision  decision `json:"decision"`
+	// Cause is why the nodes of the claim were deleted, when observed.
+	Cause string `json:"cause,omitempty"`
+	// Owner is the team owning the claim, when known.
+	Owner string `json:"owner,omitempty"`
+	// BlockedSince and Message tell about claims notified about before that
+	// are still not cleaned up.
+	BlockedSince *time.Time `json:"blockedSince,omitempty"`
+	Message      string     `json:"message,omitempty"`
+}
+
+// defaultNotificationTemplate renders the notification as json.
+const defaultNotificationTemplate = `{{ json . }}`
+
+var notificationFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// parseNotificationTemplate loads the notification template from a file, or
+// the given default template when no file is given.
+func parseNotificationTemplate(file, text string) (*template.Template, error) {
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+	return template.New("notification").Funcs(notificationFuncs).Parse(text)
+}
+
+// newNotification describes the outcome of a batch of cleanups on the given
+// nodes, or on the nodes of its orphans when nil.
+func newNotification(ctx context.Context, event string, nodes []string, sum summary) notification {
+	n := notification{
+		Event:         event,
+		CorrelationID: correlationID(ctx),
+		Nodes:         nodes,
+		Namespaces:    []string{},
+		Found:         sum.found,
+		Cleaned:       sum.cleaned,
+		Skipped:       sum.skipped,
+		Failed:        sum.failed,
+		PVCs:          []notificationPVC{},
+		Simulated:     simulated(ctx),
+	}
+
+	for _, o := range sum.outcomes {
+		pvc := notificationPVC{
+			Namespace: o.cand.pvc.Namespace,
+			Name:      o.cand.pvc.Name,
+			Nodes:     o.cand.nodes,
+			Decision:  o.decision,
+		}
+		if size, ok := o.cand.pvc.Spec.Resources.Requests["storage"]; ok {
+			pvc.Size = size.String()
+		}
+		if !o.blockedSince.IsZero() {
+			since := o.blockedSince.UTC()
+			pvc.BlockedSince = &since
+			pvc.Message = blockedMessage(o.blockedSince)
+		}
+		n.PVCs = append(n.PVCs, pvc)
+		n.Namespaces = appendUnique(n.Namespaces, pvc.Namespace)
+		if nodes == nil {
+			for _, nodeName := range o.cand.nodes {
+				n.Nodes = appendUnique(n.Nodes, nodeName)
+			}
+		}
+	}
+	sort.Strings(n.Namespaces)
+	return n
+}
+
+// notifier delivers notifications to a sink.
+type notifier interface {
+	send(ctx context.Context, n notification) error
+}
+
+// webhookNotifier posts rendered notifications to an endpoint.
+type webhookNotifier struct {
+	url         string
+	contentType string
+	template    *template.Template
+	client      *http.Client
+}
+
+func (w *webhookNotifier) send(ctx context.Context, n notification) error {
+	var body bytes.Buffer
+	err := w.template.Execute(&body, n)
+	if err != nil {
+		return fmt.Errorf("rendering notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.contentType)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// notify sends a notification about a batch of cleanups that deleted or failed
+// to delete something or escalates a blocked claim, and each owner with a
+// route one about its claims. Claims notified about within the renotify
+// interval are left out, claims count as notified once a notification about
+// them was sent.
+func (c *cleaner) notify(ctx context.Context, event string, nodes []string, sum summary) {
+	if len(c.notifiers) == 0 && len(c.ownerRoutes) == 0 {
+		return
+	}
+	if !simulated(ctx) {
+		sum = c.notified.filter(sum)
+	}
+	if !sum.notifiable() {
+		return
+	}
+
+	owners := make([]string, len(sum.outcomes))
+	for i, o := range sum.outcomes {
+		owners[i] = c.claimOwner(ctx, o.cand.pvc)
+	}
+	c.notifyOwners(ctx, event, nodes, sum, owners)
+	if len(c.notifiers) == 0 {
+		return
+	}
+
+	n := newNotification(ctx, event, nodes, sum)
+	for i, o := range sum.outcomes {
+		n.PVCs[i].Cause = c.nodeCauses(o.cand.nodes)
+		n.PVCs[i].Owner = owners[i]
+	}
+	if !simulated(ctx) {
+		n.Abandoned = c.abandoned.drain()
+	}
+	if n.Abandoned == nil {
+		n.Abandoned = []abandonedCleanup{}
+	}
+	c.redactor.redactNotification(&n)
+	sent := false
+	for _, notifier := range c.notifiers {
+		err := notifier.send(ctx, n)
+		if err != nil {
+			logf(ctx, "failed to send %s notification: %v\n", event, err)
+			continue
+		}
+		sent = true
+	}
+	if sent && !simulated(ctx) {
+		c.notified.sent(sum)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// exit codes of a one shot run.
+const (
+	exitNothingToClean = 0
+	exitCleaned        = 1
+	exitPartialFailure = 2
+	exitConfigError    = 3
+)
+
+// exitCode returns the exit code of a one shot run from its reconcile.
+func exitCode(sum summary, err error) int {
+	switch {
+	case err != nil:
+		return exitConfigError
+	case sum.failed > 0:
+		return exitPartialFailure
+	case sum.cleaned > 0:
+		return exitCleaned
+	default:
+		return exitNothingToClean
+	}
+}
+
+// exitOnPanic exits with the configuration error code when setting up a one
+// shot run panics, instead of the exit code of a panic.
+func exitOnPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	fmt.Printf("%v\n", r)
+	os.Exit(exitConfigError)
+}
+
+// checkAccess lists each kind the cleaner watches once, so missing rbac fails
+// a one shot run instead of blocking the cache sync forever.
+func checkAccess(ctx context.Context, clientset kubernetes.Interface, pods, workloads bool) error {
+	options := metav1.ListOptions{Limit: 1}
+	_, err := clientset.CoreV1().Nodes().List(ctx, options)
+	if err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+	_, err = clientset.CoreV1().PersistentVolumes().List(ctx, options)
+	if err != nil {
+		return fmt.Errorf("listing pvs: %w", err)
+	}
+	_, err = clientset.CoreV1().PersistentVolumeClaims("").List(ctx, options)
+	if err != nil {
+		return fmt.Errorf("listing pvcs: %w", err)
+	}
+	_, err = clientset.StorageV1().StorageClasses().List(ctx, options)
+	if err != nil {
+		return fmt.Errorf("listing storage classes: %w", err)
+	}
+	if workloads {
+		_, err = clientset.AppsV1().StatefulSets("").List(ctx, options)
+		if err != nil {
+			return fmt.Errorf("listing statefulsets: %w", err)
+		}
+		_, err = clientset.AppsV1().Deployments("").List(ctx, options)
+		if err != nil {
+			return fmt.Errorf("listing deployments: %w", err)
+		}
+	}
+	if !pods {
+		return nil
+	}
+	_, err = clientset.CoreV1().Pods("").List(ctx, options)
+	if err != nil {
+		return fmt.Errorf("listing pods: %w", err)
+	}
+	return nil
+}
+
+// pushJobName is the pushgateway job one shot runs are grouped under.
+const pushJobName = "local-pvc-cleaner"
+
+// exportRunMetrics makes the metrics of a one shot run observable after it
+// exits by pushing them to a pushgateway and writing them to a textfile for
+// the node exporter, when configured.
+func exportRunMetrics(pushgatewayURL, textfile string) {
+	if pushgatewayURL != "" {
+		err := push.New(pushgatewayURL, pushJobName).Gatherer(prometheus.DefaultGatherer).Push()
+		if err != nil {
+			fmt.Printf("failed to push metrics to %s: %v\n", pushgatewayURL, err)
+		} else {
+			fmt.Printf("pushed metrics to %s\n", pushgatewayURL)
+		}
+	}
+
+	if textfile != "" {
+		err := prometheus.WriteToTextfile(textfile, prometheus.DefaultGatherer)
+		if err != nil {
+			fmt.Printf("failed to write metrics to %s: %v\n", textfile, err)
+		} else {
+			fmt.Printf("wrote metrics to %s\n", textfile)
+		}
+	}
+}
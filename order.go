@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	orderPriority  = "priority"
+	orderNamespace = "namespace"
+	orderSize      = "size"
+	orderName      = "name"
+)
+
+func validOrder(order string) bool {
+	switch order {
+	case orderPriority, orderNamespace, orderSize, orderName:
+		return true
+	default:
+		return false
+	}
+}
+
+// consumerPriority returns the highest priority of the pods consuming a claim.
+func (c *cleaner) consumerPriority(pvc *corev1.PersistentVolumeClaim) int32 {
+	pods, err := c.consumerPods(pvc)
+	if err != nil {
+		fmt.Printf("error getting pods from index: %v\n", err)
+		return 0
+	}
+
+	var priority int32
+	found := false
+	for _, pod := range pods {
+		if pod.Spec.Priority == nil {
+			continue
+		}
+		if !found || *pod.Spec.Priority > priority {
+			priority = *pod.Spec.Priority
+			found = true
+		}
+	}
+	return priority
+}
+
+// sortCandidates orders candidates by the configured strategy. Priority puts
+// claims consumed by the highest priority pods first, namespace and name sort
+// alphabetically and size puts the smallest requests first. Ties are broken by
+// namespace and name.
+func (c *cleaner) sortCandidates(candidates []candidate) {
+	priorities := map[*corev1.PersistentVolumeClaim]int32{}
+	if c.order == orderPriority {
+		for _, cand := range candidates {
+			priorities[cand.pvc] = c.consumerPriority(cand.pvc)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].pvc, candidates[j].pvc
+		switch c.order {
+		case orderPriority:
+			if priorities[a] != priorities[b] {
+				return priorities[a] > priorities[b]
+			}
+		case orderSize:
+			sizeA := a.Spec.Resources.Requests[corev1.ResourceStorage]
+			sizeB := b.Spec.Resources.Requests[corev1.ResourceStorage]
+			if cmp := sizeA.Cmp(sizeB); cmp != 0 {
+				return cmp < 0
+			}
+		case orderName:
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		}
+
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+}
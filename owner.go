@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// claimOwner returns the team owning a claim: the owner label of the claim,
+// then of the pods consuming it, then the owner annotation or label of its
+// namespace. It returns an empty string when none names one.
+func (c *cleaner) claimOwner(ctx context.Context, pvc *corev1.PersistentVolumeClaim) string {
+	if c.ownerLabel == "" {
+		return ""
+	}
+	if owner := pvc.Labels[c.ownerLabel]; owner != "" {
+		return owner
+	}
+
+	pods, err := c.consumerPods(pvc)
+	if err == nil {
+		for _, pod := range pods {
+			if owner := pod.Labels[c.ownerLabel]; owner != "" {
+				return owner
+			}
+		}
+	}
+
+	ns, err := c.namespaceObject(ctx, pvc.Namespace)
+	if err != nil {
+		tracef("failed to get namespace(%s): %v\n", pvc.Namespace, err)
+		return ""
+	}
+	if owner := ns.Annotations[c.ownerLabel]; owner != "" {
+		return owner
+	}
+	return ns.Labels[c.ownerLabel]
+}
+
+// loadOwnerRoutes reads the json file mapping owners to the webhook urls their
+// notifications are sent to.
+func loadOwnerRoutes(file, contentType string, tmpl *template.Template) (map[string]notifier, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	urls := map[string]string{}
+	err = json.Unmarshal(b, &urls)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+
+	routes := map[string]notifier{}
+	for owner, url := range urls {
+		routes[owner] = &webhookNotifier{
+			url:         url,
+			contentType: contentType,
+			template:    tmpl,
+			client:      &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+	return routes, nil
+}
+
+// notifyOwners sends each owner with a route a notification about the claims
+// it owns in a batch of cleanups.
+func (c *cleaner) notifyOwners(ctx context.Context, event string, nodes []string, sum summary, owners []string) {
+	if len(c.ownerRoutes) == 0 {
+		return
+	}
+
+	batches := map[string]*summary{}
+	for i, o := range sum.outcomes {
+		if _, ok := c.ownerRoutes[owners[i]]; !ok {
+			continue
+		}
+		if batches[owners[i]] == nil {
+			batches[owners[i]] = &summary{}
+		}
+		batch := batches[owners[i]]
+		batch.addCleanup(o.cand, o.decision, o.duration, o.err, o.usedBytes)
+		batch.outcomes[len(batch.outcomes)-1].blockedSince = o.blockedSince
+	}
+
+	names := make([]string, 0, len(batches))
+	for owner := range batches {
+		names = append(names, owner)
+	}
+	sort.Strings(names)
+	for _, owner := range names {
+		batch := *batches[owner]
+		if !batch.notifiable() {
+			continue
+		}
+		n := newNotification(ctx, event, nodes, batch)
+		for i, o := range batch.outcomes {
+			n.PVCs[i].Cause = c.nodeCauses(o.cand.nodes)
+			n.PVCs[i].Owner = owner
+		}
+		n.Abandoned = []abandonedCleanup{}
+		c.redactor.redactNotification(&n)
+		err := c.ownerRoutes[owner].send(ctx, n)
+		if err != nil {
+			logf(ctx, "failed to send %s notification to owner(%s): %v\n", event, owner, err)
+			continue
+		}
+		if !simulated(ctx) {
+			c.notified.sent(batch)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// pausedKey in the controller configmap suspends all deletions when "true".
+const pausedKey = "paused"
+
+// isPaused reports whether deletions are suspended. The controller configmap
+// is the source of truth when a controller namespace is configured so the
+// state survives restarts.
+func (c *cleaner) isPaused(ctx context.Context) bool {
+	if c.namespace == "" {
+		return c.pausedFlag.Load()
+	}
+
+	cm, err := c.controllerObject(ctx)
+	if err != nil {
+		fmt.Printf("failed to get controller configmap, assuming paused: %v\n", err)
+		return true
+	}
+	return cm.Data[pausedKey] == "true"
+}
+
+// setPaused suspends or resumes deletions. Resuming triggers a reconcile to
+// process the orphans found while paused.
+func (c *cleaner) setPaused(ctx context.Context, paused bool) error {
+	if c.namespace == "" {
+		c.pausedFlag.Store(paused)
+	} else {
+		_, err := c.controllerObject(ctx)
+		if err != nil {
+			return err
+		}
+
+		patch, err := json.Marshal(map[string]any{
+			"data": map[string]string{pausedKey: fmt.Sprint(paused)},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = c.clientset.CoreV1().ConfigMaps(c.namespace).Patch(ctx, controllerConfigMapName, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	if paused {
+		fmt.Printf("paused deletions\n")
+		return nil
+	}
+
+	fmt.Printf("resumed deletions\n")
+	c.triggerReconcile()
+	return nil
+}
+
+// keys of the controller configmap listing the namespaces and node pools whose
+// deletions are suspended while the others proceed.
+const (
+	pausedNamespacesKey = "paused-namespaces"
+	pausedNodePoolsKey  = "paused-node-pools"
+)
+
+// pauseScopes are the namespaces and node pools whose deletions are suspended.
+type pauseScopes struct {
+	Namespaces stringList `json:"namespaces"`
+	NodePools  stringList `json:"nodePools"`
+}
+
+// covers reports whether a claim is in a paused namespace or on a node of a
+// paused node pool.
+func (s pauseScopes) covers(cand candidate, pools *nodePools) bool {
+	if s.Namespaces.contains(cand.pvc.Namespace) {
+		return true
+	}
+	if pools == nil || len(s.NodePools) == 0 {
+		return false
+	}
+	for _, nodeName := range cand.nodes {
+		if pool := pools.pool(nodeName); pool != "" && s.NodePools.contains(pool) {
+			return true
+		}
+	}
+	return false
+}
+
+// pausedScopes returns the namespaces and node pools whose deletions are
+// suspended, read from the controller configmap like isPaused.
+func (c *cleaner) pausedScopes(ctx context.Context) pauseScopes {
+	if c.namespace == "" {
+		c.pausedScopesMu.Lock()
+		defer c.pausedScopesMu.Unlock()
+		return c.pausedScopesValue
+	}
+
+	cm, err := c.controllerObject(ctx)
+	if err != nil {
+		fmt.Printf("failed to get controller configmap, assuming no paused scopes: %v\n", err)
+		return pauseScopes{}
+	}
+
+	var scopes pauseScopes
+	scopes.Namespaces.Set(cm.Data[pausedNamespacesKey])
+	scopes.NodePools.Set(cm.Data[pausedNodePoolsKey])
+	return scopes
+}
+
+// setScopePaused suspends or resumes the deletions of a namespace or a node
+// pool. Resuming triggers a reconcile to process the orphans found while
+// paused.
+func (c *cleaner) setScopePaused(ctx context.Context, namespace, nodePool string, paused bool) error {
+	update := func(list stringList, value string) stringList {
+		if value == "" {
+			return list
+		}
+		var updated stringList
+		for _, item := range list {
+			if item != value {
+				updated = append(updated, item)
+			}
+		}
+		if paused {
+			updated = append(updated, value)
+		}
+		return updated
+	}
+
+	c.pausedScopesMu.Lock()
+	defer c.pausedScopesMu.Unlock()
+
+	if c.namespace == "" {
+		c.pausedScopesValue.Namespaces = update(c.pausedScopesValue.Namespaces, namespace)
+		c.pausedScopesValue.NodePools = update(c.pausedScopesValue.NodePools, nodePool)
+	} else {
+		cm, err := c.controllerObject(ctx)
+		if err != nil {
+			return err
+		}
+
+		var scopes pauseScopes
+		scopes.Namespaces.Set(cm.Data[pausedNamespacesKey])
+		scopes.NodePools.Set(cm.Data[pausedNodePoolsKey])
+		scopes.Namespaces = update(scopes.Namespaces, namespace)
+		scopes.NodePools = update(scopes.NodePools, nodePool)
+
+		patch, err := json.Marshal(map[string]any{
+			"data": map[string]string{
+				pausedNamespacesKey: scopes.Namespaces.String(),
+				pausedNodePoolsKey:  scopes.NodePools.String(),
+			},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = c.clientset.CoreV1().ConfigMaps(c.namespace).Patch(ctx, controllerConfigMapName, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	scope := fmt.Sprintf("namespace(%s)", namespace)
+	if nodePool != "" {
+		scope = fmt.Sprintf("node pool(%s)", nodePool)
+	}
+	if paused {
+		fmt.Printf("paused deletions in %s\n", scope)
+		return nil
+	}
+
+	fmt.Printf("resumed deletions in %s\n", scope)
+	c.triggerReconcile()
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// planResource is the cluster scoped CleanupPlan custom resource the
+// controller publishes its would-be deletions on in report mode. Its
+// definition ships in the README.
+var planResource = schema.GroupVersionResource{
+	Group:    "local-pvc-cleaner.io",
+	Version:  "v1alpha1",
+	Resource: "cleanupplans",
+}
+
+const planResourceName = "local-pvc-cleaner"
+
+// plannedClaim is a claim the cleaner would delete, as listed in the status of
+// the plan and approved in its spec.
+type plannedClaim struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	UID       types.UID `json:"uid"`
+	Volume    string    `json:"volume,omitempty"`
+	Nodes     []string  `json:"nodes,omitempty"`
+}
+
+// cleanupPlan collects the would-be deletions of a reconcile in report mode and
+// the claims approved for deletion in the spec of the plan resource, which is
+// left to GitOps tooling.
+type cleanupPlan struct {
+	client dynamic.Interface
+
+	mu       sync.Mutex
+	approved map[types.UID]plannedClaim
+	proposed []plannedClaim
+}
+
+func newCleanupPlan(client dynamic.Interface) *cleanupPlan {
+	return &cleanupPlan{client: client, approved: map[types.UID]plannedClaim{}}
+}
+
+// loadApprovals reads the claims approved in the spec of the plan resource and
+// starts collecting the would-be deletions of a reconcile.
+func (c *cleaner) loadApprovals(ctx context.Context) {
+	p := c.plan
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.proposed = nil
+	p.mu.Unlock()
+
+	obj, err := p.client.Resource(planResource).Get(ctx, planResourceName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return
+	}
+	if err != nil {
+		logf(ctx, "failed to get %s/%s, keeping the previous approvals: %v\n", planResource.Resource, planResourceName, err)
+		return
+	}
+
+	var spec struct {
+		Approved []plannedClaim `json:"approved"`
+	}
+	value, err := json.Marshal(obj.Object["spec"])
+	if err == nil {
+		err = json.Unmarshal(value, &spec)
+	}
+	if err != nil {
+		logf(ctx, "invalid spec of %s/%s, keeping the previous approvals: %v\n", planResource.Resource, planResourceName, err)
+		return
+	}
+
+	approved := map[types.UID]plannedClaim{}
+	for _, claim := range spec.Approved {
+		if claim.UID == "" {
+			logf(ctx, "ignoring approval of pvc(%s/%s) without uid\n", claim.Namespace, claim.Name)
+			continue
+		}
+		approved[claim.UID] = claim
+	}
+	p.mu.Lock()
+	p.approved = approved
+	p.mu.Unlock()
+}
+
+// planDecision returns whether an orphan found in report mode was approved
+// in the plan, and otherwise proposes it and skips it.
+func (c *cleaner) planDecision(ctx context.Context, cand candidate) (decision, bool) {
+	p := c.plan
+	if p == nil {
+		return decisionSkippedReportMode, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	claim, ok := p.approved[cand.pvc.UID]
+	if ok && claim.Namespace == cand.pvc.Namespace && claim.Name == cand.pvc.Name {
+		logf(ctx, "pvc(%s/%s) was approved in the cleanup plan\n", cand.pvc.Namespace, cand.pvc.Name)
+		return "", true
+	}
+	p.proposed = append(p.proposed, plannedClaim{
+		Namespace: cand.pvc.Namespace,
+		Name:      cand.pvc.Name,
+		UID:       cand.pvc.UID,
+		Volume:    cand.pvc.Spec.VolumeName,
+		Nodes:     cand.nodes,
+	})
+	return decisionSkippedReportMode, false
+}
+
+// publishPlan writes the would-be deletions of a reconcile to the status of the
+// plan resource, creating it when missing.
+func (c *cleaner) publishPlan(ctx context.Context) {
+	p := c.plan
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	proposed := append([]plannedClaim{}, p.proposed...)
+	p.mu.Unlock()
+	sort.Slice(proposed, func(i, j int) bool {
+		if proposed[i].Namespace != proposed[j].Namespace {
+			return proposed[i].Namespace < proposed[j].Namespace
+		}
+		return proposed[i].Name < proposed[j].Name
+	})
+
+	value, err := json.Marshal(map[string]any{
+		"proposed":     proposed,
+		"observedTime": time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		logf(ctx, "failed to marshal cleanup plan: %v\n", err)
+		return
+	}
+	status := map[string]any{}
+	err = json.Unmarshal(value, &status)
+	if err != nil {
+		logf(ctx, "failed to convert cleanup plan: %v\n", err)
+		return
+	}
+
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": planResource.GroupVersion().String(),
+		"kind":       "CleanupPlan",
+		"metadata":   map[string]any{"name": planResourceName},
+	}}
+	client := p.client.Resource(planResource)
+	_, err = client.Apply(ctx, planResourceName, obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+	if err == nil {
+		obj.Object["status"] = status
+		_, err = client.ApplyStatus(ctx, planResourceName, obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+	}
+	if err != nil {
+		logf(ctx, "failed to update %s/%s: %v\n", planResource.Resource, planResourceName, err)
+		return
+	}
+	if len(proposed) > 0 {
+		c.controllerEvent(ctx, corev1.EventTypeNormal, "CleanupPlanned", "%d pvcs await approval in %s/%s", len(proposed), planResource.Resource, planResourceName)
+	}
+}
+
+// validatePlan checks the plan is only used in report mode, where nothing is
+// deleted without its approval.
+func validatePlan(mode string) error {
+	if mode != modeReport {
+		return fmt.Errorf("the cleanup plan needs --mode %s, got %s", modeReport, mode)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	podActionDelete = "delete"
+	podActionEvict  = "evict"
+)
+
+// evictionBackoff is used to retry evictions rejected by a pod disruption
+// budget.
+var evictionBackoff = wait.Backoff{
+	Duration: 5 * time.Second,
+	Factor:   2,
+	Steps:    6,
+}
+
+// consumerPods returns the pods using a claim from the informer cache.
+// It returns no pods when pods are not managed.
+func (c *cleaner) consumerPods(pvc *corev1.PersistentVolumeClaim) ([]*corev1.Pod, error) {
+	if c.podFactory == nil {
+		return nil, nil
+	}
+
+	objs, err := c.podFactory.Core().V1().Pods().Informer().GetIndexer().ByIndex(podByPvcIndex, pvc.Namespace+"/"+pvc.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := make([]*corev1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		pods = append(pods, obj.(*corev1.Pod))
+	}
+	return pods, nil
+}
+
+// podBatches paces pod removals into batches of a given size with a delay
+// between their starts, so cleaning up nodes with hundreds of consumer pods
+// does not flood the scheduler and provisioner with rescheduled pods at once.
+type podBatches struct {
+	size  int
+	delay time.Duration
+
+	mu    sync.Mutex
+	count int
+	start time.Time
+}
+
+// wait blocks until the next pod may be removed. Batches started longer than
+// the delay ago do not block.
+func (b *podBatches) wait(ctx context.Context) error {
+	if b == nil || b.size <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.count >= b.size {
+		remaining := time.Until(b.start.Add(b.delay))
+		if remaining > 0 {
+			logf(ctx, "removed %d pods, waiting %s before the next batch\n", b.count, remaining.Round(time.Second))
+			select {
+			case <-time.After(remaining):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		b.count = 0
+	}
+	if b.count == 0 {
+		b.start = time.Now()
+	}
+	b.count++
+	return nil
+}
+
+// removePods removes the pods consuming a cleaned up claim, logging the ones
+// that could not be removed. It stops with errCircuitOpen once the circuit
+// breaker holds the calls back, so the cleanup is skipped rather than waiting
+// on pods that were never removed.
+func (c *cleaner) removePods(ctx context.Context, pods []*corev1.Pod) error {
+	for _, pod := range pods {
+		err := c.podBatches.wait(ctx)
+		if err != nil {
+			logf(ctx, "stopped removing pods: %v\n", err)
+			return nil
+		}
+
+		err = c.deleteCall(ctx, func() error {
+			return c.removePod(ctx, pod)
+		})
+		if errors.Is(err, errCircuitOpen) {
+			logf(ctx, "stopped removing pods: %v\n", err)
+			return err
+		}
+		if err != nil {
+			logf(ctx, "failed to %s pod(%s): %v\n", c.podAction, pod.Name, err)
+			continue
+		}
+
+		if c.podAction == podActionEvict {
+			logf(ctx, "evicted pod(%s)\n", pod.Name)
+			continue
+		}
+		logf(ctx, "deleted pod(%s)\n", pod.Name)
+	}
+	return nil
+}
+
+// removePod deletes or evicts a pod consuming a cleaned up claim depending on
+// the configured pod action.
+func (c *cleaner) removePod(ctx context.Context, pod *corev1.Pod) error {
+	client, err := c.clientFor(pod.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if c.podAction != podActionEvict {
+		return client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+	}
+
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+	}
+
+	var lastErr error
+	err = wait.ExponentialBackoffWithContext(ctx, evictionBackoff, func() (bool, error) {
+		lastErr = client.CoreV1().Pods(pod.Namespace).EvictV1(ctx, eviction)
+		if lastErr == nil || apierrors.IsNotFound(lastErr) {
+			return true, nil
+		}
+		if apierrors.IsTooManyRequests(lastErr) {
+			logf(ctx, "eviction of pod(%s) blocked by pod disruption budget, retrying\n", pod.Name)
+			return false, nil
+		}
+		return false, lastErr
+	})
+	if err == wait.ErrWaitTimeout && apierrors.IsTooManyRequests(lastErr) {
+		podEvictionsBlocked.Inc()
+		c.eventf(ctx, pod, corev1.EventTypeWarning, "EvictionBlocked", "eviction blocked by pod disruption budget: %v", lastErr)
+		return fmt.Errorf("blocked by pod disruption budget: %w", lastErr)
+	}
+	return err
+}
+
+// stuckPod reports whether a pod scheduled to one of the gone nodes is stuck
+// in the Unknown phase or terminating. Without a kubelet to confirm its
+// shutdown it never goes away and keeps the pvc-protection finalizer from
+// releasing its claim.
+func stuckPod(pod *corev1.Pod, nodes []string) bool {
+	if !stringList(nodes).contains(pod.Spec.NodeName) {
+		return false
+	}
+	return pod.Status.Phase == corev1.PodUnknown || pod.DeletionTimestamp != nil
+}
+
+// releaseProtection force deletes exactly the stuck pods of a claim on the
+// gone nodes, so deleting the claim is not blocked by its pvc-protection
+// finalizer. Pods recreated since they were cached are left alone.
+func (c *cleaner) releaseProtection(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pods []*corev1.Pod, nodes []string) {
+	client, err := c.clientFor(pvc.Namespace)
+	if err != nil {
+		logf(ctx, "failed to get client for namespace(%s): %v\n", pvc.Namespace, err)
+		return
+	}
+
+	grace := int64(0)
+	for _, pod := range pods {
+		if !stuckPod(pod, nodes) {
+			continue
+		}
+		uid := pod.UID
+		err := c.deleteCall(ctx, func() error {
+			return client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{
+				GracePeriodSeconds: &grace,
+				Preconditions:      &metav1.Preconditions{UID: &uid},
+			})
+		})
+		if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+			continue
+		}
+		if err != nil {
+			logf(ctx, "failed to force delete stuck pod(%s): %v\n", pod.Name, err)
+			continue
+		}
+		stuckPodsForceDeleted.Inc()
+		logf(ctx, "force deleted pod(%s) stuck on node(%s)\n", pod.Name, pod.Spec.NodeName)
+		c.eventf(ctx, pvc, corev1.EventTypeWarning, "ForceDeletedPod", "force deleted pod(%s) stuck on gone node(%s) holding the pvc-protection finalizer", pod.Name, pod.Spec.NodeName)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "context"
+
+// defaultProtectedNamespaces hold cluster critical components and are never
+// cleaned up unless explicitly overridden.
+var defaultProtectedNamespaces = stringList{"kube-system", "kube-public", "kube-node-lease"}
+
+// policyDecision returns why the configured policies keep an orphan from
+// being cleaned up, or an empty decision.
+func (c *cleaner) policyDecision(ctx context.Context, cand candidate) decision {
+	if !c.cleanProtectedNamespaces && (defaultProtectedNamespaces.contains(cand.pvc.Namespace) || c.protectedNamespaces.contains(cand.pvc.Namespace)) {
+		tracef("pvc(%s/%s) is in a protected namespace\n", cand.pvc.Namespace, cand.pvc.Name)
+		return decisionSkippedNamespaceProtected
+	}
+	if c.namespaceProtected(ctx, cand.pvc.Namespace) {
+		tracef("pvc(%s/%s) is in a namespace protecting its pvcs\n", cand.pvc.Namespace, cand.pvc.Name)
+		return decisionSkippedNamespaceProtected
+	}
+	if workload := c.protectingWorkload(cand.pvc); workload != "" {
+		tracef("pvc(%s/%s) is protected by %s\n", cand.pvc.Namespace, cand.pvc.Name, workload)
+		return decisionSkippedWorkloadProtected
+	}
+
+	return ""
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// apiServerLoad notices when the api server sheds load, which api priority and
+// fairness does by rejecting requests of saturated priority levels with 429
+// Too Many Requests, so cleanups can be deferred until it recovered.
+type apiServerLoad struct {
+	cooldown time.Duration
+
+	mu         sync.Mutex
+	throttled  time.Time
+	retryAfter time.Duration
+	deferred   bool
+}
+
+func newAPIServerLoad(cooldown time.Duration) *apiServerLoad {
+	return &apiServerLoad{cooldown: cooldown}
+}
+
+// wrap observes the responses of every api request of a config.
+func (l *apiServerLoad) wrap(config *rest.Config) {
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &loadObserver{next: rt, load: l}
+	})
+}
+
+// high reports whether the api server rejected a request within the cooldown,
+// or the longer retry after it asked for.
+func (l *apiServerLoad) high() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !l.throttled.IsZero() && time.Since(l.throttled) < l.wait()
+}
+
+// wait returns how long cleanups are deferred after a rejection. It must be
+// called with the lock held.
+func (l *apiServerLoad) wait() time.Duration {
+	if l.retryAfter > l.cooldown {
+		return l.retryAfter
+	}
+	return l.cooldown
+}
+
+// deferCleanup runs retry once the load is over, at most once per deferral.
+func (l *apiServerLoad) deferCleanup(retry func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.deferred {
+		return
+	}
+	l.deferred = true
+	time.AfterFunc(l.wait()-time.Since(l.throttled), func() {
+		l.mu.Lock()
+		l.deferred = false
+		l.mu.Unlock()
+		retry()
+	})
+}
+
+func (l *apiServerLoad) observe(resp *http.Response) {
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+	apiServerThrottled.Inc()
+	retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+	l.mu.Lock()
+	l.throttled = time.Now()
+	l.retryAfter = time.Duration(retryAfter) * time.Second
+	l.mu.Unlock()
+}
+
+type loadObserver struct {
+	next http.RoundTripper
+	load *apiServerLoad
+}
+
+func (o *loadObserver) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := o.next.RoundTrip(req)
+	if err == nil {
+		o.load.observe(resp)
+	}
+	return resp, err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// annotations recorded on a volume right before it is deleted so audit logs
+// and etcd backups attribute the deletion to the cleaner and the node loss
+// that caused it.
+const (
+	deletedByAnnotation      = "local-pvc-cleaner.io/deleted-by"
+	deletionReasonAnnotation = "local-pvc-cleaner.io/deletion-reason"
+	deletedNodeAnnotation    = "local-pvc-cleaner.io/deleted-node"
+	deletedAtAnnotation      = "local-pvc-cleaner.io/deleted-at"
+)
+
+const (
+	// reasonNodeDeleted is recorded on volumes of claims whose node is gone.
+	reasonNodeDeleted = "node-deleted"
+	// reasonDanglingVolume is recorded on volumes whose claim and node are gone.
+	reasonDanglingVolume = "dangling-volume"
+)
+
+// recordProvenance annotates a volume with who deletes it, why and for which
+// nodes.
+func (c *cleaner) recordProvenance(ctx context.Context, pvName, reason string, nodes []string) error {
+	annotations := map[string]string{
+		deletedByAnnotation:      "local-pvc-cleaner",
+		deletionReasonAnnotation: reason,
+		deletedNodeAnnotation:    strings.Join(nodes, ","),
+		deletedAtAnnotation:      time.Now().UTC().Format(time.RFC3339),
+	}
+	if id := correlationID(ctx); id != "" {
+		annotations[correlationIDAnnotation] = id
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.clientset.CoreV1().PersistentVolumes().Patch(ctx, pvName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// reclaimTimeout waits up to the provisioner timeout for the provisioner
+	// to reclaim volumes before deleting them directly.
+	reclaimTimeout = "timeout"
+	// reclaimDirect deletes volumes directly right away.
+	reclaimDirect = "direct"
+	// reclaimAuto delegates reclaiming volumes to the provisioner while its
+	// deployment is available and deletes them directly otherwise.
+	reclaimAuto = "auto"
+)
+
+// defaultDelegateTimeout is how long the auto reclaim mode waits for a healthy
+// provisioner when no provisioner timeout is configured.
+const defaultDelegateTimeout = time.Minute
+
+// provisionerAvailable reports whether the provisioner deployment exists and
+// has an available replica. Errors other than a missing deployment are
+// returned so a flaky api does not switch the cleaner to direct deletion.
+func (c *cleaner) provisionerAvailable(ctx context.Context) (bool, error) {
+	namespace, name, _ := strings.Cut(c.provisionerDeployment, "/")
+	deployment, err := c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return deployment.Status.AvailableReplicas > 0, nil
+}
+
+// delegateTimeout returns how long to wait for the provisioner to reclaim a
+// volume before deleting it directly, zero to delete it right away.
+func (c *cleaner) delegateTimeout(ctx context.Context) time.Duration {
+	switch c.reclaimMode {
+	case reclaimDirect:
+		return 0
+	case reclaimAuto:
+		available, err := c.provisionerAvailable(ctx)
+		if err != nil {
+			logf(ctx, "failed to get provisioner deployment(%s), waiting for it to reclaim: %v\n", c.provisionerDeployment, err)
+		} else if !available {
+			logf(ctx, "provisioner deployment(%s) is missing or unavailable, deleting volumes directly\n", c.provisionerDeployment)
+			return 0
+		}
+		if c.provisionerTimeout > 0 {
+			return c.provisionerTimeout
+		}
+		return defaultDelegateTimeout
+	}
+	return c.provisionerTimeout
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// orphanedAtAnnotation marks a quarantined claim with the time its node was
+// first found missing.
+const orphanedAtAnnotation = "local-pvc-cleaner.io/orphaned-at"
+
+// graceAnnotation on a claim or its namespace overrides the grace period, like
+// "24h" to buy a cautious team more time or "0s" to clean up scratch
+// workloads right away.
+const graceAnnotation = "local-pvc-cleaner.io/grace"
+
+// claimGracePeriod returns the grace period of a claim, lengthened while the
+// node churn is high.
+func (c *cleaner) claimGracePeriod(ctx context.Context, pvc *corev1.PersistentVolumeClaim) time.Duration {
+	return c.churn.scale(c.configuredGracePeriod(ctx, pvc))
+}
+
+// configuredGracePeriod returns the configured grace period of a claim: its
+// own grace annotation, then the one of its namespace, then the global grace
+// period. Invalid annotations are logged and ignored.
+func (c *cleaner) configuredGracePeriod(ctx context.Context, pvc *corev1.PersistentVolumeClaim) time.Duration {
+	value, ok := pvc.Annotations[graceAnnotation]
+	source := "pvc(" + pvc.Namespace + "/" + pvc.Name + ")"
+	if !ok {
+		ns, err := c.namespaceObject(ctx, pvc.Namespace)
+		if err != nil {
+			tracef("failed to get namespace(%s): %v\n", pvc.Namespace, err)
+			return c.gracePeriod
+		}
+		value, ok = ns.Annotations[graceAnnotation]
+		source = "namespace(" + pvc.Namespace + ")"
+	}
+	if !ok {
+		return c.gracePeriod
+	}
+
+	grace, err := time.ParseDuration(value)
+	if err != nil || grace < 0 {
+		logf(ctx, "invalid %s annotation %q on %s, using the grace period of %s\n", graceAnnotation, value, source, c.gracePeriod)
+		return c.gracePeriod
+	}
+	return grace
+}
+
+// patchClaimAnnotations merges the given annotations into a claim, removing
+// the ones set to nil.
+func (c *cleaner) patchClaimAnnotations(ctx context.Context, pvc *corev1.PersistentVolumeClaim, annotations map[string]*string) error {
+	if simulated(ctx) {
+		return nil
+	}
+	client, err := c.clientFor(pvc.Namespace)
+	if err != nil {
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Patch(ctx, pvc.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// quarantineDecision holds an orphan until the grace period since it was first
+// seen has passed. It returns an empty decision once the orphan may be cleaned
+// up or when no grace period is configured.
+func (c *cleaner) quarantineDecision(ctx context.Context, cand candidate) decision {
+	gracePeriod := c.claimGracePeriod(ctx, cand.pvc)
+	if gracePeriod <= 0 {
+		return ""
+	}
+	if c.expected != nil && c.expected.covers(cand.nodes) {
+		logf(ctx, "node(s) %v of pvc(%s) were expected to be removed, skipping the grace period\n", cand.nodes, cand.pvc.Name)
+		return ""
+	}
+
+	now := time.Now()
+	value, ok := cand.pvc.Annotations[orphanedAtAnnotation]
+	if !ok {
+		orphanedAt := now.UTC().Format(time.RFC3339)
+		err := c.patchClaimAnnotations(ctx, cand.pvc, map[string]*string{orphanedAtAnnotation: &orphanedAt})
+		if err != nil {
+			logf(ctx, "failed to quarantine pvc(%s): %v\n", cand.pvc.Name, err)
+			return decisionFailed
+		}
+
+		logf(ctx, "quarantined pvc(%s) for %s\n", cand.pvc.Name, gracePeriod)
+		if cand.lost {
+			c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "Quarantined", "volume(%s) is gone, deleting after %s", cand.pvc.Spec.VolumeName, gracePeriod)
+		} else if cause := c.nodeCauses(cand.nodes); cause != "" {
+			c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "Quarantined", "node(s) %v are gone (%s), deleting after %s", cand.nodes, cause, gracePeriod)
+		} else {
+			c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "Quarantined", "node(s) %v are gone, deleting after %s", cand.nodes, gracePeriod)
+		}
+		c.scheduleReconcile(ctx, "quarantine/"+string(cand.pvc.UID), gracePeriod)
+		return decisionSkippedGracePeriod
+	}
+
+	orphanedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logf(ctx, "invalid %s annotation on pvc(%s): %v\n", orphanedAtAnnotation, cand.pvc.Name, err)
+		return decisionFailed
+	}
+
+	remaining := orphanedAt.Add(gracePeriod).Sub(now)
+	if remaining > 0 {
+		tracef("pvc(%s/%s) is quarantined for another %s\n", cand.pvc.Namespace, cand.pvc.Name, remaining)
+		// quarantines started before a restart have no timer yet
+		c.scheduleReconcile(ctx, "quarantine/"+string(cand.pvc.UID), remaining)
+		return decisionSkippedGracePeriod
+	}
+
+	return ""
+}
+
+// quarantineDeadline returns when a quarantined claim gets cleaned up. Claims
+// that were just quarantined are not marked in the cache yet and count from
+// now.
+func (c *cleaner) quarantineDeadline(ctx context.Context, pvc *corev1.PersistentVolumeClaim) time.Time {
+	orphanedAt, err := time.Parse(time.RFC3339, pvc.Annotations[orphanedAtAnnotation])
+	if err != nil {
+		orphanedAt = time.Now()
+	}
+	return orphanedAt.Add(c.claimGracePeriod(ctx, pvc))
+}
+
+// approveClaim ends the quarantine of a claim early so the next reconcile
+// cleans it up.
+func (c *cleaner) approveClaim(ctx context.Context, namespace, name string) error {
+	if c.reportOnly() {
+		return errReportMode
+	}
+
+	pvc, err := c.factory.Core().V1().PersistentVolumeClaims().Lister().PersistentVolumeClaims(namespace).Get(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := pvc.Annotations[orphanedAtAnnotation]; !ok {
+		return fmt.Errorf("pvc %s/%s is not quarantined", namespace, name)
+	}
+
+	orphanedAt := time.Now().Add(-c.claimGracePeriod(ctx, pvc)).UTC().Format(time.RFC3339)
+	approvedAt := time.Now().UTC().Format(time.RFC3339)
+	err = c.patchClaimAnnotations(ctx, pvc, map[string]*string{orphanedAtAnnotation: &orphanedAt, approvedAnnotation: &approvedAt})
+	if err != nil {
+		return err
+	}
+
+	logf(ctx, "approved cleanup of pvc(%s/%s)\n", namespace, name)
+	c.triggerReconcile()
+	return nil
+}
+
+// resolvedDecision reports whether a decision means a claim no longer resolves
+// to a missing node: its node is back, or it was bound to a volume on an
+// existing node, to a non-local volume or to none at all.
+func resolvedDecision(d decision) bool {
+	return d == decisionSkippedNodeExists || d == decisionSkippedNonLocalVolume || d == decisionSkippedUnclassifiable
+}
+
+// releaseQuarantine removes the quarantine marker from a claim that no longer
+// resolves to a missing node, unless its node is still about to be removed.
+func (c *cleaner) releaseQuarantine(ctx context.Context, cand candidate, d decision) {
+	if _, ok := cand.pvc.Annotations[orphanedAtAnnotation]; !ok {
+		return
+	}
+	if c.nodesLeaving(cand.nodes) {
+		return
+	}
+
+	err := c.patchClaimAnnotations(ctx, cand.pvc, map[string]*string{orphanedAtAnnotation: nil, approvedAnnotation: nil})
+	if err != nil {
+		logf(ctx, "failed to release pvc(%s) from quarantine: %v\n", cand.pvc.Name, err)
+		return
+	}
+
+	logf(ctx, "released pvc(%s) from quarantine\n", cand.pvc.Name)
+	if d == decisionSkippedNodeExists {
+		c.eventf(ctx, cand.pvc, corev1.EventTypeNormal, "CleanupCancelled", "node(s) %v exist", cand.nodes)
+		return
+	}
+	c.eventf(ctx, cand.pvc, corev1.EventTypeNormal, "CleanupCancelled", "the pvc is no longer bound to a volume on a missing node")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestQuarantineDecision(t *testing.T) {
+	now := time.Now()
+	orphanedAt := func(ago time.Duration) map[string]string {
+		return map[string]string{orphanedAtAnnotation: now.Add(-ago).UTC().Format(time.RFC3339)}
+	}
+
+	tests := []struct {
+		name                 string
+		gracePeriod          time.Duration
+		annotations          map[string]string
+		namespaceAnnotations map[string]string
+		want                 decision
+		// due is when a reconcile is scheduled for the claim, zero for none.
+		due time.Duration
+		// quarantined is whether the claim gets the orphaned at annotation.
+		quarantined bool
+	}{
+		{
+			name:        "no grace period",
+			gracePeriod: 0,
+			want:        "",
+		},
+		{
+			name:        "first seen",
+			gracePeriod: time.Hour,
+			want:        decisionSkippedGracePeriod,
+			due:         time.Hour,
+			quarantined: true,
+		},
+		{
+			name:        "within grace period",
+			gracePeriod: time.Hour,
+			annotations: orphanedAt(10 * time.Minute),
+			want:        decisionSkippedGracePeriod,
+			due:         50 * time.Minute,
+			quarantined: true,
+		},
+		{
+			name:        "grace period passed",
+			gracePeriod: time.Hour,
+			annotations: orphanedAt(2 * time.Hour),
+			want:        "",
+			quarantined: true,
+		},
+		{
+			name:        "invalid orphaned at",
+			gracePeriod: time.Hour,
+			annotations: map[string]string{orphanedAtAnnotation: "yesterday"},
+			want:        decisionFailed,
+			quarantined: true,
+		},
+		{
+			name:        "claim grace annotation",
+			gracePeriod: time.Hour,
+			annotations: map[string]string{graceAnnotation: "0s"},
+			want:        "",
+		},
+		{
+			name:                 "namespace grace annotation",
+			gracePeriod:          time.Hour,
+			annotations:          orphanedAt(90 * time.Minute),
+			namespaceAnnotations: map[string]string{graceAnnotation: "2h"},
+			want:                 decisionSkippedGracePeriod,
+			due:                  30 * time.Minute,
+			quarantined:          true,
+		},
+		{
+			name:        "invalid grace annotation",
+			gracePeriod: time.Hour,
+			annotations: map[string]string{graceAnnotation: "soon", orphanedAtAnnotation: now.Add(-2 * time.Hour).UTC().Format(time.RFC3339)},
+			want:        "",
+			quarantined: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: test.namespaceAnnotations}}
+			pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "data",
+				UID:         "uid",
+				Annotations: test.annotations,
+			}}
+			client := fake.NewSimpleClientset(ns, pvc)
+			c := &cleaner{
+				clientset:   client,
+				recorder:    record.NewFakeRecorder(10),
+				gracePeriod: test.gracePeriod,
+				stats:       newCleanupStats(),
+				timers:      newReconcileTimers(),
+				reconcileCh: make(chan struct{}, 1),
+			}
+
+			ctx := context.Background()
+			got := c.quarantineDecision(ctx, candidate{pvc: pvc, nodes: []string{"node1"}})
+			if got != test.want {
+				t.Errorf("got decision %q, want %q", got, test.want)
+			}
+
+			due, ok := c.timers.due["quarantine/uid"]
+			if ok != (test.due > 0) {
+				t.Fatalf("got reconcile scheduled %t, want %t", ok, test.due > 0)
+			}
+			// the orphaned at annotation has a resolution of a second
+			if ok && (due.Before(now.Add(test.due-2*time.Second)) || due.After(now.Add(test.due+2*time.Second))) {
+				t.Errorf("got reconcile due in %s, want %s", due.Sub(now).Round(time.Second), test.due)
+			}
+
+			current, err := client.CoreV1().PersistentVolumeClaims("default").Get(ctx, "data", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := current.Annotations[orphanedAtAnnotation]; ok != test.quarantined {
+				t.Errorf("got quarantined %t, want %t", ok, test.quarantined)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// rbacName is the name of the roles printed for the configuration.
+const rbacName = "local-pvc-cleaner"
+
+// permission is a rule the configuration of the cleaner needs. Rules without a
+// namespace are needed cluster wide.
+type permission struct {
+	namespace     string
+	group         string
+	resource      string
+	resourceNames []string
+	verbs         []string
+}
+
+type permissions []permission
+
+// add merges verbs into the rule of the same namespace, resource and resource
+// names, adding the rule when there is none yet.
+func (p *permissions) add(namespace, group, resource string, names []string, verbs ...string) {
+	for i, rule := range *p {
+		if rule.namespace == namespace && rule.group == group && rule.resource == resource && slices.Equal(rule.resourceNames, names) {
+			(*p)[i].verbs = sets.List(sets.New(rule.verbs...).Insert(verbs...))
+			return
+		}
+	}
+	*p = append(*p, permission{namespace: namespace, group: group, resource: resource, resourceNames: names, verbs: sets.List(sets.New(verbs...))})
+}
+
+// verbs returns the verbs needed on a resource in any namespace.
+func (p permissions) verbs(group, resource string) sets.Set[string] {
+	verbs := sets.New[string]()
+	for _, rule := range p {
+		if rule.group == group && rule.resource == resource {
+			verbs.Insert(rule.verbs...)
+		}
+	}
+	return verbs
+}
+
+// requiredPermissions returns the rules the cleaner needs with its current
+// configuration, leaving out the ones of disabled features, like deleting
+// pods when --delete-pods is off.
+func (c *cleaner) requiredPermissions() permissions {
+	// approved cleanup plans are carried out in report mode
+	mutating := !c.reportOnly() || c.plan != nil
+
+	var p permissions
+	p.add("", "", "nodes", nil, "list", "watch")
+	p.add("", "", "persistentvolumes", nil, "get", "list", "watch")
+	p.add("", "", "persistentvolumeclaims", nil, "get", "list", "watch")
+	p.add("", "storage.k8s.io", "storageclasses", nil, "list", "watch")
+	p.add("", "", "events", nil, "create", "patch")
+	p.add("", "", "namespaces", nil, "get")
+	if c.cacheNamespaces {
+		p.add("", "", "namespaces", nil, "list", "watch")
+	}
+
+	if c.namespace != "" {
+		p.add(c.namespace, "", "configmaps", nil, "get", "list", "watch", "create", "patch")
+	}
+	if c.nodeMappingConfigMap != "" {
+		namespace, name, _ := strings.Cut(c.nodeMappingConfigMap, "/")
+		p.add(namespace, "", "configmaps", []string{name}, "get")
+		p.add("", "", "persistentvolumeclaims", nil, "update")
+		p.add("", "", "persistentvolumes", nil, "update")
+	}
+
+	if mutating {
+		p.add("", "", "persistentvolumeclaims", nil, "patch")
+		if c.deletePVCs && !c.externalDeletion() {
+			p.add("", "", "persistentvolumeclaims", nil, "delete")
+		}
+		if c.deletePVCs && c.batchDeletes {
+			p.add("", "", "persistentvolumeclaims", nil, "deletecollection")
+		}
+		if c.deletePVCs && c.recreateClaims {
+			p.add("", "", "persistentvolumeclaims", nil, "create")
+		}
+		if c.deletePVs {
+			p.add("", "", "persistentvolumes", nil, "patch", "delete")
+		}
+		if c.deleteVolumeAttachments {
+			p.add("", "storage.k8s.io", "volumeattachments", nil, "delete")
+		}
+		if c.tombstoneLimit > 0 && c.deletePVCs {
+			p.add("", "", "configmaps", nil, "get", "create", "patch")
+		}
+		if c.artifactRetention > 0 && !c.reportOnly() {
+			p.add("", "", "configmaps", nil, "list", "delete", "patch")
+		}
+	}
+
+	if c.podFactory != nil {
+		p.add("", "", "pods", nil, "get", "list", "watch")
+		if mutating && c.deletePods {
+			if c.podAction == podActionEvict {
+				p.add("", "", "pods/eviction", nil, "create")
+			} else {
+				p.add("", "", "pods", nil, "delete")
+			}
+			if c.deletePVCs {
+				p.add("", "apps", "statefulsets", nil, "get")
+			}
+		}
+		if mutating && c.forceDeleteStuckPods {
+			p.add("", "", "pods", nil, "delete")
+		}
+		if mutating && c.capacityHints > 0 {
+			p.add("", "", "pods", nil, "patch")
+		}
+	}
+	if c.deleteVolumeAttachments {
+		p.add("", "storage.k8s.io", "volumeattachments", nil, "list", "watch")
+	}
+	if c.workloadProtection {
+		p.add("", "apps", "statefulsets", nil, "list", "watch")
+		p.add("", "apps", "deployments", nil, "list", "watch")
+	}
+	if c.provisionerDeployment != "" {
+		namespace, name, _ := strings.Cut(c.provisionerDeployment, "/")
+		p.add(namespace, "apps", "deployments", []string{name}, "get")
+	}
+
+	if !c.virtualCluster {
+		if c.leaseFreshness > 0 || c.minConfidence > 0 {
+			p.add(nodeLeaseNamespace, "coordination.k8s.io", "leases", nil, "get")
+		}
+		if c.minConfidence > 0 {
+			p.add("", "cluster.x-k8s.io", "machines", nil, "get")
+		}
+	}
+	if c.usage != nil && c.usage.prometheusURL == "" {
+		p.add("", "", "nodes/proxy", nil, "get")
+	}
+
+	if c.health != nil {
+		p.add("", "local-pvc-cleaner.io", "clustercleanerstatuses", nil, "create", "patch")
+		p.add("", "local-pvc-cleaner.io", "clustercleanerstatuses/status", nil, "patch")
+	}
+	if c.plan != nil {
+		p.add("", "local-pvc-cleaner.io", "cleanupplans", nil, "get", "create", "patch")
+		p.add("", "local-pvc-cleaner.io", "cleanupplans/status", nil, "patch")
+	}
+	if c.namespaceClients != nil {
+		p.add("", "", "serviceaccounts", nil, "impersonate")
+	}
+	return p
+}
+
+// rbacManifests renders the permissions as a ClusterRole for the cluster wide
+// rules and a Role for the rules of each namespace.
+func rbacManifests(p permissions) ([]byte, error) {
+	clusterRole := &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{Name: rbacName},
+	}
+	roles := map[string]*rbacv1.Role{}
+	for _, rule := range p {
+		policy := rbacv1.PolicyRule{
+			APIGroups:     []string{rule.group},
+			Resources:     []string{rule.resource},
+			ResourceNames: rule.resourceNames,
+			Verbs:         rule.verbs,
+		}
+		if rule.namespace == "" {
+			clusterRole.Rules = append(clusterRole.Rules, policy)
+			continue
+		}
+		role, ok := roles[rule.namespace]
+		if !ok {
+			role = &rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: rbacName, Namespace: rule.namespace},
+			}
+			roles[rule.namespace] = role
+		}
+		role.Rules = append(role.Rules, policy)
+	}
+
+	objects := []any{clusterRole}
+	for _, namespace := range sets.List(sets.KeySet(roles)) {
+		objects = append(objects, roles[namespace])
+	}
+
+	var docs []string
+	for _, obj := range objects {
+		doc, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, string(doc))
+	}
+	return []byte(strings.Join(docs, "---\n")), nil
+}
+
+// selfReviewGroups are granted to every authenticated user and never exceed
+// the configuration.
+var selfReviewGroups = stringList{"authorization.k8s.io", "authentication.k8s.io"}
+
+// excessPermissions compares the rules bound to the cleaner in the controller
+// namespace, or the default one, with the ones its configuration needs and
+// returns the verbs granted beyond them. Rules of other namespaces are not
+// reviewed, and namespace and resource name restrictions of the needed rules
+// are not compared.
+func (c *cleaner) excessPermissions(ctx context.Context, required permissions) ([]string, error) {
+	namespace := c.namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	review, err := c.clientset.AuthorizationV1().SelfSubjectRulesReviews().Create(ctx, &authorizationv1.SelfSubjectRulesReview{
+		Spec: authorizationv1.SelfSubjectRulesReviewSpec{Namespace: namespace},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if review.Status.Incomplete {
+		tracef("rules review of namespace %s is incomplete: %s\n", namespace, review.Status.EvaluationError)
+	}
+
+	excess := sets.New[string]()
+	for _, rule := range review.Status.ResourceRules {
+		for _, group := range rule.APIGroups {
+			if selfReviewGroups.contains(group) {
+				continue
+			}
+			for _, resource := range rule.Resources {
+				needed := required.verbs(group, resource)
+				var verbs []string
+				for _, verb := range rule.Verbs {
+					if verb == "*" || group == "*" || resource == "*" || !needed.Has(verb) {
+						verbs = append(verbs, verb)
+					}
+				}
+				if len(verbs) == 0 {
+					continue
+				}
+				name := resource
+				if group != "" {
+					name = resource + "." + group
+				}
+				excess.Insert(fmt.Sprintf("%s on %s", strings.Join(verbs, ","), name))
+			}
+		}
+	}
+	return sets.List(excess), nil
+}
+
+// warnExcessPermissions logs and records an event when the cleaner is bound to
+// more permissions than its configuration needs.
+func (c *cleaner) warnExcessPermissions(ctx context.Context, required permissions) {
+	excess, err := c.excessPermissions(ctx, required)
+	if err != nil {
+		fmt.Printf("failed to review the bound permissions: %v\n", err)
+		return
+	}
+	if len(excess) == 0 {
+		return
+	}
+	fmt.Printf("bound permissions exceed the configuration, print the needed ones with --print-rbac: %s\n", strings.Join(excess, "; "))
+	c.controllerEvent(ctx, corev1.EventTypeWarning, "ExcessPermissions", "bound permissions exceed the configuration: %s", strings.Join(excess, "; "))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// annotations the binding and provisioning controllers set on a claim that must
+// not be carried over to its replacement.
+var bindingAnnotations = []string{
+	selectedNodeAnnotation,
+	provisionerAnnotation,
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+}
+
+// statefulSetClaim reports whether a claim belongs to a stateful set, either
+// through its owner or through the pods consuming it.
+func statefulSetClaim(pvc *corev1.PersistentVolumeClaim, pods []*corev1.Pod) bool {
+	for _, owner := range pvc.OwnerReferences {
+		if owner.Kind == "StatefulSet" {
+			return true
+		}
+	}
+
+	for _, pod := range pods {
+		for _, owner := range pod.OwnerReferences {
+			if owner.Kind == "StatefulSet" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// recreateClaim waits for a deleted claim to be gone and creates an unbound
+// copy of it so the pending pod can bind on a new node.
+func (c *cleaner) recreateClaim(ctx context.Context, pvc *corev1.PersistentVolumeClaim) {
+	client, err := c.clientFor(pvc.Namespace)
+	if err != nil {
+		logf(ctx, "failed to get client for namespace(%s): %v\n", pvc.Namespace, err)
+		return
+	}
+
+	err = claimWatch(client, pvc.Namespace, pvc.Name).waitGone(ctx, c.recreateTimeout, pvc.UID)
+	if err != nil {
+		logf(ctx, "failed waiting for pvc(%s) to be removed: %v\n", pvc.Name, err)
+		return
+	}
+
+	replacement := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pvc.Name,
+			Namespace:       pvc.Namespace,
+			Labels:          pvc.Labels,
+			Annotations:     map[string]string{},
+			OwnerReferences: pvc.OwnerReferences,
+		},
+		Spec: *pvc.Spec.DeepCopy(),
+	}
+	replacement.Spec.VolumeName = ""
+	for key, value := range pvc.Annotations {
+		replacement.Annotations[key] = value
+	}
+	for _, key := range bindingAnnotations {
+		delete(replacement.Annotations, key)
+	}
+
+	_, err = client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Create(ctx, replacement, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		logf(ctx, "pvc(%s) was already recreated\n", pvc.Name)
+		return
+	}
+	if namespaceTerminatingError(err) {
+		tracef("not recreating pvc(%s/%s) in terminating namespace\n", pvc.Namespace, pvc.Name)
+		return
+	}
+	if err != nil {
+		logf(ctx, "failed to recreate pvc(%s): %v\n", pvc.Name, err)
+		return
+	}
+
+	logf(ctx, "recreated pvc(%s)\n", pvc.Name)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// waitForReclaim waits for the provisioner to reclaim the volume of a deleted
+// claim and reports whether it did within the delegate timeout of the reclaim
+// mode. Volumes that are not reclaimed on release are never waited for.
+func (c *cleaner) waitForReclaim(ctx context.Context, pvName string) bool {
+	if !c.deletePVCs {
+		return false
+	}
+	timeout := c.delegateTimeout(ctx)
+	if timeout <= 0 {
+		return false
+	}
+
+	pv, err := c.factory.Core().V1().PersistentVolumes().Lister().Get(pvName)
+	if err != nil {
+		logf(ctx, "failed to get pv(%s): %v\n", pvName, err)
+		return false
+	}
+	if pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimDelete {
+		return false
+	}
+
+	err = volumeWatch(c.clientset, pvName).waitGone(ctx, timeout, "")
+	if err != nil {
+		logf(ctx, "pv(%s) was not reclaimed by the provisioner within %s: %v\n", pvName, timeout, err)
+		return false
+	}
+
+	logf(ctx, "pv(%s) was reclaimed by the provisioner\n", pvName)
+	return true
+}
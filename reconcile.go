@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// reconcileTimers schedules reconciles for when a claim may be cleaned up,
+// keeping at most one pending timer per claim that fires in time.
+type reconcileTimers struct {
+	mu  sync.Mutex
+	due map[string]time.Time
+}
+
+func newReconcileTimers() *reconcileTimers {
+	return &reconcileTimers{due: map[string]time.Time{}}
+}
+
+// schedule calls trigger after the delay, unless a timer of the key already
+// fires by then.
+func (t *reconcileTimers) schedule(key string, delay time.Duration, trigger func()) {
+	now := time.Now()
+	at := now.Add(delay)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if due, ok := t.due[key]; ok && due.After(now) && !due.After(at) {
+		return
+	}
+	t.due[key] = at
+	time.AfterFunc(delay, func() {
+		t.mu.Lock()
+		if t.due[key].Equal(at) {
+			delete(t.due, key)
+		}
+		t.mu.Unlock()
+		trigger()
+	})
+}
+
+// scheduleReconcile triggers a reconcile after the delay, once per key, so
+// claims waiting out a deadline are picked up without a reconcile interval.
+// Simulations schedule nothing.
+func (c *cleaner) scheduleReconcile(ctx context.Context, key string, delay time.Duration) {
+	if simulated(ctx) {
+		return
+	}
+	c.timers.schedule(key, delay, c.triggerReconcile)
+}
+
+// triggerReconcile requests a full reconcile without waiting for it. Requests
+// made while one is already pending are coalesced.
+func (c *cleaner) triggerReconcile() {
+	select {
+	case c.reconcileCh <- struct{}{}:
+	default:
+	}
+}
+
+// runReconciles runs a full reconcile every interval, when triggered, and on
+// SIGUSR1 until the context is done. An interval of zero disables periodic
+// reconciles.
+func (c *cleaner) runReconciles(ctx context.Context, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	usr1Ch := make(chan os.Signal, 1)
+	signal.Notify(usr1Ch, syscall.SIGUSR1)
+	defer signal.Stop(usr1Ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-c.reconcileCh:
+			fmt.Printf("reconcile triggered\n")
+		case <-usr1Ch:
+			fmt.Printf("reconcile triggered by SIGUSR1\n")
+		}
+
+		c.reconcile(ctx)
+	}
+}
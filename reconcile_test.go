@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReconcileTimersSchedule(t *testing.T) {
+	tests := []struct {
+		name string
+		// delays are scheduled in order for the same key.
+		delays []time.Duration
+		// fired is how many of them call the trigger within the wait.
+		fired int
+	}{
+		{name: "single", delays: []time.Duration{10 * time.Millisecond}, fired: 1},
+		{name: "later timer deduplicated", delays: []time.Duration{10 * time.Millisecond, time.Hour}, fired: 1},
+		{name: "same deadline deduplicated", delays: []time.Duration{20 * time.Millisecond, 20 * time.Millisecond}, fired: 1},
+		{name: "earlier timer added", delays: []time.Duration{time.Hour, 10 * time.Millisecond}, fired: 1},
+		{name: "nothing due in time", delays: []time.Duration{time.Hour}, fired: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timers := newReconcileTimers()
+			var fired atomic.Int32
+			for _, delay := range test.delays {
+				timers.schedule("quarantine/uid", delay, func() { fired.Add(1) })
+			}
+
+			time.Sleep(100 * time.Millisecond)
+			if got := int(fired.Load()); got != test.fired {
+				t.Errorf("got %d reconciles triggered, want %d", got, test.fired)
+			}
+		})
+	}
+}
+
+func TestReconcileTimersKeys(t *testing.T) {
+	timers := newReconcileTimers()
+	var fired atomic.Int32
+	for _, key := range []string{"quarantine/a", "quarantine/b", "backoff/pvc/default/data"} {
+		timers.schedule(key, 10*time.Millisecond, func() { fired.Add(1) })
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := fired.Load(); got != 3 {
+		t.Errorf("got %d reconciles triggered, want one per key", got)
+	}
+	timers.mu.Lock()
+	defer timers.mu.Unlock()
+	if len(timers.due) != 0 {
+		t.Errorf("got %d timers left after they fired", len(timers.due))
+	}
+}
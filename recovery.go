@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// recoveryPollInterval is how often recovering stateful set pods are checked.
+const recoveryPollInterval = 5 * time.Second
+
+// statefulSetOwner returns the name of the stateful set owning a pod, or an
+// empty string.
+func statefulSetOwner(pod *corev1.Pod) string {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "StatefulSet" {
+			return owner.Name
+		}
+	}
+	return ""
+}
+
+func podReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// trackRecovery follows the stateful set pods of a cleaned up claim in the
+// background until they are replaced and ready with a bound replacement claim,
+// then reports the time since the cleanup started through an event on the
+// stateful set and the recovery histogram. Pods not recovering within the
+// recovery timeout are reported too.
+func (c *cleaner) trackRecovery(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pods []*corev1.Pod, start time.Time) {
+	if c.recoveryTimeout <= 0 {
+		return
+	}
+
+	statefulSet := ""
+	old := map[types.UID]bool{}
+	var names []string
+	for _, pod := range pods {
+		if owner := statefulSetOwner(pod); owner != "" {
+			statefulSet = owner
+			old[pod.UID] = true
+			names = append(names, pod.Name)
+		}
+	}
+	if statefulSet == "" {
+		return
+	}
+
+	client, err := c.clientFor(pvc.Namespace)
+	if err != nil {
+		logf(ctx, "failed to get client for namespace(%s): %v\n", pvc.Namespace, err)
+		return
+	}
+
+	go func() {
+		err := wait.PollImmediateWithContext(ctx, recoveryPollInterval, c.recoveryTimeout, func(ctx context.Context) (bool, error) {
+			claim, err := client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(ctx, pvc.Name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			if err != nil || claim.UID == pvc.UID || claim.Status.Phase != corev1.ClaimBound {
+				return false, nil
+			}
+
+			for _, name := range names {
+				pod, err := client.CoreV1().Pods(pvc.Namespace).Get(ctx, name, metav1.GetOptions{})
+				if err != nil || old[pod.UID] || !podReady(pod) {
+					return false, nil
+				}
+			}
+			return true, nil
+		})
+
+		var obj runtime.Object = pvc
+		sts, getErr := client.AppsV1().StatefulSets(pvc.Namespace).Get(ctx, statefulSet, metav1.GetOptions{})
+		if getErr == nil {
+			obj = sts
+		}
+
+		duration := time.Since(start).Round(time.Second)
+		if err != nil {
+			logf(ctx, "pods %v of statefulset(%s/%s) did not recover within %s of cleaning up pvc(%s)\n", names, pvc.Namespace, statefulSet, c.recoveryTimeout, pvc.Name)
+			statefulSetRecoveryTimeouts.Inc()
+			c.eventf(ctx, obj, corev1.EventTypeWarning, "RecoveryTimedOut", "pods %v did not recover within %s of cleaning up pvc %s", names, c.recoveryTimeout, pvc.Name)
+			return
+		}
+
+		logf(ctx, "pods %v of statefulset(%s/%s) recovered in %s after cleaning up pvc(%s)\n", names, pvc.Namespace, statefulSet, duration, pvc.Name)
+		statefulSetRecoveryDuration.Observe(time.Since(start).Seconds())
+		c.eventf(ctx, obj, corev1.EventTypeNormal, "RecoveryComplete", "recovery complete in %s: pods %v are ready with a bound replacement of pvc %s", duration, names, pvc.Name)
+	}()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// redactor hashes the names of namespaces and claims in metrics,
+// notifications and the api for clusters where operators must not see
+// tenant identifying names. Events, annotations and the tombstone configmaps
+// inside the cluster keep the full names. A nil redactor redacts nothing.
+type redactor struct {
+	key []byte
+}
+
+// name returns a stable hash of a namespace or claim name.
+func (r *redactor) name(value string) string {
+	if r == nil || value == "" {
+		return value
+	}
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return "redacted-" + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// names redacts each of the given names.
+func (r *redactor) names(values []string) []string {
+	if r == nil {
+		return values
+	}
+	redacted := make([]string, 0, len(values))
+	for _, value := range values {
+		redacted = append(redacted, r.name(value))
+	}
+	return redacted
+}
+
+// text redacts the mentions of the given namespace and claim names in text,
+// like the message of an api error. Only whole names are replaced, so a short
+// name does not rewrite the words it is part of.
+func (r *redactor) text(text string, values ...string) string {
+	if r == nil {
+		return text
+	}
+	for _, value := range values {
+		text = replaceName(text, value, r.name(value))
+	}
+	return text
+}
+
+// replaceName replaces the occurrences of a name in text that are not part of
+// a longer name.
+func replaceName(text, name, replacement string) string {
+	if name == "" {
+		return text
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(text, name)
+		if i < 0 {
+			b.WriteString(text)
+			return b.String()
+		}
+		end := i + len(name)
+		if (i > 0 && nameChar(text[i-1])) || (end < len(text) && nameChar(text[end])) {
+			b.WriteString(text[:end])
+		} else {
+			b.WriteString(text[:i])
+			b.WriteString(replacement)
+		}
+		text = text[end:]
+	}
+}
+
+// nameChar reports whether c can be part of a namespace or claim name.
+func nameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.'
+}
+
+// failureKey redacts the namespace and name of a pvc/namespace/name failure key, and
+// their mentions in text like the last error of the failure.
+func (r *redactor) failureKey(key, text string) (string, string) {
+	if r == nil || !strings.HasPrefix(key, "pvc/") {
+		return key, text
+	}
+	namespace, name, ok := strings.Cut(strings.TrimPrefix(key, "pvc/"), "/")
+	if !ok {
+		return key, text
+	}
+	return "pvc/" + r.name(namespace) + "/" + r.name(name), r.text(text, name, namespace)
+}
+
+// redactNotification hashes the claim names and namespaces of a notification.
+func (r *redactor) redactNotification(n *notification) {
+	if r == nil {
+		return
+	}
+	n.Namespaces = r.names(n.Namespaces)
+	sort.Strings(n.Namespaces)
+	for i := range n.PVCs {
+		n.PVCs[i].Namespace = r.name(n.PVCs[i].Namespace)
+		n.PVCs[i].Name = r.name(n.PVCs[i].Name)
+	}
+	for i := range n.Abandoned {
+		n.Abandoned[i].Key, n.Abandoned[i].LastError = r.failureKey(n.Abandoned[i].Key, n.Abandoned[i].LastError)
+	}
+}
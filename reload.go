@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// keys of the controller configmap that extend the built in provisioners and
+// the topology keys at runtime.
+const (
+	provisionersKey = "provisioners"
+	topologyKeysKey = "topology-keys"
+)
+
+// reloadedConfig is the configuration read from the controller configmap.
+type reloadedConfig struct {
+	provisioners stringList
+	topologyKeys stringList
+}
+
+// localProvisioner reports whether claims of a provisioner are pinned to the
+// node they were provisioned on.
+func (c *cleaner) localProvisioner(name string) bool {
+	if localProvisioners.contains(name) {
+		return true
+	}
+	config := c.reloaded.Load()
+	return config != nil && config.provisioners.contains(name)
+}
+
+// volumeTopologyKeys returns the configured topology keys followed by the ones
+// of the controller configmap.
+func (c *cleaner) volumeTopologyKeys() stringList {
+	config := c.reloaded.Load()
+	if config == nil || len(config.topologyKeys) == 0 {
+		return c.topologyKeys
+	}
+	return append(append(stringList{}, c.topologyKeys...), config.topologyKeys...)
+}
+
+// reloadConfig applies the configuration of the controller configmap. The
+// indexes do not depend on it, so it takes effect for the next lookup.
+func (c *cleaner) reloadConfig(cm *corev1.ConfigMap) {
+	config := &reloadedConfig{}
+	config.provisioners.Set(cm.Data[provisionersKey])
+	config.topologyKeys.Set(cm.Data[topologyKeysKey])
+
+	previous := c.reloaded.Load()
+	if previous == nil {
+		previous = &reloadedConfig{}
+	}
+	if reflect.DeepEqual(previous, config) {
+		return
+	}
+
+	c.reloaded.Store(config)
+	fmt.Printf("reloaded provisioners(%s) topology keys(%s) from configmap(%s/%s)\n", config.provisioners.String(), config.topologyKeys.String(), cm.Namespace, cm.Name)
+}
+
+// watchConfig reloads the configuration whenever the controller configmap
+// changes. It does nothing when no controller namespace is configured.
+func (c *cleaner) watchConfig(stopCh <-chan struct{}) {
+	if c.namespace == "" {
+		return
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, 0, informers.WithNamespace(c.namespace), informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+		options.FieldSelector = "metadata.name=" + controllerConfigMapName
+	}))
+	factory.Core().V1().ConfigMaps().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			c.reloadConfig(obj.(*corev1.ConfigMap))
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			c.reloadConfig(newObj.(*corev1.ConfigMap))
+		},
+		DeleteFunc: func(obj any) {
+			c.reloadConfig(&corev1.ConfigMap{})
+		},
+	})
+
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// notificationLogRetention is how long claims missing from the notified
+// batches are remembered, so a claim blocked across a long reconcile interval
+// still escalates instead of notifying as new.
+const notificationLogRetention = 24 * time.Hour
+
+type notifiedClaim struct {
+	// first is when the claim was first part of a batch, notified the last
+	// time a notification about it was sent, zero before the first one, and
+	// seen the last time it was part of a batch.
+	first    time.Time
+	notified time.Time
+	seen     time.Time
+}
+
+// notificationLog suppresses repeated notifications about claims that keep
+// showing up without being cleaned up, like claims whose deletion keeps
+// failing, so each is notified about once per interval.
+type notificationLog struct {
+	interval time.Duration
+
+	mu     sync.Mutex
+	claims map[types.UID]*notifiedClaim
+}
+
+func newNotificationLog(interval time.Duration) *notificationLog {
+	return &notificationLog{interval: interval, claims: map[types.UID]*notifiedClaim{}}
+}
+
+// filter returns the batch without the claims notified about within the
+// interval. Claims blocked for longer than the interval since they were last
+// notified about, or first seen when no notification about them was sent yet,
+// carry since when they are blocked. Cleaned up claims are forgotten.
+func (l *notificationLog) filter(sum summary) summary {
+	if l == nil {
+		return sum
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for uid, claim := range l.claims {
+		if now.Sub(claim.seen) > max(l.interval, notificationLogRetention) {
+			delete(l.claims, uid)
+		}
+	}
+
+	filtered := summary{}
+	for _, o := range sum.outcomes {
+		uid := o.cand.pvc.UID
+		claim := l.claims[uid]
+		switch {
+		case o.decision == decisionDeleted || o.decision == decisionMigrated:
+			delete(l.claims, uid)
+		case claim == nil:
+			l.claims[uid] = &notifiedClaim{first: now, seen: now}
+		case claim.notified.IsZero() && now.Sub(claim.first) < l.interval:
+			claim.seen = now
+		case !claim.notified.IsZero() && now.Sub(claim.notified) < l.interval:
+			claim.seen = now
+			notificationsSuppressed.Inc()
+			continue
+		default:
+			claim.seen = now
+			o.blockedSince = claim.first
+		}
+		filtered.addCleanup(o.cand, o.decision, o.duration, o.err, o.usedBytes)
+		filtered.outcomes[len(filtered.outcomes)-1].blockedSince = o.blockedSince
+	}
+	return filtered
+}
+
+// sent records that a notification about the claims of a batch was sent, so
+// they are left out for the interval.
+func (l *notificationLog) sent(sum summary) {
+	if l == nil {
+		return
+	}
+
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, o := range sum.outcomes {
+		if claim := l.claims[o.cand.pvc.UID]; claim != nil {
+			claim.notified = now
+		}
+	}
+}
+
+// notifiable reports whether a batch is worth a notification, which it is
+// when it cleaned up or failed to clean up an orphan or escalates a blocked
+// one.
+func (s summary) notifiable() bool {
+	if s.cleaned > 0 || s.failed > 0 {
+		return true
+	}
+	for _, o := range s.outcomes {
+		if !o.blockedSince.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedMessage is the escalation message of a claim notified about again
+// after being blocked since the given time.
+func blockedMessage(since time.Time) string {
+	blocked := time.Since(since)
+	if blocked < time.Hour {
+		return fmt.Sprintf("still blocked after %d minutes", int(blocked.Minutes()))
+	}
+	return fmt.Sprintf("still blocked after %d hours", int(blocked.Hours()))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// replicatedProvisioners are provisioners and CSI drivers of replicated storage
+// whose volumes survive the loss of a node and must never be cleaned up.
+var replicatedProvisioners = stringList{
+	"driver.longhorn.io",
+	"jiva.csi.openebs.io",
+	"cstor.csi.openebs.io",
+	"pxd.portworx.com",
+}
+
+// replicatedProvisionerSuffixes match drivers deployed under a custom prefix,
+// like the ceph drivers named after the rook operator namespace.
+var replicatedProvisionerSuffixes = []string{
+	".rbd.csi.ceph.com",
+	".cephfs.csi.ceph.com",
+}
+
+func replicatedProvisioner(name string) bool {
+	if replicatedProvisioners.contains(name) {
+		return true
+	}
+	for _, suffix := range replicatedProvisionerSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// replicatedVolume reports whether a volume is backed by replicated storage.
+func replicatedVolume(pv *corev1.PersistentVolume) bool {
+	if pv.Spec.CSI != nil && replicatedProvisioner(pv.Spec.CSI.Driver) {
+		return true
+	}
+	return replicatedProvisioner(pv.Annotations[provisionedByAnnotation])
+}
+
+// replicatedClaim reports whether a claim or its volume is backed by
+// replicated storage, regardless of any node annotations it carries.
+func (c *cleaner) replicatedClaim(pvc *corev1.PersistentVolumeClaim) bool {
+	if replicatedProvisioner(pvc.Annotations[provisionerAnnotation]) || replicatedProvisioner(pvc.Annotations["volume.beta.kubernetes.io/storage-provisioner"]) {
+		return true
+	}
+	if pvc.Spec.VolumeName == "" {
+		return false
+	}
+
+	pv, err := c.factory.Core().V1().PersistentVolumes().Lister().Get(pvc.Spec.VolumeName)
+	if err != nil {
+		return false
+	}
+	return replicatedVolume(pv)
+}
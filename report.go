@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type reportOutcome struct {
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Volume    string   `json:"volume,omitempty"`
+	Nodes     []string `json:"nodes"`
+	Decision  decision `json:"decision"`
+	Cause     string   `json:"cause,omitempty"`
+	// Duration is how long the cleanup took, for orphans the cleaner tried
+	// to clean up.
+	Duration string `json:"duration,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// UsedBytes is how much data the volume held when it was cleaned up,
+	// when known.
+	UsedBytes *int64 `json:"usedBytes,omitempty"`
+}
+
+// runReport is the structured report of a full reconcile written to the
+// report file, for pipelines archiving what a run did.
+type runReport struct {
+	CorrelationID  string          `json:"correlationId"`
+	Mode           string          `json:"mode"`
+	Started        time.Time       `json:"started"`
+	Finished       time.Time       `json:"finished"`
+	Duration       string          `json:"duration"`
+	Found          int             `json:"found"`
+	Cleaned        int             `json:"cleaned"`
+	Skipped        int             `json:"skipped"`
+	Failed         int             `json:"failed"`
+	Unclassifiable int             `json:"unclassifiable"`
+	Outcomes       []reportOutcome `json:"outcomes"`
+	Error          string          `json:"error,omitempty"`
+	// DiscardedBytes adds up the used bytes of the deleted volumes whose usage
+	// is known.
+	DiscardedBytes int64 `json:"discardedBytes"`
+}
+
+// writeReport writes the report of a reconcile to the report file, replacing
+// the one of the previous reconcile. The file is written next to its path and
+// renamed so readers never see a partial report.
+func (c *cleaner) writeReport(ctx context.Context, start time.Time, sum summary, reconcileErr error) {
+	if c.reportFile == "" {
+		return
+	}
+
+	finished := time.Now()
+	report := runReport{
+		CorrelationID:  correlationID(ctx),
+		Mode:           c.mode,
+		Started:        start.UTC(),
+		Finished:       finished.UTC(),
+		Duration:       finished.Sub(start).String(),
+		Found:          sum.found,
+		Cleaned:        sum.cleaned,
+		Skipped:        sum.skipped,
+		Failed:         sum.failed,
+		Unclassifiable: sum.unclassifiable,
+		Outcomes:       []reportOutcome{},
+	}
+	if reconcileErr != nil {
+		report.Error = reconcileErr.Error()
+	}
+	for _, o := range sum.outcomes {
+		entry := reportOutcome{
+			Namespace: c.redactor.name(o.cand.pvc.Namespace),
+			Name:      c.redactor.name(o.cand.pvc.Name),
+			Volume:    o.cand.pvc.Spec.VolumeName,
+			Nodes:     o.cand.nodes,
+			Decision:  o.decision,
+			Cause:     c.nodeCauses(o.cand.nodes),
+		}
+		if o.duration > 0 {
+			entry.Duration = o.duration.String()
+		}
+		if o.err != nil {
+			entry.Error = c.redactor.text(o.err.Error(), o.cand.pvc.Name, o.cand.pvc.Namespace)
+		}
+		if o.usedBytes >= 0 {
+			used := o.usedBytes
+			entry.UsedBytes = &used
+			if o.decision == decisionDeleted {
+				report.DiscardedBytes += used
+			}
+		}
+		report.Outcomes = append(report.Outcomes, entry)
+	}
+
+	value, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logf(ctx, "failed to marshal report: %v\n", err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.reportFile), ".report-*.json")
+	if err != nil {
+		logf(ctx, "failed to write report: %v\n", err)
+		return
+	}
+	_, err = tmp.Write(append(value, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.reportFile)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		logf(ctx, "failed to write report to %s: %v\n", c.reportFile, err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultTerminationTaints are put on spot and preemptible nodes whose
+// instance is about to be reclaimed, by aws-node-termination-handler and by
+// GKE graceful node shutdown.
+var defaultTerminationTaints = stringList{
+	"aws-node-termination-handler/spot-itn",
+	"aws-node-termination-handler/asg-lifecycle-termination",
+	"aws-node-termination-handler/scheduled-maintenance",
+	"cloud.google.com/impending-node-termination",
+}
+
+// signals of a node that is about to be removed.
+const (
+	removalScaleDown   = "scale-down"
+	removalTermination = "termination"
+)
+
+// hasTaint reports whether a node carries one of the given taint keys.
+func hasTaint(node *corev1.Node, keys stringList) bool {
+	for _, taint := range node.Spec.Taints {
+		if keys.contains(taint.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+// removalSignal returns why a node is about to be removed, among the signals
+// the cleaner is configured to act on, or an empty string.
+func (c *cleaner) removalSignal(node *corev1.Node) string {
+	if c.prestageTerminations && hasTaint(node, c.terminationTaints) {
+		return removalTermination
+	}
+	if c.quarantineOnScaleDown && hasTaint(node, stringList{toBeDeletedTaint}) {
+		return removalScaleDown
+	}
+	return ""
+}
+
+// nodesLeaving reports whether each of the given nodes is gone or about to be
+// removed.
+func (c *cleaner) nodesLeaving(nodeNames []string) bool {
+	if len(nodeNames) == 0 {
+		return false
+	}
+	for _, nodeName := range nodeNames {
+		node, err := c.factory.Core().V1().Nodes().Lister().Get(nodeName)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil || c.removalSignal(node) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// handleNodeUpdate starts the quarantine of the claims on a node as soon as
+// it is about to be removed. Claims of nodes removed by cluster-autoscaler
+// are quarantined from now on so the grace period has passed by the time the
+// node is deleted, claims of reclaimed spot nodes, whose data is lost for
+// sure, skip the grace period. Claims without a grace period are left to the
+// node deletion. When the removal is cancelled the next reconcile releases
+// them.
+func (c *cleaner) handleNodeUpdate(ctx context.Context, oldNode, node *corev1.Node) {
+	if c.reportOnly() {
+		return
+	}
+	previous, signal := c.removalSignal(oldNode), c.removalSignal(node)
+	if previous != "" && signal == "" {
+		logf(ctx, "removal of node(%s) was cancelled\n", node.Name)
+		c.triggerReconcile()
+		return
+	}
+	if previous != "" || signal == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx = withCorrelationID(ctx, newCorrelationID())
+	logf(ctx, "node(%s) is about to be removed: %s\n", node.Name, signal)
+
+	candidates, err := c.candidatesByNode(ctx, node.Name)
+	if err != nil {
+		logf(ctx, "error getting candidates of node(%s): %v\n", node.Name, err)
+		return
+	}
+
+	for _, cand := range candidates {
+		if _, ok := cand.pvc.Annotations[orphanedAtAnnotation]; ok {
+			continue
+		}
+		if !c.nodesLeaving(cand.nodes) || c.replicatedClaim(cand.pvc) || c.policyDecision(ctx, cand) != "" {
+			continue
+		}
+		gracePeriod := c.claimGracePeriod(ctx, cand.pvc)
+		if gracePeriod <= 0 {
+			continue
+		}
+
+		message := "are being removed by the cluster autoscaler, deleting after " + gracePeriod.String()
+		orphanedAt := time.Now()
+		if signal == removalTermination {
+			message = "are being reclaimed, deleting right after their removal"
+			orphanedAt = orphanedAt.Add(-gracePeriod)
+		}
+		value := orphanedAt.UTC().Format(time.RFC3339)
+
+		err := c.patchClaimAnnotations(ctx, cand.pvc, map[string]*string{orphanedAtAnnotation: &value})
+		if err != nil {
+			logf(ctx, "failed to quarantine pvc(%s): %v\n", cand.pvc.Name, err)
+			continue
+		}
+		logf(ctx, "quarantined pvc(%s) ahead of the removal of node(%s)\n", cand.pvc.Name, node.Name)
+		c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "Quarantined", "node(s) %v %s", cand.nodes, message)
+	}
+}
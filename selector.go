@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// approvedAnnotation marks a claim an operator approved the cleanup of.
+	approvedAnnotation = "local-pvc-cleaner.io/approved"
+	// nodePoolLabel holds the node pool of a claim in the labels the auto
+	// clean selector matches.
+	nodePoolLabel = "local-pvc-cleaner.io/node-pool"
+)
+
+// claimLabels returns the labels the auto clean selector matches a claim
+// against: the labels and annotations of the claim and its volume, which
+// provisioners copy zones and pools onto, the topology of the node affinity
+// of the volume and the node pool of its nodes.
+func (c *cleaner) claimLabels(cand candidate) labels.Set {
+	set := labels.Set{}
+	if pool := c.candidatePool(cand); pool != "" {
+		set[nodePoolLabel] = pool
+	}
+
+	if cand.pvc.Spec.VolumeName != "" {
+		pv, err := c.factory.Core().V1().PersistentVolumes().Lister().Get(cand.pvc.Spec.VolumeName)
+		if err == nil {
+			if pv.Spec.NodeAffinity != nil && pv.Spec.NodeAffinity.Required != nil {
+				for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+					for _, expr := range term.MatchExpressions {
+						if expr.Operator == corev1.NodeSelectorOpIn && len(expr.Values) == 1 {
+							set[expr.Key] = expr.Values[0]
+						}
+					}
+				}
+			}
+			for key, value := range pv.Annotations {
+				set[key] = value
+			}
+			for key, value := range pv.Labels {
+				set[key] = value
+			}
+		}
+	}
+
+	for key, value := range cand.pvc.Annotations {
+		set[key] = value
+	}
+	for key, value := range cand.pvc.Labels {
+		set[key] = value
+	}
+	return set
+}
+
+// candidatePool returns the node pool of the first node of a claim with a
+// known pool.
+func (c *cleaner) candidatePool(cand candidate) string {
+	if c.nodePools == nil {
+		return ""
+	}
+	for _, nodeName := range cand.nodes {
+		if pool := c.nodePools.pool(nodeName); pool != "" {
+			return pool
+		}
+	}
+	return ""
+}
+
+// selectorDecision quarantines orphans outside the auto clean selector until
+// an operator approves their cleanup.
+func (c *cleaner) selectorDecision(ctx context.Context, cand candidate) decision {
+	if c.autoCleanSelector == nil || c.autoCleanSelector.Matches(c.claimLabels(cand)) {
+		return ""
+	}
+	if _, ok := cand.pvc.Annotations[approvedAnnotation]; ok {
+		return ""
+	}
+
+	if _, ok := cand.pvc.Annotations[orphanedAtAnnotation]; !ok {
+		orphanedAt := time.Now().UTC().Format(time.RFC3339)
+		err := c.patchClaimAnnotations(ctx, cand.pvc, map[string]*string{orphanedAtAnnotation: &orphanedAt})
+		if err != nil {
+			logf(ctx, "failed to quarantine pvc(%s): %v\n", cand.pvc.Name, err)
+			return decisionFailed
+		}
+		logf(ctx, "quarantined pvc(%s) outside the auto clean selector\n", cand.pvc.Name)
+		c.eventf(ctx, cand.pvc, corev1.EventTypeWarning, "Quarantined", "node(s) %v are gone, the pvc is outside the auto clean selector and is deleted once approved", cand.nodes)
+	}
+	return decisionSkippedAwaitingApproval
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// serve runs the api on addr. With a certificate and key it is served over tls,
+// and with a client ca, certificates signed by it authorize requests like the
+// api token does. Read only endpoints need the same authorization unless they
+// are public.
+func (c *cleaner) serve(addr, certFile, keyFile, clientCAFile string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	))
+	mux.HandleFunc("/v1/status", c.readable(c.handleStatus))
+	mux.HandleFunc("/v1/stats", c.readable(c.handleStats))
+	mux.HandleFunc("/v1/orphans", c.readable(c.handleOrphans))
+	mux.HandleFunc("/v1/reconcile", c.authorized(c.handleReconcile))
+	mux.HandleFunc("/v1/blacklist", c.readable(c.handleBlacklist))
+	mux.HandleFunc("/v1/blacklist/", c.authorized(c.handleBlacklistEntry))
+	mux.HandleFunc("/v1/pvcs/", c.authorized(c.handleClaimAction))
+	mux.HandleFunc("/v1/pause", c.authorized(c.handlePause(true)))
+	mux.HandleFunc("/v1/resume", c.authorized(c.handlePause(false)))
+	mux.HandleFunc("/v1/expected-nodes", c.handleExpectedNodes)
+	mux.HandleFunc("/v1/cleanups/", c.authorized(c.handleCleanupAction))
+	mux.HandleFunc("/v1/nodes", c.readable(c.handleNodes))
+	mux.HandleFunc("/v1/candidates", c.authorized(c.handleCandidates))
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	if clientCAFile != "" {
+		server.TLSConfig = &tls.Config{
+			ClientCAs:  clientCAPool(clientCAFile),
+			ClientAuth: tls.VerifyClientCertIfGiven,
+		}
+	}
+
+	go func() {
+		var err error
+		if certFile != "" {
+			err = server.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil {
+			fmt.Printf("failed to serve on %s: %v\n", addr, err)
+		}
+	}()
+}
+
+// clientCAPool reads the ca whose client certificates authorize api requests.
+func clientCAPool(clientCAFile string) *x509.CertPool {
+	ca, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		panic(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		panic(fmt.Sprintf("no certificates in %s", clientCAFile))
+	}
+	return pool
+}
+
+// authorized only lets requests carrying the configured bearer token or a
+// verified client certificate through. Without either the wrapped handler is
+// disabled.
+func (c *cleaner) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			next(w, r)
+			return
+		}
+
+		if c.apiToken == "" {
+			http.Error(w, "api token is not configured", http.StatusForbidden)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(c.apiToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// readable authorizes requests to a read only endpoint like authorized does,
+// unless read only endpoints are public.
+func (c *cleaner) readable(next http.HandlerFunc) http.HandlerFunc {
+	if c.publicReadEndpoints {
+		return next
+	}
+	return c.authorized(next)
+}
+
+func (c *cleaner) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	c.triggerReconcile()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (c *cleaner) handleBlacklist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	entries := c.failures.list()
+	for i := range entries {
+		entries[i].Key, entries[i].LastError = c.redactor.failureKey(entries[i].Key, entries[i].LastError)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"entries": entries})
+}
+
+// handleBlacklistEntry clears the failures of the object whose key, like
+// pvc/namespace/name or pv/name, follows /v1/blacklist/.
+func (c *cleaner) handleBlacklistEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/v1/blacklist/")
+	if c.redactor != nil {
+		for _, entry := range c.failures.list() {
+			if redacted, _ := c.redactor.failureKey(entry.Key, ""); redacted == key {
+				key = entry.Key
+				break
+			}
+		}
+	}
+	if !c.failures.clear(key) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	fmt.Printf("cleared failures of %s from api\n", key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleClaimAction approves or skips the cleanup of a claim on
+// /v1/pvcs/{namespace}/{name}/approve and /v1/pvcs/{namespace}/{name}/skip.
+// Skipped claims are blacklisted until cleared. In external mode the external
+// deleter confirms deletions on /v1/pvcs/{namespace}/{name}/confirm.
+func (c *cleaner) handleClaimAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/pvcs/"), "/")
+	if len(parts) != 3 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	namespace, name, action := parts[0], parts[1], parts[2]
+	if action == "approve" || action == "skip" {
+		var ok bool
+		namespace, name, ok = c.requestedClaim(namespace, name)
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	switch action {
+	case "approve":
+		err := c.approveClaim(r.Context(), namespace, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	case "skip":
+		c.failures.skip("pvc/" + namespace + "/" + name)
+		fmt.Printf("skipped cleanup of pvc(%s/%s)\n", namespace, name)
+	case "confirm":
+		var confirmation externalConfirmation
+		err := json.NewDecoder(r.Body).Decode(&confirmation)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = c.confirmExternal(r.Context(), namespace, name, confirmation)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// requestedClaim returns the namespace and name of the claim an api request
+// names by the hashed names shown when names are redacted, and whether there
+// is such a claim.
+func (c *cleaner) requestedClaim(namespace, name string) (string, string, bool) {
+	if c.redactor == nil {
+		return namespace, name, true
+	}
+	claims, err := c.factory.Core().V1().PersistentVolumeClaims().Lister().List(labels.Everything())
+	if err != nil {
+		return "", "", false
+	}
+	for _, pvc := range claims {
+		if c.redactor.name(pvc.Namespace) == namespace && c.redactor.name(pvc.Name) == name {
+			return pvc.Namespace, pvc.Name, true
+		}
+	}
+	return "", "", false
+}
+
+type orphan struct {
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Volume    string   `json:"volume,omitempty"`
+	Nodes     []string `json:"nodes"`
+}
+
+// listOrphans returns the claims whose nodes are all gone, with their names
+// redacted.
+func (c *cleaner) listOrphans(ctx context.Context) ([]orphan, error) {
+	candidates, err := c.allCandidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := []orphan{}
+	for _, cand := range candidates {
+		if len(cand.nodes) == 0 || c.replicatedClaim(cand.pvc) {
+			continue
+		}
+		remaining, err := c.remainingNode(ctx, cand.nodes)
+		if err != nil || remaining != "" {
+			continue
+		}
+		orphans = append(orphans, orphan{
+			Namespace: c.redactor.name(cand.pvc.Namespace),
+			Name:      c.redactor.name(cand.pvc.Name),
+			Volume:    cand.pvc.Spec.VolumeName,
+			Nodes:     cand.nodes,
+		})
+	}
+	return orphans, nil
+}
+
+// handleOrphans lists the claims whose nodes are all gone without acting on
+// them.
+func (c *cleaner) handleOrphans(w http.ResponseWriter, r *http.Request) {
+	orphans, err := c.listOrphans(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"orphans": orphans})
+}
+
+func (c *cleaner) handlePause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		namespace := r.URL.Query().Get("namespace")
+		nodePool := r.URL.Query().Get("nodepool")
+		if namespace != "" && nodePool != "" {
+			http.Error(w, "namespace and nodepool are exclusive", http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		if namespace != "" || nodePool != "" {
+			err = c.setScopePaused(r.Context(), namespace, nodePool, paused)
+		} else {
+			err = c.setPaused(r.Context(), paused)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decisionHistory returns the latest decisions with their names redacted, filtered
+// by namespace and name when they are set.
+func (c *cleaner) decisionHistory(namespace, name string) []decisionRecord {
+	records := []decisionRecord{}
+	for _, record := range c.decisions.list() {
+		record.Namespace = c.redactor.name(record.Namespace)
+		record.Name = c.redactor.name(record.Name)
+		if namespace != "" && record.Namespace != namespace {
+			continue
+		}
+		if name != "" && record.Name != name {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// handleStatus returns the latest decisions, optionally filtered by the
+// namespace and name query parameters.
+func (c *cleaner) handleStatus(w http.ResponseWriter, r *http.Request) {
+	records := c.decisionHistory(r.URL.Query().Get("namespace"), r.URL.Query().Get("name"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"mode":         c.mode,
+		"paused":       c.isPaused(r.Context()),
+		"pausedScopes": c.pausedScopes(r.Context()),
+		"decisions":    records,
+	})
+}
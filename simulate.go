@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// notificationSimulatedCleanup is the event of notifications about simulated
+// node deletions.
+const notificationSimulatedCleanup = "simulated-node-cleanup"
+
+var errNodeNotFound = errors.New("node does not exist")
+
+type simulatedNodeKey struct{}
+
+// withSimulatedNode returns a context in which the given node counts as
+// deleted and claims, pods and events are left alone.
+func withSimulatedNode(ctx context.Context, nodeName string) context.Context {
+	return context.WithValue(ctx, simulatedNodeKey{}, nodeName)
+}
+
+// simulatedNode returns the node whose deletion the context simulates, or an
+// empty string outside of a simulation.
+func simulatedNode(ctx context.Context) string {
+	nodeName, _ := ctx.Value(simulatedNodeKey{}).(string)
+	return nodeName
+}
+
+func simulated(ctx context.Context) bool {
+	return simulatedNode(ctx) != ""
+}
+
+// simulation is the outcome of simulating the deletion of a node.
+type simulation struct {
+	Node          string         `json:"node"`
+	Simulated     bool           `json:"simulated"`
+	CorrelationID string         `json:"correlationId"`
+	Found         int            `json:"found"`
+	Cleaned       int            `json:"cleaned"`
+	Skipped       int            `json:"skipped"`
+	PVCs          []simulatedPVC `json:"pvcs"`
+}
+
+type simulatedPVC struct {
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Nodes     []string `json:"nodes"`
+	Decision  decision `json:"decision"`
+	// Quarantined is when the grace period of a claim that would be cleaned
+	// up ends, when it has one.
+	Quarantined *time.Time `json:"quarantinedUntil,omitempty"`
+	// Actions are the objects a cleanup would delete or move.
+	Actions []string `json:"actions,omitempty"`
+}
+
+// simulateNodeDeletion runs the decision pipeline over the claims of an
+// existing node as if it was deleted and notifies about the outcome, marked as
+// simulated, without changing any claim, volume or pod, recording events or
+// asking the veto webhook, and without touching the failures, metrics, circuit
+// breaker or scheduled reconciles of the cleaner. Claims that would be
+// quarantined are evaluated as if their grace period passed.
+func (c *cleaner) simulateNodeDeletion(ctx context.Context, nodeName string) (simulation, error) {
+	sim := simulation{Node: nodeName, Simulated: true, PVCs: []simulatedPVC{}}
+	exists, err := c.nodeExists(ctx, nodeName)
+	if err != nil {
+		return sim, err
+	}
+	if !exists {
+		return sim, errNodeNotFound
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sim.CorrelationID = newCorrelationID()
+	ctx = withSimulatedNode(withCorrelationID(ctx, sim.CorrelationID), nodeName)
+	logf(ctx, "simulating deletion of node(%s)\n", nodeName)
+
+	mapping, err := c.nodeMapping(ctx)
+	if err != nil {
+		return sim, fmt.Errorf("getting node mapping: %w", err)
+	}
+	candidates, err := c.candidatesByNode(ctx, nodeName)
+	if err != nil {
+		return sim, err
+	}
+	c.sortCandidates(candidates)
+
+	scopes := c.pausedScopes(ctx)
+	var sum summary
+	for _, cand := range candidates {
+		pvc := simulatedPVC{Namespace: c.redactor.name(cand.pvc.Namespace), Name: c.redactor.name(cand.pvc.Name), Nodes: cand.nodes}
+		d := c.simulatedDecision(ctx, cand, scopes, &pvc)
+		if d == "" {
+			d, pvc.Actions = c.simulatedCleanup(cand, mapping)
+		}
+		pvc.Decision = d
+		logf(ctx, "pvc(%s/%s) nodes(%s) decision(%s)\n", cand.pvc.Namespace, cand.pvc.Name, strings.Join(cand.nodes, ","), d)
+
+		n := len(sum.outcomes)
+		sum.add(cand, d)
+		if len(sum.outcomes) > n {
+			sim.PVCs = append(sim.PVCs, pvc)
+		}
+	}
+	sim.Found, sim.Cleaned, sim.Skipped = sum.found, sum.cleaned, sum.skipped
+
+	c.notify(ctx, notificationSimulatedCleanup, []string{nodeName}, sum)
+	logf(ctx, "simulated deletion of node(%s): %d found, %d cleaned, %d skipped\n", nodeName, sum.found, sum.cleaned, sum.skipped)
+	return sim, nil
+}
+
+// simulatedDecision follows the decisions of evaluateAll for a claim of the
+// simulated node, passing the grace period.
+func (c *cleaner) simulatedDecision(ctx context.Context, cand candidate, scopes pauseScopes, pvc *simulatedPVC) decision {
+	d := c.skipDecision(ctx, cand)
+	if d == "" && c.cancelled.any(cand.nodes) {
+		d = decisionSkippedCancelled
+	}
+	if d == "" && c.quarantineDecision(ctx, cand) == decisionSkippedGracePeriod {
+		deadline := c.quarantineDeadline(ctx, cand.pvc).UTC()
+		pvc.Quarantined = &deadline
+	}
+	if d == "" {
+		d = c.selectorDecision(ctx, cand)
+	}
+	if d == "" {
+		d = c.consumerDecision(ctx, cand)
+	}
+	if d == "" {
+		d = c.backoffDecision(ctx, cand.pvc)
+	}
+	if d == "" && scopes.covers(cand, c.nodePools) {
+		d = decisionSkippedPaused
+	}
+	if d == "" && c.restoringNamespace(cand.pvc.Namespace) != "" {
+		d = decisionSkippedRestoring
+	}
+	if d == "" {
+		d = c.cloneSourceDecision(ctx, cand)
+	}
+	if d == "" {
+		d = c.flappingDecision(ctx, cand)
+	}
+	if d == "" && c.breaker.isOpen() {
+		d = decisionSkippedCircuitOpen
+	}
+	if d == "" && c.isPaused(ctx) {
+		d = decisionSkippedPaused
+	}
+	return d
+}
+
+// simulatedCleanup returns the decision and actions of cleaning up an orphan
+// of the simulated node.
+func (c *cleaner) simulatedCleanup(cand candidate, mapping map[string]string) (decision, []string) {
+	claim := "persistentvolumeclaim/" + c.redactor.name(cand.pvc.Namespace) + "/" + c.redactor.name(cand.pvc.Name)
+	if c.reportOnly() {
+		return decisionSkippedReportMode, nil
+	}
+	if c.externalDeletion() {
+		return decisionSkippedAwaitingExternal, []string{"label " + claim}
+	}
+
+	for _, nodeName := range cand.nodes {
+		if newNode := mapping[nodeName]; newNode != "" {
+			actions := []string{"move " + claim + " to node " + newNode}
+			if cand.pvc.Spec.VolumeName != "" {
+				actions = append(actions, "move persistentvolume/"+cand.pvc.Spec.VolumeName+" to node "+newNode)
+			}
+			return decisionMigrated, actions
+		}
+	}
+
+	pods, _ := c.consumerPods(cand.pvc)
+	ephemeral := ephemeralClaim(cand.pvc)
+	var actions []string
+	if c.deletePods || ephemeral {
+		for _, pod := range pods {
+			actions = append(actions, "delete pod/"+c.redactor.name(pod.Namespace)+"/"+c.redactor.name(pod.Name))
+		}
+	}
+	if c.deletePVCs {
+		actions = append(actions, "delete "+claim)
+	}
+	if c.deletePVs && cand.pvc.Spec.VolumeName != "" {
+		actions = append(actions, "delete persistentvolume/"+cand.pvc.Spec.VolumeName)
+	}
+	if c.deletePVCs && c.recreateClaims && !ephemeral && statefulSetClaim(cand.pvc, pods) {
+		actions = append(actions, "recreate "+claim)
+	}
+	return decisionDeleted, actions
+}
+
+// handleSimulation simulates the deletion of a node on
+// /v1/cleanups/{node}/simulate.
+func (c *cleaner) handleSimulation(w http.ResponseWriter, r *http.Request, nodeName string) {
+	sim, err := c.simulateNodeDeletion(r.Context(), nodeName)
+	if errors.Is(err, errNodeNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sim)
+}
+
+// runSimulate simulates the deletion of a node on a running cleaner and prints
+// what its cleanup would do.
+func runSimulate(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	server := flags.String("server", "http://localhost:8080", "address of the cleaner api")
+	apiTokenFile := flags.String("api-token-file", "", "file containing the bearer token of the cleaner api")
+	node := flags.String("node", "", "existing node to simulate the deletion of")
+	output := flags.String("output", "text", "output format, one of text or json")
+	flags.Parse(args)
+
+	if *node == "" {
+		fmt.Fprintf(os.Stderr, "no node to simulate the deletion of\n")
+		os.Exit(1)
+	}
+
+	body := callAPI(*server, *apiTokenFile, http.MethodPost, "/v1/cleanups/"+*node+"/simulate", nil, http.StatusOK)
+	if *output == "json" {
+		os.Stdout.Write(body)
+		return
+	}
+
+	var sim simulation
+	err := json.Unmarshal(body, &sim)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to decode simulation: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("simulated deletion of node(%s), correlation id %s\n", sim.Node, sim.CorrelationID)
+	for _, pvc := range sim.PVCs {
+		fmt.Printf("pvc %s/%s: %s\n", pvc.Namespace, pvc.Name, pvc.Decision)
+		if pvc.Quarantined != nil {
+			fmt.Printf("  quarantined until %s\n", pvc.Quarantined.Format(time.RFC3339))
+		}
+		for _, action := range pvc.Actions {
+			fmt.Printf("  %s\n", action)
+		}
+	}
+	fmt.Printf("%d found, %d cleaned, %d skipped\n", sim.Found, sim.Cleaned, sim.Skipped)
+}
+
+// simulatedEvent logs an event a simulation would have recorded on an object.
+func simulatedEvent(ctx context.Context, eventType, reason, message string) {
+	logf(ctx, "%s event %s: %s\n", strings.ToLower(eventType), reason, message)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	smtpTLSStartTLS = "starttls"
+	smtpTLSImplicit = "tls"
+	smtpTLSNone     = "none"
+)
+
+// smtpTimeout bounds connecting to and talking with the mail server.
+const smtpTimeout = 30 * time.Second
+
+// defaultEmailTemplate renders a plain text summary of a notification.
+const defaultEmailTemplate = `{{ .Event }} of nodes {{ .Nodes }}: found {{ .Found }} orphans, cleaned {{ .Cleaned }}, skipped {{ .Skipped }}, failed {{ .Failed }}
+{{ if .CorrelationID }}correlation id: {{ .CorrelationID }}
+{{ end }}
+{{ range .PVCs }}{{ .Namespace }}/{{ .Name }} {{ .Size }} on {{ .Nodes }}: {{ .Decision }}
+{{ end }}{{ if .Abandoned }}
+gave up after {{ (index .Abandoned 0).Attempts }} attempts:
+{{ range .Abandoned }}{{ .Key }}: {{ .LastError }}
+{{ end }}{{ end }}`
+
+// smtpNotifier mails rendered notifications to a list of addresses.
+type smtpNotifier struct {
+	address  string
+	from     string
+	to       []string
+	username string
+	password string
+	tlsMode  string
+	template *template.Template
+}
+
+func (s *smtpNotifier) send(ctx context.Context, n notification) error {
+	var body bytes.Buffer
+	err := s.template.Execute(&body, n)
+	if err != nil {
+		return fmt.Errorf("rendering notification: %w", err)
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", s.from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&message, "Subject: local-pvc-cleaner %s: cleaned %d, failed %d\r\n", n.Event, n.Cleaned, n.Failed)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.Write(bytes.ReplaceAll(body.Bytes(), []byte("\n"), []byte("\r\n")))
+
+	client, err := s.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.username != "" {
+		host, _, _ := net.SplitHostPort(s.address)
+		err = client.Auth(smtp.PlainAuth("", s.username, s.password, host))
+		if err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	err = client.Mail(s.from)
+	if err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		err = client.Rcpt(to)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(message.Bytes())
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// dial connects to the mail server using the configured tls mode.
+func (s *smtpNotifier) dial(ctx context.Context) (*smtp.Client, error) {
+	host, _, err := net.SplitHostPort(s.address)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{ServerName: host}
+
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	if s.tlsMode == smtpTLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if s.tlsMode == smtpTLSStartTLS {
+		err = client.StartTLS(tlsConfig)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("starting tls: %w", err)
+		}
+	}
+	return client, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// annotations telling the owners of an orphaned claim what is about to happen
+// to it, shown by kubectl describe.
+const (
+	stateAnnotation         = "local-pvc-cleaner.io/state"
+	stateDeadlineAnnotation = "local-pvc-cleaner.io/state-deadline"
+	stateMessageAnnotation  = "local-pvc-cleaner.io/state-message"
+)
+
+// pendingStates maps the decisions that leave an orphan waiting for its
+// cleanup to the state exposed on the claim.
+var pendingStates = map[decision]string{
+	decisionSkippedGracePeriod:      "quarantined",
+	decisionSkippedPaused:           "paused",
+	decisionSkippedRestoring:        "paused",
+	decisionSkippedCloneSource:      "deferred",
+	decisionSkippedCircuitOpen:      "paused",
+	decisionSkippedAPIServerLoad:    "paused",
+	decisionSkippedVetoDelayed:      "delayed",
+	decisionSkippedVetoed:           "vetoed",
+	decisionSkippedBackoff:          "retrying",
+	decisionSkippedBlacklisted:      "blacklisted",
+	decisionSkippedLeaseRenewed:     "aborted",
+	decisionSkippedFlapping:         "held",
+	decisionSkippedCancelled:        "cancelled",
+	decisionSkippedAwaitingApproval: "awaiting-approval",
+	decisionSkippedActiveConsumers:  "awaiting-approval",
+	decisionSkippedAwaitingExternal: "awaiting-external-deletion",
+}
+
+// stateMessage describes a pending state to the owners of a claim.
+func stateMessage(cand candidate, d decision, deadline *time.Time) string {
+	gone := fmt.Sprintf("node(s) %s are gone", strings.Join(cand.nodes, ","))
+	if cand.lost {
+		gone = fmt.Sprintf("volume(%s) is gone", cand.pvc.Spec.VolumeName)
+	}
+	switch d {
+	case decisionSkippedGracePeriod:
+		return fmt.Sprintf("%s, the pvc is deleted at %s", gone, deadline.UTC().Format(time.RFC3339))
+	case decisionSkippedPaused, decisionSkippedCircuitOpen, decisionSkippedAPIServerLoad:
+		return fmt.Sprintf("%s, the pvc is deleted once cleanup resumes", gone)
+	case decisionSkippedRestoring:
+		return fmt.Sprintf("%s, the pvc is deleted once the velero restore into its namespace finished", gone)
+	case decisionSkippedCloneSource:
+		return fmt.Sprintf("%s, the pvc is deleted once the pvcs cloned from it are bound", gone)
+	case decisionSkippedVetoDelayed:
+		return fmt.Sprintf("%s, the veto webhook delayed deleting the pvc", gone)
+	case decisionSkippedVetoed:
+		return fmt.Sprintf("%s, the veto webhook denied deleting the pvc", gone)
+	case decisionSkippedBackoff:
+		return fmt.Sprintf("%s, deleting the pvc failed and is retried", gone)
+	case decisionSkippedBlacklisted:
+		return fmt.Sprintf("%s, deleting the pvc failed too often and is no longer retried", gone)
+	case decisionSkippedLeaseRenewed:
+		return fmt.Sprintf("%s but still renew their lease, the pvc is kept", gone)
+	case decisionSkippedAwaitingApproval:
+		return fmt.Sprintf("%s, the pvc is outside the auto clean selector and is deleted once approved", gone)
+	case decisionSkippedActiveConsumers:
+		return fmt.Sprintf("%s but pods still use the pvc, it is deleted once approved", gone)
+	case decisionSkippedAwaitingExternal:
+		return fmt.Sprintf("%s, the pvc is deleted by the external deleter", gone)
+	case decisionSkippedCancelled:
+		return fmt.Sprintf("%s, but an operator cancelled the cleanup until they are back", gone)
+	case decisionSkippedFlapping:
+		return fmt.Sprintf("%s, but a pvc of this name was cleaned up recently and the pvc is kept", gone)
+	}
+	return string(d)
+}
+
+// exposeState annotates an orphaned claim with its pending state, or removes
+// the annotations again once its node is back. Claims are only patched when
+// the state changed.
+func (c *cleaner) exposeState(ctx context.Context, cand candidate, d decision, deadline *time.Time) {
+	if c.reportOnly() {
+		return
+	}
+
+	annotations := map[string]*string{}
+	state, pending := pendingStates[d]
+	switch {
+	case pending:
+		message := stateMessage(cand, d, deadline)
+		if cand.pvc.Annotations[stateAnnotation] == state && cand.pvc.Annotations[stateMessageAnnotation] == message {
+			return
+		}
+		annotations[stateAnnotation] = &state
+		annotations[stateMessageAnnotation] = &message
+		annotations[stateDeadlineAnnotation] = nil
+		if deadline != nil {
+			value := deadline.UTC().Format(time.RFC3339)
+			annotations[stateDeadlineAnnotation] = &value
+		}
+	case d == decisionSkippedNodeExists:
+		if _, ok := cand.pvc.Annotations[stateAnnotation]; !ok {
+			return
+		}
+		annotations[stateAnnotation] = nil
+		annotations[stateMessageAnnotation] = nil
+		annotations[stateDeadlineAnnotation] = nil
+	default:
+		return
+	}
+
+	err := c.patchClaimAnnotations(ctx, cand.pvc, annotations)
+	if err != nil {
+		logf(ctx, "failed to expose state of pvc(%s): %v\n", cand.pvc.Name, err)
+	}
+}
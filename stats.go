@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// statsWindow is how far back the rolling statistics look.
+const statsWindow = 7 * 24 * time.Hour
+
+type statEvent struct {
+	time    time.Time
+	failed  bool
+	latency time.Duration
+}
+
+// cleanupStats keeps rolling aggregates of cleanups computed in process.
+type cleanupStats struct {
+	mu           sync.Mutex
+	events       []statEvent
+	nodesDeleted map[string]time.Time
+	nodeCauses   map[string]string
+}
+
+func newCleanupStats() *cleanupStats {
+	return &cleanupStats{nodesDeleted: map[string]time.Time{}, nodeCauses: map[string]string{}}
+}
+
+func (s *cleanupStats) prune(now time.Time) {
+	cutoff := now.Add(-statsWindow)
+	i := 0
+	for i < len(s.events) && s.events[i].time.Before(cutoff) {
+		i++
+	}
+	s.events = s.events[i:]
+
+	for node, deleted := range s.nodesDeleted {
+		if deleted.Before(cutoff) {
+			delete(s.nodesDeleted, node)
+			delete(s.nodeCauses, node)
+		}
+	}
+}
+
+// nodeDeleted remembers when the deletion of a node was observed and why the
+// node was deleted.
+func (s *cleanupStats) nodeDeleted(nodeName, cause string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nodesDeleted[nodeName] = time.Now()
+	s.nodeCauses[nodeName] = cause
+}
+
+// cause returns why a node was deleted, or an empty string when its deletion
+// was not observed.
+func (s *cleanupStats) cause(nodeName string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.nodeCauses[nodeName]
+}
+
+// observe records the outcome of cleaning up a claim on the given nodes.
+func (s *cleanupStats) observe(nodes []string, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	event := statEvent{time: now, failed: failed}
+	for _, node := range nodes {
+		deleted, ok := s.nodesDeleted[node]
+		if ok && now.Sub(deleted) > event.latency {
+			event.latency = now.Sub(deleted)
+		}
+	}
+
+	s.events = append(s.events, event)
+	s.prune(now)
+}
+
+type statsDay struct {
+	Date     string `json:"date"`
+	Cleanups int    `json:"cleanups"`
+	Failures int    `json:"failures"`
+}
+
+type statsResponse struct {
+	Window                           string         `json:"window"`
+	Cleanups                         int            `json:"cleanups"`
+	Failures                         int            `json:"failures"`
+	CleanupsPerDay                   float64        `json:"cleanupsPerDay"`
+	FailureRate                      float64        `json:"failureRate"`
+	MeanNodeDeletionToCleanupSeconds float64        `json:"meanNodeDeletionToCleanupSeconds"`
+	NodeDeletionsByCause             map[string]int `json:"nodeDeletionsByCause"`
+	Days                             []statsDay     `json:"days"`
+}
+
+func (s *cleanupStats) summarize() statsResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+	response := statsResponse{Window: statsWindow.String(), NodeDeletionsByCause: map[string]int{}, Days: []statsDay{}}
+	for _, cause := range s.nodeCauses {
+		response.NodeDeletionsByCause[cause]++
+	}
+
+	var latencySum time.Duration
+	latencies := 0
+	for _, event := range s.events {
+		date := event.time.UTC().Format("2006-01-02")
+		if len(response.Days) == 0 || response.Days[len(response.Days)-1].Date != date {
+			response.Days = append(response.Days, statsDay{Date: date})
+		}
+		day := &response.Days[len(response.Days)-1]
+
+		if event.failed {
+			response.Failures++
+			day.Failures++
+			continue
+		}
+
+		response.Cleanups++
+		day.Cleanups++
+		if event.latency > 0 {
+			latencySum += event.latency
+			latencies++
+		}
+	}
+
+	response.CleanupsPerDay = float64(response.Cleanups) / (statsWindow.Hours() / 24)
+	if total := response.Cleanups + response.Failures; total > 0 {
+		response.FailureRate = float64(response.Failures) / float64(total)
+	}
+	if latencies > 0 {
+		response.MeanNodeDeletionToCleanupSeconds = (latencySum / time.Duration(latencies)).Seconds()
+	}
+	return response
+}
+
+func (c *cleaner) handleStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.stats.summarize())
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// storageClassMissing reports whether a claim names a storage class that does
+// not exist, which cluster rebuilds commonly leave behind.
+func (c *cleaner) storageClassMissing(pvc *corev1.PersistentVolumeClaim) bool {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false
+	}
+
+	_, err := c.factory.Storage().V1().StorageClasses().Lister().Get(*pvc.Spec.StorageClassName)
+	return apierrors.IsNotFound(err)
+}
+
+// orphanedStorageClassClaim reports whether a claim without a known local
+// provisioner still looks local: it selected a node and its storage class is
+// gone, so the provisioner annotation cannot be trusted to be meaningful.
+func (c *cleaner) orphanedStorageClassClaim(pvc *corev1.PersistentVolumeClaim) bool {
+	return pvc.Annotations[selectedNodeAnnotation] != "" && c.storageClassMissing(pvc)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// terminatingObject is an object the cleaner deleted that was not gone by the
+// end of its step timeout.
+type terminatingObject struct {
+	kind string
+	uid  types.UID
+	get  func(context.Context) (metav1.Object, error)
+}
+
+// terminatingObjects tracks the deleted objects that are still terminating,
+// usually because a finalizer of a third party holds them.
+type terminatingObjects struct {
+	mu      sync.Mutex
+	objects map[string]terminatingObject
+}
+
+func newTerminatingObjects() *terminatingObjects {
+	return &terminatingObjects{objects: map[string]terminatingObject{}}
+}
+
+// track remembers a deleted object, described like pvc(name), to check on it
+// later.
+func (t *terminatingObjects) track(description string, uid types.UID, get func(context.Context) (metav1.Object, error)) {
+	kind, _, _ := strings.Cut(description, "(")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.objects[description+"/"+string(uid)] = terminatingObject{kind: kind, uid: uid, get: get}
+}
+
+// reportStuck looks up the deleted objects that were still terminating and
+// reports the ones terminating for longer than the stuck threshold through the
+// stuck objects gauge and warning events, forgetting the ones that are gone.
+func (c *cleaner) reportStuck(ctx context.Context) {
+	if c.stuckThreshold <= 0 {
+		return
+	}
+
+	c.terminating.mu.Lock()
+	objects := map[string]terminatingObject{}
+	for key, object := range c.terminating.objects {
+		objects[key] = object
+	}
+	c.terminating.mu.Unlock()
+
+	stuck := map[string]int{"pod": 0, "pvc": 0, "pv": 0}
+	var descriptions []string
+	for key, object := range objects {
+		obj, err := object.get(ctx)
+		if apierrors.IsNotFound(err) || (err == nil && object.uid != "" && obj.GetUID() != object.uid) || (err == nil && obj.GetDeletionTimestamp() == nil) {
+			c.terminating.mu.Lock()
+			delete(c.terminating.objects, key)
+			c.terminating.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			logf(ctx, "failed to get terminating %s: %v\n", key, err)
+			continue
+		}
+
+		terminating := time.Since(obj.GetDeletionTimestamp().Time)
+		if terminating < c.stuckThreshold {
+			continue
+		}
+
+		description := object.kind + "(" + obj.GetName() + ")"
+		if obj.GetNamespace() != "" {
+			description = object.kind + "(" + obj.GetNamespace() + "/" + obj.GetName() + ")"
+		}
+		finalizers := strings.Join(obj.GetFinalizers(), ",")
+		logf(ctx, "warning: %s is terminating for %s, held by finalizers(%s)\n", description, terminating.Round(time.Second), finalizers)
+		if runtimeObj, ok := obj.(runtime.Object); ok {
+			c.eventf(ctx, runtimeObj, corev1.EventTypeWarning, "StuckTerminating", "deleted by the cleaner %s ago but still held by finalizers %s", terminating.Round(time.Second), finalizers)
+		}
+		stuck[object.kind]++
+		descriptions = append(descriptions, description)
+	}
+
+	for kind, count := range stuck {
+		stuckTerminatingObjects.WithLabelValues(kind).Set(float64(count))
+	}
+	if len(descriptions) > 0 {
+		c.controllerEvent(ctx, corev1.EventTypeWarning, "StuckTerminating", "objects deleted by the cleaner are stuck terminating: %s", strings.Join(descriptions, ", "))
+	}
+}
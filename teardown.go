@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// objectWatch looks up and watches a single object by name, so waiting for
+// its deletion costs one watch instead of a get every second, no matter how
+// many cleanups wait concurrently.
+type objectWatch struct {
+	name    string
+	example runtime.Object
+	get     func(ctx context.Context) (metav1.Object, error)
+	list    func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error)
+	watch   func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error)
+}
+
+func podWatch(client kubernetes.Interface, namespace, name string) objectWatch {
+	pods := client.CoreV1().Pods(namespace)
+	return objectWatch{
+		name:    name,
+		example: &corev1.Pod{},
+		get: func(ctx context.Context) (metav1.Object, error) {
+			return pods.Get(ctx, name, metav1.GetOptions{})
+		},
+		list: func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return pods.List(ctx, options)
+		},
+		watch: pods.Watch,
+	}
+}
+
+func claimWatch(client kubernetes.Interface, namespace, name string) objectWatch {
+	claims := client.CoreV1().PersistentVolumeClaims(namespace)
+	return objectWatch{
+		name:    name,
+		example: &corev1.PersistentVolumeClaim{},
+		get: func(ctx context.Context) (metav1.Object, error) {
+			return claims.Get(ctx, name, metav1.GetOptions{})
+		},
+		list: func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return claims.List(ctx, options)
+		},
+		watch: claims.Watch,
+	}
+}
+
+func volumeWatch(client kubernetes.Interface, name string) objectWatch {
+	volumes := client.CoreV1().PersistentVolumes()
+	return objectWatch{
+		name:    name,
+		example: &corev1.PersistentVolume{},
+		get: func(ctx context.Context) (metav1.Object, error) {
+			return volumes.Get(ctx, name, metav1.GetOptions{})
+		},
+		list: func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return volumes.List(ctx, options)
+		},
+		watch: volumes.Watch,
+	}
+}
+
+// waitGone watches the object for up to timeout until it is gone, including
+// its finalizers. An object with a different uid than the given one, unless
+// that is empty, is a replacement and counts as gone.
+func (w objectWatch) waitGone(ctx context.Context, timeout time.Duration, uid types.UID) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	selector := fields.OneTermEqualSelector("metadata.name", w.name).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return w.list(ctx, options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return w.watch(ctx, options)
+		},
+	}
+	replaced := func(obj metav1.Object) bool {
+		return uid != "" && obj.GetUID() != uid
+	}
+
+	_, err := watchtools.UntilWithSync(ctx, lw, w.example, func(store cache.Store) (bool, error) {
+		for _, item := range store.List() {
+			obj, ok := item.(metav1.Object)
+			if ok && obj.GetName() == w.name && !replaced(obj) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}, func(event watch.Event) (bool, error) {
+		obj, ok := event.Object.(metav1.Object)
+		if !ok || obj.GetName() != w.name {
+			return false, nil
+		}
+		if event.Type == watch.Deleted {
+			return uid == "" || obj.GetUID() == uid, nil
+		}
+		return replaced(obj), nil
+	})
+	return err
+}
+
+// waitDeleted waits up to the step timeout for an object to be gone. An object
+// with a different uid than the given one, unless that is empty, is a
+// replacement and counts as gone. Objects still there are tracked to report
+// them when they stay stuck terminating.
+func (c *cleaner) waitDeleted(ctx context.Context, description string, uid types.UID, w objectWatch) error {
+	err := w.waitGone(ctx, c.stepTimeout, uid)
+	if err != nil {
+		if c.terminating != nil {
+			c.terminating.track(description, uid, w.get)
+		}
+		return fmt.Errorf("waiting for %s to be deleted: %w", description, err)
+	}
+	return nil
+}
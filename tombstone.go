@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+const (
+	// tombstoneConfigMapName is the configmap in each namespace listing the
+	// claims the cleaner deleted there.
+	tombstoneConfigMapName = "local-pvc-cleaner-tombstones"
+	fieldManager           = "local-pvc-cleaner"
+)
+
+type tombstone struct {
+	Namespace     string    `json:"namespace"`
+	PVC           string    `json:"pvc"`
+	PV            string    `json:"pv,omitempty"`
+	Capacity      string    `json:"capacity,omitempty"`
+	Nodes         []string  `json:"nodes"`
+	Time          time.Time `json:"time"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+}
+
+// tombstoneTime returns the unix time a tombstone key starts with.
+func tombstoneTime(key string) int64 {
+	prefix, _, _ := strings.Cut(key, ".")
+	seconds, _ := strconv.ParseInt(prefix, 10, 64)
+	return seconds
+}
+
+// recordTombstone adds a deleted claim to the tombstone configmap of its
+// namespace, keeping only the latest tombstones.
+func (c *cleaner) recordTombstone(ctx context.Context, pvc *corev1.PersistentVolumeClaim, nodes []string) error {
+	client, err := c.clientFor(pvc.Namespace)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]string{}
+	cm, err := client.CoreV1().ConfigMaps(pvc.Namespace).Get(ctx, tombstoneConfigMapName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		for key, value := range cm.Data {
+			data[key] = value
+		}
+	}
+
+	now := time.Now()
+	entry := tombstone{
+		Namespace:     pvc.Namespace,
+		PVC:           pvc.Name,
+		PV:            pvc.Spec.VolumeName,
+		Nodes:         nodes,
+		Time:          now.UTC(),
+		CorrelationID: correlationID(ctx),
+	}
+	if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		entry.Capacity = capacity.String()
+	}
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data[fmt.Sprintf("%d.%s", now.Unix(), pvc.Name)] = string(value)
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return tombstoneTime(keys[i]) > tombstoneTime(keys[j])
+	})
+	for _, key := range keys[min(len(keys), c.tombstoneLimit):] {
+		delete(data, key)
+	}
+
+	apply := applycorev1.ConfigMap(tombstoneConfigMapName, pvc.Namespace).WithData(data)
+	_, err = client.CoreV1().ConfigMaps(pvc.Namespace).Apply(ctx, apply, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+	return err
+}
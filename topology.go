@@ -0,0 +1,126 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	provisionedByAnnotation = "pv.kubernetes.io/provisioned-by"
+	hostnameLabel           = "kubernetes.io/hostname"
+	// localStaticProvisionerValue is the provisioned-by annotation of volumes
+	// created by sig-storage-local-static-provisioner.
+	localStaticProvisionerValue = "local-volume-provisioner"
+)
+
+// localProvisioners are the provisioners whose claims are pinned to the node
+// they were provisioned on.
+var localProvisioners = stringList{
+	expectedProvisionerValue,
+	"topolvm.io",
+	"topolvm.cybozu.com",
+	"local.csi.openebs.io",
+	"hostpath.csi.k8s.io",
+}
+
+// csiDriverTopologyKeys are the node topology keys of known local CSI drivers,
+// looked at in addition to the configured topology keys.
+var csiDriverTopologyKeys = map[string]stringList{
+	"topolvm.io":           {"topology.topolvm.io/node"},
+	"topolvm.cybozu.com":   {"topology.topolvm.cybozu.com/node"},
+	"local.csi.openebs.io": {"openebs.io/nodename"},
+	"hostpath.csi.k8s.io":  {"topology.hostpath.csi/node"},
+}
+
+// volumeNodes returns the nodes a local volume is pinned to, or nil when the
+// volume is not a local volume this cleaner knows about.
+func (c *cleaner) volumeNodes(pv *corev1.PersistentVolume) []string {
+	if pv.Spec.CSI != nil {
+		return c.translateNodes(csiVolumeNodes(pv, c.volumeTopologyKeys()))
+	}
+	if pv.Annotations[provisionedByAnnotation] == expectedProvisionerValue {
+		return c.translateNodes(affinityNodes(pv, stringList{hostnameLabel}))
+	}
+	if pv.Spec.Local != nil && pv.Annotations[provisionedByAnnotation] == localStaticProvisionerValue {
+		return c.translateNodes(affinityNodes(pv, stringList{hostnameLabel}))
+	}
+	return nil
+}
+
+// indexedVolumeNodes returns every node a volume may be pinned to under any
+// configuration: the values of the required node affinity and of the CSI
+// volume attributes. Lookups filter them down with volumeNodes, which keeps the
+// index valid when the configuration is reloaded.
+func indexedVolumeNodes(pv *corev1.PersistentVolume) []string {
+	var nodes []string
+	if pv.Spec.NodeAffinity != nil && pv.Spec.NodeAffinity.Required != nil {
+		for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+			for _, expr := range term.MatchExpressions {
+				if expr.Operator != corev1.NodeSelectorOpIn {
+					continue
+				}
+				for _, value := range expr.Values {
+					nodes = appendUnique(nodes, value)
+				}
+			}
+		}
+	}
+	if pv.Spec.CSI != nil {
+		for _, value := range pv.Spec.CSI.VolumeAttributes {
+			nodes = appendUnique(nodes, value)
+		}
+	}
+	return nodes
+}
+
+// affinityNodes returns the values of the given keys in the required node
+// affinity of a volume.
+func affinityNodes(pv *corev1.PersistentVolume, keys stringList) []string {
+	var nodes []string
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return nodes
+	}
+
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			if expr.Operator != corev1.NodeSelectorOpIn || !keys.contains(expr.Key) {
+				continue
+			}
+			for _, value := range expr.Values {
+				nodes = appendUnique(nodes, value)
+			}
+		}
+	}
+	return nodes
+}
+
+// appendUnique appends a non empty value that is not in the list yet.
+func appendUnique(list []string, value string) []string {
+	if value == "" {
+		return list
+	}
+	for _, existing := range list {
+		if existing == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// csiVolumeNodes returns the nodes a CSI volume is pinned to by looking at the
+// configured and the driver's topology keys in the node affinity and the volume
+// attributes.
+func csiVolumeNodes(pv *corev1.PersistentVolume, topologyKeys stringList) []string {
+	if pv.Spec.CSI == nil {
+		return nil
+	}
+
+	if driverKeys := csiDriverTopologyKeys[pv.Spec.CSI.Driver]; len(driverKeys) > 0 {
+		topologyKeys = append(append(stringList{}, topologyKeys...), driverKeys...)
+	}
+
+	nodes := affinityNodes(pv, topologyKeys)
+	for _, key := range topologyKeys {
+		nodes = appendUnique(nodes, pv.Spec.CSI.VolumeAttributes[key])
+	}
+	return nodes
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	applycorev1 "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+// transactionConfigMapName is the configmap in the controller namespace holding
+// the intent logs of the node cleanups in progress, one key per set of nodes.
+const transactionConfigMapName = "local-pvc-cleaner-transactions"
+
+// the steps of cleaning up a claim, in the order they run.
+const (
+	stepMigrate     = "migrate"
+	stepPods        = "pods"
+	stepPVC         = "pvc"
+	stepPV          = "pv"
+	stepAttachments = "attachments"
+)
+
+type transactionStep struct {
+	// Claim is the namespace/name of the claim the step cleans up.
+	Claim  string    `json:"claim"`
+	UID    types.UID `json:"uid"`
+	Volume string    `json:"volume,omitempty"`
+	Step   string    `json:"step"`
+	Done   bool      `json:"done"`
+}
+
+// transaction is the intent log of a node cleanup: the steps it planned before
+// running any, marked done as they succeed, so a crash leaves a record of
+// where the cleanup stopped.
+type transaction struct {
+	Nodes         []string          `json:"nodes"`
+	CorrelationID string            `json:"correlationId,omitempty"`
+	Started       time.Time         `json:"started"`
+	Steps         []transactionStep `json:"steps"`
+}
+
+// done reports whether all planned steps succeeded.
+func (t *transaction) done() bool {
+	for _, step := range t.Steps {
+		if !step.Done {
+			return false
+		}
+	}
+	return true
+}
+
+type transactionLog struct {
+	mu           sync.Mutex
+	transactions map[string]*transaction
+}
+
+func newTransactionLog() *transactionLog {
+	return &transactionLog{transactions: map[string]*transaction{}}
+}
+
+type transactionKey struct{}
+
+// transactionID returns the configmap key of the transaction of a set of
+// nodes. Node names never contain underscores.
+func transactionID(nodes []string) string {
+	return strings.Join(nodes, "_")
+}
+
+// plannedSteps returns the steps cleaning up a claim will run.
+func (c *cleaner) plannedSteps(cand candidate, mapping map[string]string) []string {
+	for _, nodeName := range cand.nodes {
+		if mapping[nodeName] != "" {
+			return []string{stepMigrate}
+		}
+	}
+
+	var steps []string
+	if c.deletePods || ephemeralClaim(cand.pvc) {
+		steps = append(steps, stepPods)
+	}
+	if c.deletePVCs {
+		steps = append(steps, stepPVC)
+	}
+	if c.deletePVs && cand.pvc.Spec.VolumeName != "" {
+		steps = append(steps, stepPV)
+	}
+	if c.deleteVolumeAttachments && cand.pvc.Spec.VolumeName != "" {
+		steps = append(steps, stepAttachments)
+	}
+	return steps
+}
+
+// beginTransaction persists the planned steps of cleaning up a group of claims
+// on the same nodes and returns a context the steps are marked done through.
+func (c *cleaner) beginTransaction(ctx context.Context, nodes []string, group []candidate, mapping map[string]string) context.Context {
+	if c.transactions == nil {
+		return ctx
+	}
+
+	tx := &transaction{Nodes: nodes, CorrelationID: correlationID(ctx), Started: time.Now().UTC()}
+	for _, cand := range group {
+		for _, step := range c.plannedSteps(cand, mapping) {
+			tx.Steps = append(tx.Steps, transactionStep{
+				Claim:  cand.pvc.Namespace + "/" + cand.pvc.Name,
+				UID:    cand.pvc.UID,
+				Volume: cand.pvc.Spec.VolumeName,
+				Step:   step,
+			})
+		}
+	}
+
+	id := transactionID(nodes)
+	c.transactions.mu.Lock()
+	c.transactions.transactions[id] = tx
+	c.transactions.mu.Unlock()
+	c.saveTransactions(ctx)
+	return context.WithValue(ctx, transactionKey{}, id)
+}
+
+// completeStep marks a step of cleaning up a claim done.
+func (c *cleaner) completeStep(ctx context.Context, pvc *corev1.PersistentVolumeClaim, step string) {
+	id, ok := ctx.Value(transactionKey{}).(string)
+	if !ok {
+		return
+	}
+
+	c.transactions.mu.Lock()
+	tx := c.transactions.transactions[id]
+	found := false
+	for i := range tx.Steps {
+		if tx.Steps[i].UID == pvc.UID && tx.Steps[i].Step == step {
+			tx.Steps[i].Done = true
+			found = true
+		}
+	}
+	c.transactions.mu.Unlock()
+	if found {
+		c.saveTransactions(ctx)
+	}
+}
+
+// endTransaction forgets the transaction of a node cleanup once all its steps
+// succeeded. Failed cleanups keep their transaction until the next cleanup of
+// the same nodes replaces it.
+func (c *cleaner) endTransaction(ctx context.Context) {
+	id, ok := ctx.Value(transactionKey{}).(string)
+	if !ok {
+		return
+	}
+
+	c.transactions.mu.Lock()
+	done := c.transactions.transactions[id].done()
+	if done {
+		delete(c.transactions.transactions, id)
+	}
+	c.transactions.mu.Unlock()
+	if done {
+		c.saveTransactions(ctx)
+	}
+}
+
+// saveTransactions persists the transactions in progress, logging failures so
+// a missing record never blocks a cleanup.
+func (c *cleaner) saveTransactions(ctx context.Context) {
+	c.transactions.mu.Lock()
+	data := map[string]string{}
+	for id, tx := range c.transactions.transactions {
+		value, err := json.Marshal(tx)
+		if err != nil {
+			logf(ctx, "failed to marshal transaction of node(s) %v: %v\n", tx.Nodes, err)
+			continue
+		}
+		data[id] = string(value)
+	}
+	c.transactions.mu.Unlock()
+
+	apply := applycorev1.ConfigMap(transactionConfigMapName, c.namespace).WithData(data)
+	_, err := c.clientset.CoreV1().ConfigMaps(c.namespace).Apply(ctx, apply, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+	if err != nil {
+		logf(ctx, "failed to save transactions: %v\n", err)
+	}
+}
+
+// loadTransactions reads the transactions a previous run left unfinished.
+func (c *cleaner) loadTransactions(ctx context.Context) error {
+	cm, err := c.clientset.CoreV1().ConfigMaps(c.namespace).Get(ctx, transactionConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.transactions.mu.Lock()
+	defer c.transactions.mu.Unlock()
+	for id, value := range cm.Data {
+		tx := &transaction{}
+		err = json.Unmarshal([]byte(value), tx)
+		if err != nil {
+			return err
+		}
+		for _, step := range tx.Steps {
+			if !step.Done {
+				fmt.Printf("cleanup of node(s) %v started at %s stopped before the %s step of pvc(%s)\n", tx.Nodes, tx.Started.Format(time.RFC3339), step.Step, step.Claim)
+				break
+			}
+		}
+		c.transactions.transactions[id] = tx
+	}
+	return nil
+}
+
+// resumeTransactions finishes the steps of unfinished transactions whose claim
+// is already deleted, which no evaluation picks up again: deleting its volume
+// and the volume attachments. Claims that still exist are left to the regular
+// evaluation.
+func (c *cleaner) resumeTransactions(ctx context.Context) {
+	if c.transactions == nil || c.reportOnly() {
+		return
+	}
+
+	c.transactions.mu.Lock()
+	pending := map[string]transaction{}
+	for id, tx := range c.transactions.transactions {
+		pending[id] = *tx
+	}
+	c.transactions.mu.Unlock()
+
+	for id, tx := range pending {
+		ctx := withCorrelationID(ctx, tx.CorrelationID)
+		var steps []transactionStep
+		for _, step := range tx.Steps {
+			if step.Done || !c.claimDeleted(tx, step) {
+				continue
+			}
+
+			switch step.Step {
+			case stepPV:
+				err := c.deleteCall(ctx, func() error {
+					return c.clientset.CoreV1().PersistentVolumes().Delete(ctx, step.Volume, metav1.DeleteOptions{})
+				})
+				if err != nil && !apierrors.IsNotFound(err) {
+					logf(ctx, "failed to resume deleting pv(%s) of pvc(%s): %v\n", step.Volume, step.Claim, err)
+					steps = append(steps, step)
+					continue
+				}
+				logf(ctx, "resumed deleting pv(%s) of pvc(%s)\n", step.Volume, step.Claim)
+			case stepAttachments:
+				c.deleteAttachments(ctx, step.Volume, tx.Nodes)
+			}
+			step.Done = true
+			steps = append(steps, step)
+		}
+
+		c.transactions.mu.Lock()
+		if len(steps) == 0 || (&transaction{Steps: steps}).done() {
+			delete(c.transactions.transactions, id)
+		} else {
+			tx.Steps = steps
+			c.transactions.transactions[id] = &tx
+		}
+		c.transactions.mu.Unlock()
+		c.saveTransactions(ctx)
+	}
+}
+
+// claimDeleted reports whether the claim of a step was deleted by its
+// transaction, so only the steps after deleting it are left.
+func (c *cleaner) claimDeleted(tx transaction, step transactionStep) bool {
+	for _, other := range tx.Steps {
+		if other.UID == step.UID && other.Step == stepPVC && !other.Done {
+			return false
+		}
+	}
+
+	namespace, name, _ := strings.Cut(step.Claim, "/")
+	pvc, err := c.factory.Core().V1().PersistentVolumeClaims().Lister().PersistentVolumeClaims(namespace).Get(name)
+	if err == nil && pvc.UID == step.UID {
+		return false
+	}
+	return apierrors.IsNotFound(err) || err == nil
+}
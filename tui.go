@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// tui is a live terminal view of a running cleaner driven through its http
+// api.
+type tui struct {
+	server  string
+	token   string
+	client  *http.Client
+	refresh time.Duration
+
+	decisions []decisionRecord
+	stats     statsResponse
+	blacklist []failureEntry
+	err       error
+	selected  int
+	messages  []string
+	// offset is the first line of the view shown, which scrolls to keep the
+	// selected claim on screen, and page the number of lines shown.
+	offset int
+	page   int
+	// width and height are the size of the terminal, zero until it is known.
+	width  int
+	height int
+}
+
+// fetchedMsg carries the state read from the cleaner api.
+type fetchedMsg struct {
+	decisions []decisionRecord
+	stats     statsResponse
+	blacklist []failureEntry
+	err       error
+}
+
+// tickMsg asks for the view to be refreshed.
+type tickMsg time.Time
+
+// actionMsg reports the outcome of a request made from the tui.
+type actionMsg string
+
+func runTUI(args []string) {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	server := flags.String("server", "http://localhost:8080", "address of the cleaner api")
+	apiTokenFile := flags.String("api-token-file", "", "file containing the bearer token of the cleaner api")
+	refresh := flags.Duration("refresh", 2*time.Second, "how often to refresh the view")
+	flags.Parse(args)
+
+	t := &tui{
+		server:  strings.TrimSuffix(*server, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		refresh: *refresh,
+	}
+	if *apiTokenFile != "" {
+		token, err := os.ReadFile(*apiTokenFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read api token: %v\n", err)
+			os.Exit(1)
+		}
+		t.token = strings.TrimSpace(string(token))
+	}
+
+	if _, err := tea.NewProgram(t, tea.WithAltScreen()).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "tui failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (t *tui) Init() tea.Cmd {
+	return tea.Batch(t.fetch, t.tick())
+}
+
+func (t *tui) tick() tea.Cmd {
+	return tea.Tick(t.refresh, func(now time.Time) tea.Msg {
+		return tickMsg(now)
+	})
+}
+
+func (t *tui) get(path string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, t.server+path, nil)
+	if err != nil {
+		return err
+	}
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (t *tui) post(path string) error {
+	req, err := http.NewRequest(http.MethodPost, t.server+path, nil)
+	if err != nil {
+		return err
+	}
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s: %s", path, resp.Status)
+	}
+	return nil
+}
+
+// fetch reads the state of the cleaner. It runs outside the update loop, so
+// it only reads the fields set up before the program starts.
+func (t *tui) fetch() tea.Msg {
+	var status struct {
+		Decisions []decisionRecord `json:"decisions"`
+	}
+	var blacklist struct {
+		Entries []failureEntry `json:"entries"`
+	}
+	var msg fetchedMsg
+
+	msg.err = t.get("/v1/status", &status)
+	if msg.err == nil {
+		msg.err = t.get("/v1/stats", &msg.stats)
+	}
+	if msg.err == nil {
+		msg.err = t.get("/v1/blacklist", &blacklist)
+	}
+	msg.decisions = status.Decisions
+	msg.blacklist = blacklist.Entries
+	return msg
+}
+
+// action posts to path and reports the outcome as done or failed.
+func (t *tui) action(path, done, failed string) tea.Cmd {
+	return func() tea.Msg {
+		if err := t.post(path); err != nil {
+			return actionMsg(fmt.Sprintf("%s: %v", failed, err))
+		}
+		return actionMsg(done)
+	}
+}
+
+func (t *tui) message(format string, args ...any) {
+	t.messages = append(t.messages, time.Now().Format("15:04:05")+" "+fmt.Sprintf(format, args...))
+	if len(t.messages) > 5 {
+		t.messages = t.messages[len(t.messages)-5:]
+	}
+}
+
+func (t *tui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		t.width, t.height = msg.Width, msg.Height
+	case tickMsg:
+		return t, tea.Batch(t.fetch, t.tick())
+	case fetchedMsg:
+		t.err = msg.err
+		if msg.err == nil {
+			t.decisions = msg.decisions
+			t.stats = msg.stats
+			t.blacklist = msg.blacklist
+		}
+		if t.selected >= len(t.decisions) {
+			t.selected = len(t.decisions) - 1
+		}
+		if t.selected < 0 {
+			t.selected = 0
+		}
+	case actionMsg:
+		t.message("%s", string(msg))
+		return t, t.fetch
+	case tea.KeyMsg:
+		return t, t.handleKey(msg)
+	}
+	return t, nil
+}
+
+// handleKey acts on a key press and returns the command it starts.
+func (t *tui) handleKey(key tea.KeyMsg) tea.Cmd {
+	switch key.String() {
+	case "q", "ctrl+c":
+		return tea.Quit
+	case "j", "down":
+		if t.selected < len(t.decisions)-1 {
+			t.selected++
+		}
+	case "k", "up":
+		if t.selected > 0 {
+			t.selected--
+		}
+	case "pgdown":
+		t.selected = max(min(t.selected+max(t.page, 1), len(t.decisions)-1), 0)
+	case "pgup":
+		t.selected = max(t.selected-max(t.page, 1), 0)
+	case "r":
+		return t.action("/v1/reconcile", "reconcile triggered", "reconcile failed")
+	case "a", "s":
+		if len(t.decisions) == 0 {
+			return nil
+		}
+		record := t.decisions[t.selected]
+		action := map[string]string{"a": "approve", "s": "skip"}[key.String()]
+		claim := record.Namespace + "/" + record.Name
+		return t.action(fmt.Sprintf("/v1/pvcs/%s/%s", claim, action),
+			fmt.Sprintf("%s of %s requested", action, claim),
+			fmt.Sprintf("%s of %s failed", action, claim))
+	}
+	return nil
+}
+
+// View draws the view clipped to the terminal, scrolled so the selected claim
+// is on screen, with the key help pinned to the last line.
+func (t *tui) View() string {
+	var lines []string
+	line := func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	line("local-pvc-cleaner %s  %s", t.server, time.Now().Format("15:04:05"))
+	if t.err != nil {
+		line("error: %v", t.err)
+	}
+	line("cleanups %d  failures %d  failure rate %.2f  mean node deletion to cleanup %.0fs",
+		t.stats.Cleanups, t.stats.Failures, t.stats.FailureRate, t.stats.MeanNodeDeletionToCleanupSeconds)
+	line("")
+
+	nodes := map[string]int{}
+	for _, record := range t.decisions {
+		for _, node := range record.Nodes {
+			nodes[node]++
+		}
+	}
+	nodeNames := make([]string, 0, len(nodes))
+	for node := range nodes {
+		nodeNames = append(nodeNames, node)
+	}
+	sort.Strings(nodeNames)
+	line("nodes")
+	for _, node := range nodeNames {
+		line("  %-40s %d pvcs", node, nodes[node])
+	}
+	line("")
+
+	line("  %-50s %-30s %-28s %s", "PVC", "NODES", "DECISION", "DEADLINE")
+	selectedLine := len(lines)
+	for i, record := range t.decisions {
+		cursor := " "
+		if i == t.selected {
+			cursor = ">"
+			selectedLine = len(lines)
+		}
+		deadline := ""
+		if record.Deadline != nil {
+			deadline = "in " + time.Until(*record.Deadline).Round(time.Second).String()
+		}
+		line("%s %-50s %-30s %-28s %s", cursor, record.Namespace+"/"+record.Name, strings.Join(record.Nodes, ","), record.Decision, deadline)
+	}
+	line("")
+
+	line("blacklist")
+	for _, entry := range t.blacklist {
+		if entry.Blacklisted {
+			line("  %-50s %d attempts  %s", entry.Key, entry.Attempts, entry.LastError)
+		}
+	}
+	line("")
+
+	line("recent actions")
+	for _, message := range t.messages {
+		line("  %s", message)
+	}
+
+	width, height := t.width, t.height
+	if height < 2 {
+		width, height = 0, len(lines)+1
+	}
+	t.page = height - 1
+	if selectedLine < t.offset {
+		t.offset = selectedLine
+	}
+	if selectedLine >= t.offset+t.page {
+		t.offset = selectedLine - t.page + 1
+	}
+	t.offset = max(min(t.offset, len(lines)-t.page), 0)
+	view := lines[t.offset:min(t.offset+t.page, len(lines))]
+
+	var b strings.Builder
+	for _, text := range view {
+		b.WriteString(clip(text, width) + "\n")
+	}
+	help := "j/k or arrows move  pgup/pgdn page  a approve  s skip  r reconcile  q quit"
+	if len(lines) > t.page {
+		help += fmt.Sprintf("  lines %d-%d of %d", t.offset+1, t.offset+len(view), len(lines))
+	}
+	// the last line ends without a newline so the view does not scroll
+	b.WriteString(clip(help, width))
+	return b.String()
+}
+
+// clip cuts text to the terminal width so lines do not wrap, leaving it alone
+// when the width is unknown.
+func clip(text string, width int) string {
+	if width <= 0 || len(text) <= width {
+		return text
+	}
+	return text[:width]
+}
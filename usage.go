@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// usedBytesQuery returns the last used bytes of a claim reported by the
+// kubelet, which includes the usage of CSI volumes reported by their driver.
+// The node of an orphan is gone, so the query looks back over a day.
+const usedBytesQuery = `last_over_time(kubelet_volume_stats_used_bytes{namespace=%q,persistentvolumeclaim=%q}[1d])`
+
+// volumeSample is the used bytes of a claim last reported by its kubelet.
+type volumeSample struct {
+	uid   types.UID
+	bytes int64
+	at    time.Time
+}
+
+// volumeUsage looks up how much data the volume of a claim held, so reports
+// tell the data discarded by a cleanup rather than the requested capacity.
+// Usage comes from prometheus when configured, otherwise from the kubelet
+// stats sampled while the nodes were still there.
+type volumeUsage struct {
+	prometheusURL string
+	client        *http.Client
+
+	mu      sync.Mutex
+	samples map[string]volumeSample
+}
+
+func newVolumeUsage(prometheusURL string) *volumeUsage {
+	return &volumeUsage{
+		prometheusURL: prometheusURL,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		samples:       map[string]volumeSample{},
+	}
+}
+
+// kubeletSummary is the part of the kubelet stats summary holding the usage of
+// the volumes of claims.
+type kubeletSummary struct {
+	Pods []struct {
+		Volumes []struct {
+			UsedBytes *int64 `json:"usedBytes"`
+			PVCRef    *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+// sampleUsage reads the stats summary of every node through the api server
+// proxy and remembers the used bytes of the claims of their pods.
+func (c *cleaner) sampleUsage(ctx context.Context) {
+	nodes, err := c.factory.Core().V1().Nodes().Lister().List(labels.Everything())
+	if err != nil {
+		logf(ctx, "failed to list nodes: %v\n", err)
+		return
+	}
+
+	pvcs := c.factory.Core().V1().PersistentVolumeClaims().Lister()
+	now := time.Now()
+	for _, node := range nodes {
+		raw, err := c.clientset.CoreV1().RESTClient().Get().
+			AbsPath("/api/v1/nodes/" + node.Name + "/proxy/stats/summary").
+			Do(ctx).Raw()
+		if err != nil {
+			tracef("failed to get stats summary of node(%s): %v\n", node.Name, err)
+			continue
+		}
+		var summary kubeletSummary
+		err = json.Unmarshal(raw, &summary)
+		if err != nil {
+			tracef("invalid stats summary of node(%s): %v\n", node.Name, err)
+			continue
+		}
+
+		c.usage.mu.Lock()
+		for _, pod := range summary.Pods {
+			for _, volume := range pod.Volumes {
+				if volume.PVCRef == nil || volume.UsedBytes == nil {
+					continue
+				}
+				pvc, err := pvcs.PersistentVolumeClaims(volume.PVCRef.Namespace).Get(volume.PVCRef.Name)
+				if err != nil {
+					continue
+				}
+				c.usage.samples[claimKey(pvc)] = volumeSample{uid: pvc.UID, bytes: *volume.UsedBytes, at: now}
+			}
+		}
+		c.usage.mu.Unlock()
+	}
+
+	// forget claims whose samples are older than a day, like the query
+	c.usage.mu.Lock()
+	for key, sample := range c.usage.samples {
+		if now.Sub(sample.at) > 24*time.Hour {
+			delete(c.usage.samples, key)
+		}
+	}
+	c.usage.mu.Unlock()
+}
+
+// runUsageSampling samples the kubelet stats every interval until the context
+// is done.
+func (c *cleaner) runUsageSampling(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.sampleUsage(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// usedBytes returns how many bytes the volume of a claim held, or -1 when
+// that is unknown.
+func (c *cleaner) usedBytes(ctx context.Context, pvc *corev1.PersistentVolumeClaim) int64 {
+	if c.usage == nil {
+		return -1
+	}
+	if c.usage.prometheusURL != "" {
+		used, err := c.usage.query(ctx, pvc)
+		if err != nil {
+			logf(ctx, "failed to query used bytes of pvc(%s): %v\n", pvc.Name, err)
+		}
+		return used
+	}
+
+	c.usage.mu.Lock()
+	defer c.usage.mu.Unlock()
+	sample, ok := c.usage.samples[claimKey(pvc)]
+	if !ok || sample.uid != pvc.UID {
+		return -1
+	}
+	return sample.bytes
+}
+
+// query asks prometheus for the last used bytes of a claim.
+func (u *volumeUsage) query(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (int64, error) {
+	query := url.Values{"query": {fmt.Sprintf(usedBytesQuery, pvc.Namespace, pvc.Name)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.prometheusURL+"/api/v1/query?"+query.Encode(), nil)
+	if err != nil {
+		return -1, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return -1, fmt.Errorf("prometheus responded with %s", resp.Status)
+	}
+
+	var result struct {
+		Data struct {
+			Result []struct {
+				Value []any `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return -1, err
+	}
+	if len(result.Data.Result) == 0 || len(result.Data.Result[0].Value) != 2 {
+		return -1, nil
+	}
+	value, _ := result.Data.Result[0].Value[1].(string)
+	used, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return -1, fmt.Errorf("invalid value %q: %w", value, err)
+	}
+	return int64(used), nil
+}
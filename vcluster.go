@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// fakeNodeLabel marks the nodes vcluster makes up for the host nodes its pods
+// run on when it does not sync the real ones. They are removed once no pod of
+// the virtual cluster runs on them anymore, whether the host node exists or
+// not.
+const fakeNodeLabel = "vcluster.loft.sh/fake-node"
+
+var errVirtualCluster = errors.New("not available in a virtual cluster")
+
+// nodeTranslator translates the node names volumes and claims are pinned to
+// into the names of the node objects the cleaner looks them up by, for virtual
+// clusters whose node objects are named differently than the host nodes.
+type nodeTranslator interface {
+	translate(nodeName string) string
+}
+
+// regexpTranslator rewrites node names matching a pattern.
+type regexpTranslator struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+func (t regexpTranslator) translate(nodeName string) string {
+	return t.pattern.ReplaceAllString(nodeName, t.replacement)
+}
+
+// mapTranslator looks node names up in a mapping, keeping the ones missing
+// from it.
+type mapTranslator map[string]string
+
+func (t mapTranslator) translate(nodeName string) string {
+	if translated, ok := t[nodeName]; ok {
+		return translated
+	}
+	return nodeName
+}
+
+// newNodeTranslator returns the translator of a pattern and replacement or of
+// a json file mapping host node names to virtual ones, or nil when neither is
+// configured.
+func newNodeTranslator(pattern, replacement, file string) (nodeTranslator, error) {
+	switch {
+	case pattern != "" && file != "":
+		return nil, fmt.Errorf("--node-name-pattern and --node-names-file are exclusive")
+	case pattern != "":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node name pattern: %w", err)
+		}
+		return regexpTranslator{pattern: re, replacement: replacement}, nil
+	case file != "":
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		mapping := mapTranslator{}
+		err = json.Unmarshal(b, &mapping)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", file, err)
+		}
+		return mapping, nil
+	}
+	return nil, nil
+}
+
+// translateNode returns the name of the node object of a node name.
+func (c *cleaner) translateNode(nodeName string) string {
+	if c.translator == nil {
+		return nodeName
+	}
+	return c.translator.translate(nodeName)
+}
+
+// translateNodes translates node names in place.
+func (c *cleaner) translateNodes(nodeNames []string) []string {
+	if c.translator == nil {
+		return nodeNames
+	}
+	for i, nodeName := range nodeNames {
+		nodeNames[i] = c.translator.translate(nodeName)
+	}
+	return nodeNames
+}
+
+// virtualNodesDecision keeps every orphan in virtual cluster mode while the
+// node objects cannot tell a deleted host node from an idle one: when vcluster
+// makes up fake nodes, which disappear with their last pod, or has no nodes at
+// all.
+func (c *cleaner) virtualNodesDecision(ctx context.Context, cand candidate) decision {
+	if !c.virtualCluster {
+		return ""
+	}
+
+	nodes, err := c.factory.Core().V1().Nodes().Lister().List(labels.Everything())
+	if err != nil {
+		logf(ctx, "failed to list nodes: %v\n", err)
+		return decisionFailed
+	}
+	if len(nodes) == 0 {
+		logf(ctx, "virtual cluster has no nodes, keeping pvc(%s/%s)\n", cand.pvc.Namespace, cand.pvc.Name)
+		return decisionSkippedFakeNodes
+	}
+	for _, node := range nodes {
+		if fakeNode(node) {
+			logf(ctx, "virtual cluster has fake node(%s), keeping pvc(%s/%s) of nodes(%s)\n", node.Name, cand.pvc.Namespace, cand.pvc.Name, strings.Join(cand.nodes, ","))
+			return decisionSkippedFakeNodes
+		}
+	}
+	return ""
+}
+
+func fakeNode(node *corev1.Node) bool {
+	return node.Labels[fakeNodeLabel] == "true"
+}
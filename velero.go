@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+var veleroRestoreResource = schema.GroupVersionResource{
+	Group:    "velero.io",
+	Version:  "v1",
+	Resource: "restores",
+}
+
+// veleroRestoreDone are the phases of restores that no longer change claims.
+var veleroRestoreDone = stringList{"Completed", "PartiallyFailed", "Failed", "FailedValidation"}
+
+// restoreActive reports whether a velero restore is still in progress.
+func restoreActive(restore *unstructured.Unstructured) bool {
+	phase, _, _ := unstructured.NestedString(restore.Object, "status", "phase")
+	return !veleroRestoreDone.contains(phase)
+}
+
+// restoreCovers reports whether a restore restores into a namespace: one of
+// its included namespaces, all of them when none or "*" are included, or the
+// target of its namespace mapping, unless excluded.
+func restoreCovers(restore *unstructured.Unstructured, namespace string) bool {
+	excluded, _, _ := unstructured.NestedStringSlice(restore.Object, "spec", "excludedNamespaces")
+	mapping, _, _ := unstructured.NestedStringMap(restore.Object, "spec", "namespaceMapping")
+	for source, target := range mapping {
+		if target == namespace && !stringList(excluded).contains(source) {
+			return true
+		}
+	}
+
+	if stringList(excluded).contains(namespace) {
+		return false
+	}
+	included, _, _ := unstructured.NestedStringSlice(restore.Object, "spec", "includedNamespaces")
+	if _, mapped := mapping[namespace]; mapped {
+		return false
+	}
+	return len(included) == 0 || stringList(included).contains("*") || stringList(included).contains(namespace)
+}
+
+// watchVeleroRestores watches the velero restores of a namespace, all
+// namespaces when empty, so claims of namespaces being restored are not
+// cleaned up mid restore. Finished restores trigger a reconcile for the claims
+// they held back.
+func (c *cleaner) watchVeleroRestores(client dynamic.Interface, namespace string, stopCh <-chan struct{}) {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, 0, namespace, nil)
+	informer := factory.ForResource(veleroRestoreResource).Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj any) {
+			oldRestore, newRestore := oldObj.(*unstructured.Unstructured), newObj.(*unstructured.Unstructured)
+			if restoreActive(oldRestore) && !restoreActive(newRestore) {
+				fmt.Printf("velero restore(%s/%s) finished\n", newRestore.GetNamespace(), newRestore.GetName())
+				c.triggerReconcile()
+			}
+		},
+		DeleteFunc: func(obj any) {
+			c.triggerReconcile()
+		},
+	})
+	c.veleroRestores = informer
+
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+}
+
+// restoringNamespace returns the namespace/name of a velero restore in
+// progress into a namespace, or an empty string.
+func (c *cleaner) restoringNamespace(namespace string) string {
+	if c.veleroRestores == nil {
+		return ""
+	}
+
+	for _, obj := range c.veleroRestores.GetStore().List() {
+		restore, ok := obj.(*unstructured.Unstructured)
+		if ok && restoreActive(restore) && restoreCovers(restore, namespace) {
+			return restore.GetNamespace() + "/" + restore.GetName()
+		}
+	}
+	return ""
+}
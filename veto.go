@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	verdictAllow = "allow"
+	verdictDeny  = "deny"
+	verdictDelay = "delay"
+)
+
+// defaultVetoDelay is used when the webhook delays a cleanup without saying
+// for how long.
+const defaultVetoDelay = time.Minute
+
+// vetoWebhook asks an external endpoint whether a pending cleanup may proceed.
+type vetoWebhook struct {
+	url      string
+	failOpen bool
+	client   *http.Client
+}
+
+type vetoClaim struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Volume    string `json:"volume,omitempty"`
+}
+
+type vetoRequest struct {
+	Nodes         []string    `json:"nodes"`
+	PVCs          []vetoClaim `json:"pvcs"`
+	CorrelationID string      `json:"correlationId,omitempty"`
+}
+
+type vetoResponse struct {
+	Verdict      string `json:"verdict"`
+	DelaySeconds int    `json:"delaySeconds,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+func (v *vetoWebhook) ask(ctx context.Context, request vetoRequest) (vetoResponse, error) {
+	var response vetoResponse
+	body, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, bytes.NewReader(body))
+	if err != nil {
+		return response, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return response, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return response, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return response, err
+	}
+
+	switch response.Verdict {
+	case verdictAllow, verdictDeny, verdictDelay:
+		return response, nil
+	default:
+		return response, fmt.Errorf("unknown verdict %q", response.Verdict)
+	}
+}
+
+// vetoDecision returns why the cleanup of the given orphans must not happen
+// now, or an empty decision when the webhook allows it or none is configured.
+func (c *cleaner) vetoDecision(ctx context.Context, nodes []string, orphans []candidate) decision {
+	if c.veto == nil {
+		return ""
+	}
+
+	request := vetoRequest{Nodes: nodes, CorrelationID: correlationID(ctx)}
+	for _, cand := range orphans {
+		request.PVCs = append(request.PVCs, vetoClaim{
+			Namespace: cand.pvc.Namespace,
+			Name:      cand.pvc.Name,
+			Volume:    cand.pvc.Spec.VolumeName,
+		})
+	}
+
+	response, err := c.veto.ask(ctx, request)
+	if err != nil {
+		logf(ctx, "failed to ask veto webhook about nodes(%v): %v\n", nodes, err)
+		if c.veto.failOpen {
+			return ""
+		}
+		return decisionSkippedVetoUnavailable
+	}
+
+	switch response.Verdict {
+	case verdictDeny:
+		logf(ctx, "veto webhook denied cleanup of nodes(%v): %s\n", nodes, response.Reason)
+		return decisionSkippedVetoed
+	case verdictDelay:
+		delay := time.Duration(response.DelaySeconds) * time.Second
+		if delay <= 0 {
+			delay = defaultVetoDelay
+		}
+		logf(ctx, "veto webhook delayed cleanup of nodes(%v) by %s: %s\n", nodes, delay, response.Reason)
+		time.AfterFunc(delay, c.triggerReconcile)
+		return decisionSkippedVetoDelayed
+	default:
+		return ""
+	}
+}
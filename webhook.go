@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// nodeCordonedAnnotation is added by the mutating webhook to claims that
+	// select storage on a node being removed.
+	nodeCordonedAnnotation = "local-pvc-cleaner.io/node-cordoned"
+	toBeDeletedTaint       = "ToBeDeletedByClusterAutoscaler"
+)
+
+// nodeCordoned reports whether a node is cordoned or marked for removal.
+func nodeCordoned(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return true
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == toBeDeletedTaint || taint.Key == corev1.TaintNodeUnschedulable {
+			return true
+		}
+	}
+	return false
+}
+
+// cordonedNode returns the cordoned node a claim selects storage on, if any.
+func (c *cleaner) cordonedNode(pvc *corev1.PersistentVolumeClaim) (string, error) {
+	nodeName := pvc.Annotations[selectedNodeAnnotation]
+	if nodeName == "" {
+		return "", nil
+	}
+
+	node, err := c.factory.Core().V1().Nodes().Lister().Get(nodeName)
+	if err != nil {
+		return "", err
+	}
+	if !nodeCordoned(node) {
+		return "", nil
+	}
+	return nodeName, nil
+}
+
+func (c *cleaner) serveWebhook(addr, certFile, keyFile string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/validate-pvc", func(w http.ResponseWriter, r *http.Request) {
+		c.handleAdmission(w, r, false)
+	})
+	mux.HandleFunc("/mutate-pvc", func(w http.ResponseWriter, r *http.Request) {
+		c.handleAdmission(w, r, true)
+	})
+
+	go func() {
+		err := http.ListenAndServeTLS(addr, certFile, keyFile, mux)
+		if err != nil {
+			fmt.Printf("failed to serve webhook on %s: %v\n", addr, err)
+		}
+	}()
+}
+
+// handleAdmission always admits claims but warns about, and when mutating
+// annotates, claims selecting storage on a cordoned node.
+func (c *cleaner) handleAdmission(w http.ResponseWriter, r *http.Request, mutate bool) {
+	var review admissionv1.AdmissionReview
+	err := json.NewDecoder(r.Body).Decode(&review)
+	if err != nil || review.Request == nil {
+		http.Error(w, "invalid admission review", http.StatusBadRequest)
+		return
+	}
+
+	response := &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: true}
+	review.Response = response
+
+	var pvc corev1.PersistentVolumeClaim
+	err = json.Unmarshal(review.Request.Object.Raw, &pvc)
+	if err != nil {
+		fmt.Printf("failed to decode pvc in admission review: %v\n", err)
+		writeReview(w, review)
+		return
+	}
+
+	nodeName, err := c.cordonedNode(&pvc)
+	if err != nil {
+		tracef("failed to get selected node of pvc(%s/%s): %v\n", pvc.Namespace, pvc.Name, err)
+	}
+	if nodeName == "" || pvc.Annotations[nodeCordonedAnnotation] == nodeName {
+		writeReview(w, review)
+		return
+	}
+
+	response.Warnings = []string{fmt.Sprintf("pvc selects local storage on node %s which is cordoned for removal", nodeName)}
+	if mutate && !c.reportOnly() {
+		patch := []map[string]any{{
+			"op":    "add",
+			"path":  "/metadata/annotations/" + jsonPointerEscaper.Replace(nodeCordonedAnnotation),
+			"value": nodeName,
+		}}
+		if pvc.Annotations == nil {
+			patch = []map[string]any{{
+				"op":    "add",
+				"path":  "/metadata/annotations",
+				"value": map[string]string{nodeCordonedAnnotation: nodeName},
+			}}
+		}
+		response.Patch, err = json.Marshal(patch)
+		if err != nil {
+			fmt.Printf("failed to encode admission patch: %v\n", err)
+			writeReview(w, review)
+			return
+		}
+		patchType := admissionv1.PatchTypeJSONPatch
+		response.PatchType = &patchType
+	}
+
+	fmt.Printf("pvc(%s/%s) selects cordoned node(%s)\n", pvc.Namespace, pvc.Name, nodeName)
+	writeReview(w, review)
+}
+
+func writeReview(w http.ResponseWriter, review admissionv1.AdmissionReview) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(review)
+}
+
+// jsonPointerEscaper escapes a key for use in a json patch path.
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// protectPVCsAnnotation on a stateful set or deployment keeps the cleaner
+// away from all of its claims.
+const protectPVCsAnnotation = "local-pvc-cleaner.io/protect-pvcs"
+
+// claimOfStatefulSet reports whether a claim was created from one of the
+// volume claim templates of a stateful set, which names it
+// <template>-<statefulset>-<ordinal>.
+func claimOfStatefulSet(pvc *corev1.PersistentVolumeClaim, sts *appsv1.StatefulSet) bool {
+	for _, owner := range pvc.OwnerReferences {
+		if owner.Kind == "StatefulSet" && owner.UID == sts.UID {
+			return true
+		}
+	}
+
+	for _, template := range sts.Spec.VolumeClaimTemplates {
+		ordinal, ok := strings.CutPrefix(pvc.Name, template.Name+"-"+sts.Name+"-")
+		if !ok {
+			continue
+		}
+		if _, err := strconv.Atoi(ordinal); err == nil {
+			return true
+		}
+	}
+	return podTemplateClaims(sts.Spec.Template).contains(pvc.Name)
+}
+
+// podTemplateClaims returns the names of the claims a pod template mounts.
+func podTemplateClaims(template corev1.PodTemplateSpec) stringList {
+	var claims stringList
+	for _, volume := range template.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			claims = append(claims, volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	return claims
+}
+
+// protectingWorkload returns the stateful set or deployment of a claim that
+// protects its claims, or an empty string. Claims are matched to stateful sets
+// through their owner, the volume claim template naming convention or the pod
+// template, and to deployments through the pod template or the owners of the
+// pods consuming them.
+func (c *cleaner) protectingWorkload(pvc *corev1.PersistentVolumeClaim) string {
+	if !c.workloadProtection {
+		return ""
+	}
+
+	statefulSets, err := c.factory.Apps().V1().StatefulSets().Lister().StatefulSets(pvc.Namespace).List(labels.Everything())
+	if err != nil {
+		tracef("failed to list statefulsets of namespace(%s): %v\n", pvc.Namespace, err)
+	}
+	for _, sts := range statefulSets {
+		if sts.Annotations[protectPVCsAnnotation] == "true" && claimOfStatefulSet(pvc, sts) {
+			return "statefulset/" + sts.Name
+		}
+	}
+
+	deployments, err := c.factory.Apps().V1().Deployments().Lister().Deployments(pvc.Namespace).List(labels.Everything())
+	if err != nil {
+		tracef("failed to list deployments of namespace(%s): %v\n", pvc.Namespace, err)
+	}
+	protected := map[string]bool{}
+	for _, deployment := range deployments {
+		if deployment.Annotations[protectPVCsAnnotation] != "true" {
+			continue
+		}
+		if podTemplateClaims(deployment.Spec.Template).contains(pvc.Name) {
+			return "deployment/" + deployment.Name
+		}
+		protected[deployment.Name] = true
+	}
+	if len(protected) == 0 {
+		return ""
+	}
+
+	pods, err := c.consumerPods(pvc)
+	if err != nil {
+		tracef("failed to get pods of pvc(%s/%s): %v\n", pvc.Namespace, pvc.Name, err)
+	}
+	for _, pod := range pods {
+		for _, owner := range pod.OwnerReferences {
+			if owner.Kind != "ReplicaSet" {
+				continue
+			}
+			// replica sets of a deployment are named <deployment>-<pod template hash>
+			index := strings.LastIndex(owner.Name, "-")
+			if index > 0 && protected[owner.Name[:index]] {
+				return "deployment/" + owner.Name[:index]
+			}
+		}
+	}
+	return ""
+}